@@ -0,0 +1,100 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// AuthCollector counts the entities known to the cluster's auth database, so
+// that sudden growth in the number of users or daemons can be alerted on.
+// Only the count is exported; key material is never read.
+type AuthCollector struct {
+	conn   Conn
+	logger *logrus.Logger
+
+	entitiesDesc *prometheus.Desc
+}
+
+// NewAuthCollector creates a new AuthCollector instance
+func NewAuthCollector(exporter *Exporter) *AuthCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+
+	return &AuthCollector{
+		conn:   exporter.Conn,
+		logger: exporter.Logger,
+
+		entitiesDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_auth_entities_total", cephNamespace),
+			"Number of entities in the auth database, according to `ceph auth ls`",
+			nil,
+			labels,
+		),
+	}
+}
+
+// cephAuthLs deliberately only decodes the entity names, the keys are never
+// decoded or exported.
+type cephAuthLs struct {
+	AuthDump []struct {
+		Entity string `json:"entity"`
+	} `json:"auth_dump"`
+}
+
+// getAuthEntityCount runs the 'ceph auth ls' command and counts its entities
+func (a *AuthCollector) getAuthEntityCount() (int, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "auth ls",
+		"format": "json",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	buf, _, err := a.conn.MonCommand(cmd)
+	if err != nil {
+		return 0, err
+	}
+
+	var authData cephAuthLs
+	if err = json.Unmarshal(buf, &authData); err != nil {
+		return 0, err
+	}
+
+	return len(authData.AuthDump), nil
+}
+
+// Describe provides the metrics descriptions to Prometheus
+func (a *AuthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.entitiesDesc
+}
+
+// Collect sends all the collected metrics Prometheus.
+func (a *AuthCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	count, err := a.getAuthEntityCount()
+	if err != nil {
+		a.logger.WithError(err).Error("failed to run 'ceph auth ls'")
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(a.entitiesDesc, prometheus.GaugeValue, float64(count))
+
+	return nil
+}
@@ -0,0 +1,110 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		input              string
+		version            string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name: "several entities",
+			input: `
+{
+	"auth_dump": [
+		{
+			"entity": "osd.0",
+			"key": "AQBSecretOsdZeroKeyMaterial000000000000==",
+			"caps": {"mgr": "allow profile osd", "mon": "allow profile osd", "osd": "allow *"}
+		},
+		{
+			"entity": "client.admin",
+			"key": "AQBSecretAdminKeyMaterial0000000000000==",
+			"caps": {"mds": "allow *", "mgr": "allow *", "mon": "allow *", "osd": "allow *"}
+		},
+		{
+			"entity": "client.rgw.node01",
+			"key": "AQBSecretRgwKeyMaterial000000000000000==",
+			"caps": {"mon": "allow rw", "osd": "allow rwx"}
+		}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_auth_entities_total{cluster="ceph"} 3`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`AQBSecret`),
+				regexp.MustCompile(`client\.admin`),
+			},
+		},
+		{
+			name:    "no entities",
+			input:   `{"auth_dump": []}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_auth_entities_total{cluster="ceph"} 0`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(tt.version, "{}")
+			conn.On("MonCommand", mock.Anything).Return(
+				[]byte(tt.input), "", nil,
+			)
+
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+			e.cc = map[string]versionedCollector{
+				"auth": NewAuthCollector(e),
+			}
+			err := prometheus.Register(e)
+			require.NoError(t, err)
+			defer prometheus.Unregister(e)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		})
+	}
+}
@@ -139,14 +139,16 @@ func (c *ClusterUsageCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect sends the metric values for each metric pertaining to the global
 // cluster usage over to the provided prometheus Metric channel.
-func (c *ClusterUsageCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (c *ClusterUsageCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
 	c.logger.Debug("collecting cluster usage metrics")
 	if err := c.collect(); err != nil {
 		c.logger.WithError(err).Error("error collecting cluster usage metrics")
-		return
+		return err
 	}
 
 	for _, metric := range c.metricsList() {
 		ch <- metric
 	}
+
+	return nil
 }
@@ -140,7 +140,7 @@ func TestClusterUsage(t *testing.T) {
 				[]byte(tt.input), "", nil,
 			)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"clusterUsage": NewClusterUsageCollector(e),
 			}
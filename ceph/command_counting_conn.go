@@ -0,0 +1,73 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"sync"
+)
+
+// commandCountingConn wraps a Conn and counts the mon and mgr commands sent
+// through it. Every collector talks to the cluster through the exporter's
+// Conn, so this counts the commands of a whole scrape without each collector
+// having to report back.
+type commandCountingConn struct {
+	Conn
+
+	mu          sync.Mutex
+	monCommands int
+	mgrCommands int
+}
+
+func newCommandCountingConn(conn Conn) *commandCountingConn {
+	return &commandCountingConn{
+		Conn: conn,
+	}
+}
+
+// MonCommand counts the command and passes it on to the wrapped Conn.
+func (c *commandCountingConn) MonCommand(args []byte) ([]byte, string, error) {
+	c.mu.Lock()
+	c.monCommands++
+	c.mu.Unlock()
+
+	return c.Conn.MonCommand(args)
+}
+
+// MgrCommand counts the command and passes it on to the wrapped Conn.
+func (c *commandCountingConn) MgrCommand(args [][]byte) ([]byte, string, error) {
+	c.mu.Lock()
+	c.mgrCommands++
+	c.mu.Unlock()
+
+	return c.Conn.MgrCommand(args)
+}
+
+// resetCommandCounts starts counting the commands of a new scrape.
+func (c *commandCountingConn) resetCommandCounts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.monCommands = 0
+	c.mgrCommands = 0
+}
+
+// commandCounts returns the number of mon and mgr commands sent since the
+// last resetCommandCounts.
+func (c *commandCountingConn) commandCounts() (mon, mgr int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.monCommands, c.mgrCommands
+}
@@ -22,6 +22,7 @@ package ceph
 type Conn interface {
 	MonCommand([]byte) ([]byte, string, error)
 	MgrCommand([][]byte) ([]byte, string, error)
+	OsdCommand(int, [][]byte) ([]byte, string, error)
 	GetPoolStats(string) (*PoolStat, error)
 }
 
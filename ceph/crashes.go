@@ -103,7 +103,7 @@ func (c *CrashesCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 // Collect sends all the collected metrics Prometheus.
-func (c *CrashesCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (c *CrashesCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
 	crashes, err := c.getCrashLs()
 	if err != nil {
 		c.logger.WithError(err).Error("failed to run 'ceph crash ls'")
@@ -119,4 +119,6 @@ func (c *CrashesCollector) Collect(ch chan<- prometheus.Metric, version *Version
 			statusNames[crash.isNew],
 		)
 	}
+
+	return err
 }
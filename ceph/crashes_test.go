@@ -194,7 +194,7 @@ func TestCrashesCollector(t *testing.T) {
 					[]byte(tt.input), "", nil,
 				)
 
-				e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+				e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 				e.cc = map[string]versionedCollector{
 					"crashes": NewCrashesCollector(e),
 				}
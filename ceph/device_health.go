@@ -0,0 +1,251 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceHealthCollector reports the health of the devices backing the
+// cluster's daemons as tracked by the mgr devicehealth module, so that drives
+// can be replaced before DEVICE_HEALTH is raised. It sends one command per
+// device on each scrape and is only enabled with DEVICE_HEALTH_METRICS.
+type DeviceHealthCollector struct {
+	conn   Conn
+	logger *logrus.Logger
+
+	// now is time.Now, swapped out in tests.
+	now func() time.Time
+
+	lifeExpectancyDesc *prometheus.Desc
+	wearLevelDesc      *prometheus.Desc
+}
+
+// NewDeviceHealthCollector creates a new DeviceHealthCollector instance
+func NewDeviceHealthCollector(exporter *Exporter) *DeviceHealthCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+
+	return &DeviceHealthCollector{
+		conn:   exporter.Conn,
+		logger: exporter.Logger,
+		now:    time.Now,
+
+		lifeExpectancyDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_device_life_expectancy_days", cephNamespace),
+			"Days until the earliest predicted failure of a device",
+			[]string{"devid", "osd"},
+			labels,
+		),
+		wearLevelDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_device_smart_wear_level", cephNamespace),
+			"Share of the rated endurance of an SSD that has been used, from its last SMART scrape",
+			[]string{"devid", "osd"},
+			labels,
+		),
+	}
+}
+
+type cephDevice struct {
+	DevID             string   `json:"devid"`
+	Daemons           []string `json:"daemons"`
+	LifeExpectancyMin string   `json:"life_expectancy_min"`
+}
+
+// osds returns the OSDs on the device, or a single empty name for devices
+// only used by other daemons so that they are still reported.
+func (d cephDevice) osds() []string {
+	var osds []string
+	for _, daemon := range d.Daemons {
+		if strings.HasPrefix(daemon, "osd.") {
+			osds = append(osds, daemon)
+		}
+	}
+	if len(osds) == 0 {
+		return []string{""}
+	}
+
+	return osds
+}
+
+// cephSMARTMetrics holds the fields of a device's smartctl JSON output that
+// the wear level is read from, the same ones the devicehealth module uses.
+type cephSMARTMetrics struct {
+	NVMeHealthLog *struct {
+		PercentageUsed *float64 `json:"percentage_used"`
+	} `json:"nvme_smart_health_information_log"`
+	ATADeviceStatistics struct {
+		Pages []*struct {
+			Number int `json:"number"`
+			Table  []struct {
+				Offset int     `json:"offset"`
+				Value  float64 `json:"value"`
+			} `json:"table"`
+		} `json:"pages"`
+	} `json:"ata_device_statistics"`
+}
+
+// wearLevel returns the share of the rated endurance that has been used.
+// NVMe devices report it directly, SATA SSDs in the "Percentage Used
+// Endurance Indicator" of the solid state device statistics page.
+func (m *cephSMARTMetrics) wearLevel() (float64, bool) {
+	if m.NVMeHealthLog != nil && m.NVMeHealthLog.PercentageUsed != nil {
+		return *m.NVMeHealthLog.PercentageUsed / 100, true
+	}
+
+	for _, page := range m.ATADeviceStatistics.Pages {
+		if page == nil || page.Number != 7 {
+			continue
+		}
+		for _, item := range page.Table {
+			if item.Offset == 8 {
+				return item.Value / 100, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func (d *DeviceHealthCollector) getDevices() ([]cephDevice, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "device ls",
+		"format": "json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := d.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		d.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mgr command")
+
+		return nil, err
+	}
+
+	var devices []cephDevice
+	if err := json.Unmarshal(buf, &devices); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// getLatestSMARTMetrics returns the most recent SMART scrape of the device,
+// or nil if the devicehealth module hasn't scraped it yet.
+func (d *DeviceHealthCollector) getLatestSMARTMetrics(devID string) (*cephSMARTMetrics, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "device get-health-metrics",
+		"devid":  devID,
+		"format": "json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := d.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		d.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mgr command")
+
+		return nil, err
+	}
+
+	// Scrapes are keyed by their timestamp, e.g. 20230110-100000, which
+	// sorts in time order.
+	scrapes := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(buf, &scrapes); err != nil {
+		return nil, err
+	}
+	if len(scrapes) == 0 {
+		return nil, nil
+	}
+
+	stamps := make([]string, 0, len(scrapes))
+	for stamp := range scrapes {
+		stamps = append(stamps, stamp)
+	}
+	sort.Strings(stamps)
+
+	metrics := &cephSMARTMetrics{}
+	if err := json.Unmarshal(scrapes[stamps[len(stamps)-1]], metrics); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// Describe provides the metrics descriptions to Prometheus
+func (d *DeviceHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- d.lifeExpectancyDesc
+	ch <- d.wearLevelDesc
+}
+
+// Collect sends all the collected metrics Prometheus.
+func (d *DeviceHealthCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	devices, err := d.getDevices()
+	if err != nil {
+		d.logger.WithError(err).Error("failed to run 'ceph device ls'")
+		return err
+	}
+
+	var errs []error
+	now := d.now()
+	for _, device := range devices {
+		// Life expectancy is only known once a failure prediction module
+		// has run.
+		if device.LifeExpectancyMin != "" {
+			lifeExpectancy, err := parseCephStamp(device.LifeExpectancyMin)
+			if err != nil {
+				d.logger.WithError(err).WithField("devid", device.DevID).Debug("skipping device life expectancy")
+			} else {
+				days := lifeExpectancy.Sub(now).Hours() / 24
+				for _, osd := range device.osds() {
+					ch <- prometheus.MustNewConstMetric(d.lifeExpectancyDesc, prometheus.GaugeValue, days, device.DevID, osd)
+				}
+			}
+		}
+
+		metrics, err := d.getLatestSMARTMetrics(device.DevID)
+		if err != nil {
+			d.logger.WithError(err).WithField("devid", device.DevID).Error("failed to get device health metrics")
+			errs = append(errs, err)
+			continue
+		}
+		if metrics == nil {
+			continue
+		}
+
+		// HDDs have no wear level.
+		if wearLevel, ok := metrics.wearLevel(); ok {
+			for _, osd := range device.osds() {
+				ch <- prometheus.MustNewConstMetric(d.wearLevelDesc, prometheus.GaugeValue, wearLevel, device.DevID, osd)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
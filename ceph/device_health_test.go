@@ -0,0 +1,136 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceHealthCollector(t *testing.T) {
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+
+	conn.On("MgrCommand", mock.MatchedBy(func(in interface{}) bool {
+		v := map[string]interface{}{}
+
+		uv, ok := in.([][]byte)
+		require.True(t, ok)
+		require.Len(t, uv, 1)
+
+		err := json.Unmarshal(uv[0], &v)
+		require.NoError(t, err)
+
+		return v["prefix"] == "device ls"
+	})).Return([]byte(`
+[
+	{
+		"devid": "SAMSUNG_MZ7LH960HAJR_S45NNA0M123456",
+		"location": [{"host": "ceph-node01", "dev": "sda", "path": "/dev/disk/by-path/pci-0000:00:17.0-ata-1"}],
+		"daemons": ["osd.0"],
+		"life_expectancy_min": "2023-02-09T10:00:00.000000+0000",
+		"life_expectancy_max": "2023-03-11T10:00:00.000000+0000",
+		"life_expectancy_stamp": "2023-01-10T10:00:00.000000+0000"
+	},
+	{
+		"devid": "INTEL_SSDPE2KE016T8_PHLN123456781P6AGN",
+		"location": [{"host": "ceph-node01", "dev": "nvme0n1", "path": "/dev/disk/by-path/pci-0000:5e:00.0-nvme-1"}],
+		"daemons": ["osd.1", "osd.2"]
+	},
+	{
+		"devid": "ST8000NM0055-1RM112_ZA1ABCDE",
+		"location": [{"host": "ceph-mon01", "dev": "sdb", "path": "/dev/disk/by-path/pci-0000:00:17.0-ata-2"}],
+		"daemons": ["mon.a"]
+	}
+]`), "", nil)
+
+	for devid, metrics := range map[string]string{
+		// The latest of the scrapes is used.
+		"SAMSUNG_MZ7LH960HAJR_S45NNA0M123456": `{
+			"20230109-100000": {"ata_device_statistics": {"pages": [null, {"number": 7, "table": [{"offset": 8, "value": 3}]}]}},
+			"20230110-100000": {"ata_device_statistics": {"pages": [null, {"number": 7, "table": [{"offset": 8, "value": 4}]}]}}
+		}`,
+		"INTEL_SSDPE2KE016T8_PHLN123456781P6AGN": `{
+			"20230110-100000": {"nvme_smart_health_information_log": {"percentage_used": 12}}
+		}`,
+		// HDDs have no wear level.
+		"ST8000NM0055-1RM112_ZA1ABCDE": `{
+			"20230110-100000": {"ata_smart_attributes": {"table": []}}
+		}`,
+	} {
+		devid, metrics := devid, metrics
+		conn.On("MgrCommand", mock.MatchedBy(func(in interface{}) bool {
+			v := map[string]interface{}{}
+
+			uv, ok := in.([][]byte)
+			require.True(t, ok)
+			require.Len(t, uv, 1)
+
+			err := json.Unmarshal(uv[0], &v)
+			require.NoError(t, err)
+
+			return v["prefix"] == "device get-health-metrics" && v["devid"] == devid
+		})).Return([]byte(metrics), "", nil)
+	}
+
+	conn.On("MgrCommand", mock.Anything).Return([]byte(""), "", fmt.Errorf("not under test"))
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	c := NewDeviceHealthCollector(e)
+	c.now = func() time.Time { return time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC) }
+	e.cc = map[string]versionedCollector{
+		"deviceHealth": c,
+	}
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`ceph_device_life_expectancy_days{cluster="ceph",devid="SAMSUNG_MZ7LH960HAJR_S45NNA0M123456",osd="osd.0"} 30`),
+		regexp.MustCompile(`ceph_device_smart_wear_level{cluster="ceph",devid="SAMSUNG_MZ7LH960HAJR_S45NNA0M123456",osd="osd.0"} 0.04`),
+		regexp.MustCompile(`ceph_device_smart_wear_level{cluster="ceph",devid="INTEL_SSDPE2KE016T8_PHLN123456781P6AGN",osd="osd.1"} 0.12`),
+		regexp.MustCompile(`ceph_device_smart_wear_level{cluster="ceph",devid="INTEL_SSDPE2KE016T8_PHLN123456781P6AGN",osd="osd.2"} 0.12`),
+	} {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+	for _, re := range []*regexp.Regexp{
+		// no failure prediction yet
+		regexp.MustCompile(`ceph_device_life_expectancy_days{cluster="ceph",devid="INTEL_SSDPE2KE016T8_PHLN123456781P6AGN"`),
+		regexp.MustCompile(`ceph_device_smart_wear_level{cluster="ceph",devid="ST8000NM0055-1RM112_ZA1ABCDE"`),
+	} {
+		require.False(t, re.Match(buf), "expected %s not to match", re.String())
+	}
+}
@@ -16,56 +16,299 @@ package ceph
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/Jeffail/gabs"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
+// versionedCollector is implemented by all the collectors of the exporter.
+// Collect returns an error if any part of the collection failed, be it a
+// command or its response, which the exporter reports as the last scrape
+// error.
 type versionedCollector interface {
-	Collect(chan<- prometheus.Metric, *Version)
+	Collect(chan<- prometheus.Metric, *Version) error
 	Describe(chan<- *prometheus.Desc)
 }
 
+// collectErrors gathers the errors of the parts of a collector that are
+// collected concurrently.
+type collectErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// add records err, if it isn't nil.
+func (c *collectErrors) add(err error) {
+	if err == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errs = append(c.errs, err)
+}
+
+// err returns the errors added so far joined, or nil if there were none.
+func (c *collectErrors) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return errors.Join(c.errs...)
+}
+
+// minVersionCollector is implemented by collectors that rely on commands only
+// available from a Ceph release on. They are skipped on older clusters rather
+// than failing on every scrape.
+type minVersionCollector interface {
+	minVersion() *Version
+}
+
+// backgroundCollector is implemented by collectors that also collect between
+// scrapes, which they do until stop is closed. They are given the connection
+// the exporter was created with, so their commands aren't taken for those of
+// a scrape.
+type backgroundCollector interface {
+	collectBackground(conn Conn, stop <-chan struct{})
+}
+
+// partialCollector is implemented by collectors that skip only some of their
+// parts on older Ceph releases, through versionGates.
+type partialCollector interface {
+	skippedParts() []string
+}
+
+// versionGates is embedded by collectors to gate parts of their collection on
+// the Ceph release, so that the parts an older cluster doesn't get are
+// reported by ceph_exporter_collector_skipped like whole collectors are.
+type versionGates struct {
+	mu      sync.Mutex
+	skipped map[string]bool
+}
+
+// versionAtLeast returns whether version is at least minVersion, and records
+// part as skipped by the current scrape otherwise.
+func (g *versionGates) versionAtLeast(version, minVersion *Version, part string) bool {
+	if version.IsAtLeast(minVersion) {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.skipped == nil {
+		g.skipped = make(map[string]bool)
+	}
+	g.skipped[part] = true
+
+	return false
+}
+
+// skippedParts returns the parts skipped since it was last called, sorted.
+func (g *versionGates) skippedParts() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	parts := make([]string, 0, len(g.skipped))
+	for part := range g.skipped {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	g.skipped = nil
+
+	return parts
+}
+
 // Exporter wraps all the ceph collectors and provides a single global
 // exporter to extracts metrics out of. It also ensures that the collection
 // is done in a thread-safe manner, the necessary requirement stated by
 // prometheus. It also implements a prometheus.Collector interface in order
 // to register it correctly.
 type Exporter struct {
-	mu        sync.Mutex
-	Conn      Conn
-	Cluster   string
-	Config    string
-	User      string
-	RgwMode   int
-	RbdMirror bool
-	Logger    *logrus.Logger
-	Version   *Version
-	cc        map[string]versionedCollector
+	mu           sync.Mutex
+	Conn         Conn
+	Cluster      string
+	Config       string
+	User         string
+	RgwMode      int
+	RbdMirror    bool
+	OSDOpQueue   bool
+	OSDPerfDump  bool
+	DeviceHealth bool
+	AuthMetrics  bool
+	PGQuery      bool
+	Logger       *logrus.Logger
+	Version      *Version
+	cc           map[string]versionedCollector
+
+	// OSDDeviceClassAllowlist limits the per-OSD metrics to OSDs of these
+	// device classes, all OSDs are included when it is empty.
+	OSDDeviceClassAllowlist []string
+
+	// HealthCheckSeverity overrides the built-in criticality of health
+	// checks for HealthStatusInterpreter, 0 ignores a check.
+	HealthCheckSeverity map[string]int
+
+	// OSDLabelRelabels rewrite the host, rack and root labels of the per-OSD
+	// metrics, in order.
+	OSDLabelRelabels []OSDLabelRelabel
+
+	// stop is closed by Close to stop the background collection.
+	stop chan struct{}
+
+	// commands counts the commands of each scrape, it is the same connection
+	// as Conn.
+	commands *commandCountingConn
+
+	// pgDump is the PG dump shared by the collectors of each scrape.
+	pgDump *scrapePGDump
+
+	// lastError is when a scrape last failed, as reported by LastScrapeError.
+	lastError time.Time
+
+	// now is time.Now, swapped out in tests.
+	now func() time.Time
+
+	// LastScrapeError shows when any collector of this cluster last failed.
+	LastScrapeError *prometheus.Desc
+
+	// MonCommandsPerScrape and MgrCommandsPerScrape show how many commands
+	// the last scrape sent, i.e. the load each scrape puts on the cluster.
+	MonCommandsPerScrape *prometheus.Desc
+	MgrCommandsPerScrape *prometheus.Desc
+
+	// Collectors shows how many collectors are enabled and disabled, to
+	// confirm the configuration took effect.
+	Collectors *prometheus.Desc
+
+	// CollectorSkipped flags the collectors skipped by the last scrape, so
+	// that their metrics missing on older clusters isn't mistaken for a bug.
+	CollectorSkipped *prometheus.Desc
 }
 
+// ExporterOptions configures what an Exporter collects beyond the standard
+// collectors, as set by the environment and config file.
+type ExporterOptions struct {
+	// Config and User are the Ceph config file and user the cluster is
+	// accessed with, used by the RGW collector.
+	Config string
+	User   string
+
+	// RgwMode is one of the RGWMode* constants.
+	RgwMode int
+
+	// OSDOpQueue and OSDPerfDump enable commands sent to every OSD daemon,
+	// PGQuery one to every backfilling PG.
+	OSDOpQueue  bool
+	OSDPerfDump bool
+	PGQuery     bool
+
+	DeviceHealth bool
+
+	// AuthMetrics enables the auth collector, which lists the whole auth
+	// database with `ceph auth ls` every scrape.
+	AuthMetrics bool
+
+	// OSDDeviceClassAllowlist limits the per-OSD metrics to OSDs of these
+	// device classes, all OSDs are included when it is empty.
+	OSDDeviceClassAllowlist []string
+
+	// HealthCheckSeverity overrides the built-in criticality of health
+	// checks for HealthStatusInterpreter, 0 ignores a check.
+	HealthCheckSeverity map[string]int
+
+	// OSDLabelRelabels rewrite the host, rack and root labels of the per-OSD
+	// metrics, in order.
+	OSDLabelRelabels []OSDLabelRelabel
+}
+
+// optionalCollectors are the collectors that are only enabled by the
+// configuration, e.g. RGW_MODE, or when the cluster runs the daemons, e.g.
+// rbd-mirror.
+var optionalCollectors = []string{"rgw", "rbdMirror", "deviceHealth", "auth"}
+
 // NewExporter returns an initialized *Exporter
 // We can choose to enable a collector to extract stats out of by adding it to the list of collectors.
-func NewExporter(conn Conn, cluster string, config string, user string, rgwMode int, logger *logrus.Logger) *Exporter {
-	e := &Exporter{
-		Conn:    conn,
-		Cluster: cluster,
-		Config:  config,
-		User:    user,
-		RgwMode: rgwMode,
-		Logger:  logger,
-	}
-	err := e.setCephVersion()
-	if err != nil {
-		e.Logger.WithError(err).Error("failed to set ceph version")
-		return nil
+func NewExporter(conn Conn, cluster string, opts ExporterOptions, logger *logrus.Logger) (*Exporter, error) {
+	e := newExporter(conn, cluster, opts, logger)
+	if err := e.setCephVersion(); err != nil {
+		return nil, fmt.Errorf("failed to set ceph version: %s", err)
 	}
 	e.cc = e.initCollectors()
 
-	return e
+	for _, cc := range e.cc {
+		if bc, ok := cc.(backgroundCollector); ok {
+			go bc.collectBackground(conn, e.stop)
+		}
+	}
+
+	return e, nil
+}
+
+// Close stops the background collection of the collectors started by
+// NewExporter.
+func (exporter *Exporter) Close() {
+	close(exporter.stop)
+}
+
+// newExporter returns an Exporter without any collector, which are set up by
+// NewExporter once the version of the cluster is known, or by the tests.
+func newExporter(conn Conn, cluster string, opts ExporterOptions, logger *logrus.Logger) *Exporter {
+	commands := newCommandCountingConn(conn)
+
+	return &Exporter{
+		Conn:         commands,
+		Cluster:      cluster,
+		Config:       opts.Config,
+		User:         opts.User,
+		RgwMode:      opts.RgwMode,
+		OSDOpQueue:   opts.OSDOpQueue,
+		OSDPerfDump:  opts.OSDPerfDump,
+		DeviceHealth: opts.DeviceHealth,
+		AuthMetrics:  opts.AuthMetrics,
+		PGQuery:      opts.PGQuery,
+		Logger:       logger,
+		stop:         make(chan struct{}),
+		commands:     commands,
+		pgDump:       newScrapePGDump(commands, logger),
+		now:          time.Now,
+
+		OSDDeviceClassAllowlist: opts.OSDDeviceClassAllowlist,
+		HealthCheckSeverity:     opts.HealthCheckSeverity,
+		OSDLabelRelabels:        opts.OSDLabelRelabels,
+
+		LastScrapeError: prometheus.NewDesc(
+			fmt.Sprintf("%s_exporter_last_scrape_error_timestamp_seconds", cephNamespace),
+			"Unix timestamp of the last scrape in which a collector failed, 0 if there hasn't been one",
+			nil, prometheus.Labels{"cluster": cluster},
+		),
+		MonCommandsPerScrape: prometheus.NewDesc(
+			fmt.Sprintf("%s_exporter_mon_commands_per_scrape", cephNamespace),
+			"Number of mon commands sent by the last scrape",
+			nil, prometheus.Labels{"cluster": cluster},
+		),
+		MgrCommandsPerScrape: prometheus.NewDesc(
+			fmt.Sprintf("%s_exporter_mgr_commands_per_scrape", cephNamespace),
+			"Number of mgr commands sent by the last scrape",
+			nil, prometheus.Labels{"cluster": cluster},
+		),
+		Collectors: prometheus.NewDesc(
+			fmt.Sprintf("%s_exporter_collectors", cephNamespace),
+			"Number of collectors by state, enabled or disabled",
+			[]string{"state"}, prometheus.Labels{"cluster": cluster},
+		),
+		CollectorSkipped: prometheus.NewDesc(
+			fmt.Sprintf("%s_exporter_collector_skipped", cephNamespace),
+			"Collectors, or collector/part for parts of one, skipped by the last scrape and why, the value is always 1",
+			[]string{"collector", "reason"}, prometheus.Labels{"cluster": cluster},
+		),
+	}
 }
 
 func (exporter *Exporter) initCollectors() map[string]versionedCollector {
@@ -77,6 +320,17 @@ func (exporter *Exporter) initCollectors() map[string]versionedCollector {
 		"mon":           NewMonitorCollector(exporter),
 		"osd":           NewOSDCollector(exporter),
 		"crashes":       NewCrashesCollector(exporter),
+		"mds":           NewMDSCollector(exporter),
+		"progress":      NewProgressCollector(exporter),
+		"poolAutoscale": NewPoolAutoscaleCollector(exporter),
+	}
+
+	if exporter.DeviceHealth {
+		standardCollectors["deviceHealth"] = NewDeviceHealthCollector(exporter)
+	}
+
+	if exporter.AuthMetrics {
+		standardCollectors["auth"] = NewAuthCollector(exporter)
 	}
 
 	switch exporter.RgwMode {
@@ -218,6 +472,12 @@ func (exporter *Exporter) setCephVersion() error {
 // Describe sends all the descriptors of the collectors included to
 // the provided channel.
 func (exporter *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- exporter.LastScrapeError
+	ch <- exporter.MonCommandsPerScrape
+	ch <- exporter.MgrCommandsPerScrape
+	ch <- exporter.Collectors
+	ch <- exporter.CollectorSkipped
+
 	err := exporter.setCephVersion()
 	if err != nil {
 		exporter.Logger.WithError(err).Error("failed to set ceph Version")
@@ -242,25 +502,102 @@ func (exporter *Exporter) Collect(ch chan<- prometheus.Metric) {
 	exporter.mu.Lock()
 	defer exporter.mu.Unlock()
 
+	// Sent last so that they include the errors and commands of this scrape.
+	defer exporter.collectLastScrapeError(ch)
+	defer exporter.collectCommandCounts(ch)
+	defer exporter.collectCollectorCounts(ch)
+
+	exporter.commands.resetCommandCounts()
+	exporter.pgDump.reset()
+
 	err := exporter.setCephVersion()
 	if err != nil {
+		exporter.recordError()
 		exporter.Logger.WithError(err).Error("failed to set ceph Version")
 		return
 	}
 
 	err = exporter.setRbdMirror()
 	if err != nil {
+		exporter.recordError()
 		exporter.Logger.WithError(err).Error("failed to set rbd mirror")
 		return
 	}
 
+	errs := &collectErrors{}
 	wg := &sync.WaitGroup{}
-	for _, cc := range exporter.cc {
+	for name, cc := range exporter.cc {
+		if exporter.skipForVersion(cc) {
+			exporter.Logger.WithField("collector", name).Debug("skipping collector not supported by the ceph version")
+			ch <- prometheus.MustNewConstMetric(exporter.CollectorSkipped, prometheus.GaugeValue, 1, name, "version")
+			continue
+		}
+
 		wg.Add(1)
 		go func(cc versionedCollector, wg *sync.WaitGroup) {
-			cc.Collect(ch, exporter.Version)
+			errs.add(cc.Collect(ch, exporter.Version))
 			wg.Done()
 		}(cc, wg)
 	}
 	wg.Wait()
+
+	if errs.err() != nil {
+		exporter.recordError()
+	}
+
+	for name, cc := range exporter.cc {
+		if pc, ok := cc.(partialCollector); ok {
+			for _, part := range pc.skippedParts() {
+				ch <- prometheus.MustNewConstMetric(exporter.CollectorSkipped, prometheus.GaugeValue, 1, name+"/"+part, "version")
+			}
+		}
+	}
+}
+
+// skipForVersion returns whether the collector doesn't support the version of
+// the cluster.
+func (exporter *Exporter) skipForVersion(cc versionedCollector) bool {
+	mv, ok := cc.(minVersionCollector)
+	if !ok {
+		return false
+	}
+
+	return !exporter.Version.IsAtLeast(mv.minVersion())
+}
+
+// recordError marks the current scrape as failed. It is only called by
+// Collect, under the exporter's mutex.
+func (exporter *Exporter) recordError() {
+	exporter.lastError = exporter.now()
+}
+
+// lastErrorTimestamp returns the unix time of the last failed scrape, or 0 if
+// there hasn't been one yet.
+func (exporter *Exporter) lastErrorTimestamp() float64 {
+	if exporter.lastError.IsZero() {
+		return 0
+	}
+	return float64(exporter.lastError.UnixNano()) / 1e9
+}
+
+func (exporter *Exporter) collectLastScrapeError(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(exporter.LastScrapeError, prometheus.GaugeValue, exporter.lastErrorTimestamp())
+}
+
+func (exporter *Exporter) collectCommandCounts(ch chan<- prometheus.Metric) {
+	mon, mgr := exporter.commands.commandCounts()
+	ch <- prometheus.MustNewConstMetric(exporter.MonCommandsPerScrape, prometheus.GaugeValue, float64(mon))
+	ch <- prometheus.MustNewConstMetric(exporter.MgrCommandsPerScrape, prometheus.GaugeValue, float64(mgr))
+}
+
+func (exporter *Exporter) collectCollectorCounts(ch chan<- prometheus.Metric) {
+	disabled := 0
+	for _, name := range optionalCollectors {
+		if _, ok := exporter.cc[name]; !ok {
+			disabled++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(exporter.Collectors, prometheus.GaugeValue, float64(len(exporter.cc)), "enabled")
+	ch <- prometheus.MustNewConstMetric(exporter.Collectors, prometheus.GaugeValue, float64(disabled), "disabled")
 }
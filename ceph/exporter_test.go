@@ -0,0 +1,421 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// pgDumpCollector is a minimal collector that issues a single mgr command and
+// parses its response.
+type pgDumpCollector struct {
+	conn Conn
+}
+
+func (c *pgDumpCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *pgDumpCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	buf, _, err := c.conn.MgrCommand([][]byte{[]byte(`{"prefix":"pg dump","format":"json"}`)})
+	if err != nil {
+		return err
+	}
+
+	var dump map[string]interface{}
+	return json.Unmarshal(buf, &dump)
+}
+
+func TestExporterLastScrapeError(t *testing.T) {
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+
+	var (
+		fail     bool
+		response string
+	)
+	conn.On("MgrCommand", mock.Anything).Return(func([][]byte) []byte {
+		return []byte(response)
+	}, "", func([][]byte) error {
+		if fail {
+			return errors.New("timed out")
+		}
+		return nil
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{User: "admin"}, logrus.New())
+	e.cc = map[string]versionedCollector{"pgDump": &pgDumpCollector{conn: e.Conn}}
+
+	now := time.Unix(1700000000, 0)
+	e.now = func() time.Time { return now }
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name     string
+		fail     bool
+		response string
+		reMatch  *regexp.Regexp
+	}{
+		{
+			name:     "no errors yet",
+			fail:     false,
+			response: "{}",
+			reMatch:  regexp.MustCompile(`ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 0`),
+		},
+		{
+			name:     "failed scrape",
+			fail:     true,
+			response: "{}",
+			reMatch:  regexp.MustCompile(`ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 1.7000108e\+09`),
+		},
+		{
+			name:     "recovered scrape keeps the last error",
+			fail:     false,
+			response: "{}",
+			reMatch:  regexp.MustCompile(`ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 1.7000108e\+09`),
+		},
+		{
+			name:     "unparsable response",
+			fail:     false,
+			response: "{",
+			reMatch:  regexp.MustCompile(`ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 1.7000324e\+09`),
+		},
+		{
+			name:     "failed again",
+			fail:     true,
+			response: "{}",
+			reMatch:  regexp.MustCompile(`ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 1.7000432e\+09`),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fail = tt.fail
+			response = tt.response
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.True(t, tt.reMatch.Match(buf), "expected %s to match", tt.reMatch.String())
+		})
+
+		now = now.Add(3 * time.Hour)
+	}
+}
+
+// chattyCollector issues a fixed number of mon and mgr commands.
+type chattyCollector struct {
+	conn     Conn
+	mon, mgr int
+}
+
+func (c *chattyCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *chattyCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	for i := 0; i < c.mon; i++ {
+		_, _, _ = c.conn.MonCommand([]byte(`{"prefix":"status","format":"json"}`))
+	}
+	for i := 0; i < c.mgr; i++ {
+		_, _, _ = c.conn.MgrCommand([][]byte{[]byte(`{"prefix":"pg dump","format":"json"}`)})
+	}
+	return nil
+}
+
+func TestExporterCommandsPerScrape(t *testing.T) {
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+	conn.On("MonCommand", mock.Anything).Return([]byte("{}"), "", nil)
+	conn.On("MgrCommand", mock.Anything).Return([]byte("{}"), "", nil)
+
+	// NewExporter would start the OSD collector's background PG dumps, which
+	// would be counted below as calls to the mock, so only the collector
+	// under test is set up here.
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	e.cc = map[string]versionedCollector{"chatty": &chattyCollector{conn: e.Conn, mon: 3, mgr: 2}}
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	// Counts every call made to the mock so far, to compare the counts of
+	// a scrape against.
+	calls := func(method string) int {
+		n := 0
+		for _, call := range conn.Calls {
+			if call.Method == method {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Scrape twice to check the counts are per scrape, not cumulative.
+	for i := 0; i < 2; i++ {
+		monBefore, mgrBefore := calls("MonCommand"), calls("MgrCommand")
+
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+
+		buf, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		// version and versions are sent on top of the collector's commands.
+		mon, mgr := calls("MonCommand")-monBefore, calls("MgrCommand")-mgrBefore
+		require.Equal(t, 5, mon)
+		require.Equal(t, 2, mgr)
+
+		require.Regexp(t, fmt.Sprintf(`ceph_exporter_mon_commands_per_scrape{cluster="ceph"} %d`, mon), string(buf))
+		require.Regexp(t, fmt.Sprintf(`ceph_exporter_mgr_commands_per_scrape{cluster="ceph"} %d`, mgr), string(buf))
+	}
+}
+
+func TestExporterBackgroundCommandsNotCounted(t *testing.T) {
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+
+	dumped := make(chan struct{}, 1)
+	conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+		"prefix":       "pg dump",
+		"dumpcontents": []interface{}{"pgs_brief"},
+		"format":       "json",
+	}))).Return(func([][]byte) []byte {
+		select {
+		case dumped <- struct{}{}:
+		default:
+		}
+		return nil
+	}, "", errors.New("timed out"))
+
+	e, err := NewExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	require.NoError(t, err)
+	defer e.Close()
+
+	select {
+	case <-dumped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no background PG dump")
+	}
+
+	// The PG dump of the OSD collector's background loop isn't counted, only
+	// the version probe of NewExporter is.
+	mon, mgr := e.commands.commandCounts()
+	require.Equal(t, 1, mon)
+	require.Equal(t, 0, mgr)
+}
+
+func TestExporterCollectors(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		collectors []string
+		enabled    int
+		disabled   int
+	}{
+		{
+			name:       "optional collectors disabled",
+			collectors: []string{"mon", "osd"},
+			enabled:    2,
+			disabled:   4,
+		},
+		{
+			name:       "rgw enabled",
+			collectors: []string{"mon", "osd", "rgw"},
+			enabled:    3,
+			disabled:   3,
+		},
+		{
+			name:       "device health enabled",
+			collectors: []string{"mon", "osd", "deviceHealth"},
+			enabled:    3,
+			disabled:   3,
+		},
+		{
+			name:       "auth enabled",
+			collectors: []string{"mon", "osd", "auth"},
+			enabled:    3,
+			disabled:   3,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+			e.cc = make(map[string]versionedCollector)
+			for _, name := range tt.collectors {
+				e.cc[name] = &noopCollector{}
+			}
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(e))
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Regexp(t, fmt.Sprintf(`ceph_exporter_collectors{cluster="ceph",state="enabled"} %d`, tt.enabled), string(buf))
+			require.Regexp(t, fmt.Sprintf(`ceph_exporter_collectors{cluster="ceph",state="disabled"} %d`, tt.disabled), string(buf))
+		})
+	}
+}
+
+func TestExporterCollectorSkipped(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		version string
+		skipped bool
+	}{
+		{
+			name:    "luminous",
+			version: `{"version":"ceph version 12.2.13 (584a20eb0237c657dc0567da126be145106aa47e) luminous (stable)"}`,
+			skipped: true,
+		},
+		{
+			name:    "pacific",
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			skipped: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(tt.version, "{}")
+			conn.On("MgrCommand", mock.Anything).Return([]byte(`{"events": [], "completed": []}`), "", nil)
+
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+			e.cc = map[string]versionedCollector{
+				"progress": NewProgressCollector(e),
+				"mon":      &noopCollector{},
+				"gated":    &gatedCollector{},
+			}
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(e))
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			skipped := regexp.MustCompile(`ceph_exporter_collector_skipped{cluster="ceph",collector="progress",reason="version"} 1`)
+			require.Equal(t, tt.skipped, skipped.Match(buf), "got:\n%s", buf)
+			require.NotContains(t, string(buf), `collector="mon"`)
+
+			// Only the part of the collector is skipped, not all of it.
+			skippedPart := regexp.MustCompile(`ceph_exporter_collector_skipped{cluster="ceph",collector="gated/pacific",reason="version"} 1`)
+			require.Equal(t, tt.skipped, skippedPart.Match(buf), "got:\n%s", buf)
+			require.NotContains(t, string(buf), `collector="gated"`)
+
+			if tt.skipped {
+				conn.AssertNotCalled(t, "MgrCommand", mock.Anything)
+			}
+		})
+	}
+}
+
+// gatedCollector is a collector with a part that needs Pacific.
+type gatedCollector struct {
+	versionGates
+}
+
+func (c *gatedCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *gatedCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	c.versionAtLeast(version, Pacific, "pacific")
+	return nil
+}
+
+// noopCollector is a minimal collector without any metrics.
+type noopCollector struct{}
+
+func (c *noopCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *noopCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	return nil
+}
+
+// descCollector is a collector declaring a single metric.
+type descCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c *descCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *descCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	return nil
+}
+
+func TestExporterDuplicateDescs(t *testing.T) {
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+	conn.On("MonCommand", mock.Anything).Return([]byte("{}"), "", nil)
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	e.cc = map[string]versionedCollector{
+		"first":  &descCollector{desc: prometheus.NewDesc("ceph_conflicting_metric", "first", nil, nil)},
+		"second": &descCollector{desc: prometheus.NewDesc("ceph_conflicting_metric", "second", nil, nil)},
+	}
+
+	// Two collectors declaring the same metric fail the registration, naming
+	// the metric.
+	err := prometheus.NewRegistry().Register(e)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"ceph_conflicting_metric"`)
+}
+
+func TestNewExporterNoDuplicateDescs(t *testing.T) {
+	// Enable every optional collector, registration fails if any two of them
+	// declare the same metric.
+	e, err := NewExporter(NewFixtureConn("testdata/fixture"), "ceph", ExporterOptions{
+		User:         "admin",
+		RgwMode:      RGWModeForeground,
+		OSDOpQueue:   true,
+		OSDPerfDump:  true,
+		DeviceHealth: true,
+		AuthMetrics:  true,
+		PGQuery:      true,
+	}, logrus.New())
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, prometheus.NewRegistry().Register(e))
+}
@@ -0,0 +1,135 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FixtureConn is a Conn that answers commands with responses recorded from a
+// real cluster, so that the exporter can be run end-to-end without one, e.g.
+// in CI, for demos or to reproduce parsing bugs from a user's captured output.
+//
+// Responses are read from JSON files in a single directory, named after the
+// command's prefix with spaces replaced by underscores, e.g. osd_tree.json.
+// Commands that take further arguments first look for a file named after the
+// prefix followed by the argument values in order of their names, e.g.
+// pg_dump_pgs_brief.json or osd_tree_down.json, and commands sent to an OSD
+// first look for one prefixed with the OSD, e.g. osd.3_perf_dump.json.
+type FixtureConn struct {
+	dir string
+}
+
+// NewFixtureConn returns a FixtureConn that reads its responses from dir.
+func NewFixtureConn(dir string) *FixtureConn {
+	return &FixtureConn{dir: dir}
+}
+
+// MonCommand returns the recorded response to the command.
+func (f *FixtureConn) MonCommand(args []byte) ([]byte, string, error) {
+	return f.read(fixtureNames(args))
+}
+
+// MgrCommand returns the recorded response to the command.
+func (f *FixtureConn) MgrCommand(args [][]byte) ([]byte, string, error) {
+	return f.read(fixtureNames(firstArg(args)))
+}
+
+// OsdCommand returns the recorded response of the given OSD to the command,
+// falling back to a response shared by all OSDs.
+func (f *FixtureConn) OsdCommand(osd int, args [][]byte) ([]byte, string, error) {
+	names := fixtureNames(firstArg(args))
+
+	osdNames := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		osdNames = append(osdNames, fmt.Sprintf("osd.%d_%s", osd, name))
+	}
+
+	return f.read(append(osdNames, names...))
+}
+
+// GetPoolStats always fails, librados stats aren't recorded, so the metrics
+// read from them are missing rather than reported as 0.
+func (f *FixtureConn) GetPoolStats(pool string) (*PoolStat, error) {
+	return nil, fmt.Errorf("pool stats of %s aren't recorded in %s", pool, f.dir)
+}
+
+// read returns the contents of the first of the named fixtures that exists.
+func (f *FixtureConn) read(names []string) ([]byte, string, error) {
+	for _, name := range names {
+		buf, err := ioutil.ReadFile(filepath.Join(f.dir, name+".json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		return buf, "", nil
+	}
+
+	return nil, "", fmt.Errorf("no fixture %s.json in %s", names[len(names)-1], f.dir)
+}
+
+// fixtureNames returns the names of the fixtures that can answer a JSON
+// encoded command, the most specific first.
+func fixtureNames(args []byte) []string {
+	cmd := map[string]interface{}{}
+	_ = json.Unmarshal(args, &cmd)
+
+	prefix, _ := cmd["prefix"].(string)
+	if prefix == "" {
+		prefix = "unknown"
+	}
+
+	keys := make([]string, 0, len(cmd))
+	for k := range cmd {
+		if k != "prefix" && k != "format" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := []string{prefix}
+	for _, k := range keys {
+		switch v := cmd[k].(type) {
+		case string:
+			parts = append(parts, v)
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+		}
+	}
+
+	names := []string{fixtureName(parts...)}
+	if len(parts) > 1 {
+		names = append(names, fixtureName(prefix))
+	}
+
+	return names
+}
+
+func fixtureName(parts ...string) string {
+	return strings.NewReplacer(" ", "_", "/", "_").Replace(strings.Join(parts, "_"))
+}
@@ -0,0 +1,169 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtureNames(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		expect []string
+	}{
+		{
+			input:  `{"prefix":"osd tree","format":"json"}`,
+			expect: []string{"osd_tree"},
+		},
+		{
+			input:  `{"prefix":"osd tree","states":["down"],"format":"json"}`,
+			expect: []string{"osd_tree_down", "osd_tree"},
+		},
+		{
+			input:  `{"prefix":"pg dump","dumpcontents":["pgs_brief"],"format":"json"}`,
+			expect: []string{"pg_dump_pgs_brief", "pg_dump"},
+		},
+		{
+			input:  `{"prefix":"config get","who":"osd","key":"osd_max_backfills","format":"json"}`,
+			expect: []string{"config_get_osd_max_backfills_osd", "config_get"},
+		},
+		{
+			input:  `not json`,
+			expect: []string{"unknown"},
+		},
+	} {
+		require.Equal(t, tt.expect, fixtureNames([]byte(tt.input)), tt.input)
+	}
+}
+
+func TestFixtureConnOsdCommand(t *testing.T) {
+	conn := NewFixtureConn("testdata/fixture")
+
+	_, _, err := conn.OsdCommand(0, [][]byte{[]byte(`{"prefix":"perf dump","format":"json"}`)})
+	require.EqualError(t, err, "no fixture perf_dump.json in testdata/fixture")
+
+	buf, _, err := conn.MonCommand([]byte(`{"prefix":"version","format":"json"}`))
+	require.NoError(t, err)
+	require.Contains(t, string(buf), "pacific")
+}
+
+func TestExporterFixtureBackend(t *testing.T) {
+	e, err := NewExporter(NewFixtureConn("testdata/fixture"), "ceph", ExporterOptions{User: "admin", AuthMetrics: true}, logrus.New())
+	require.NoError(t, err)
+	defer e.Close()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, re := range []*regexp.Regexp{
+		// every command was answered by a fixture
+		regexp.MustCompile(`ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 0`),
+
+		regexp.MustCompile(`ceph_health_status{cluster="ceph"} 0`),
+		regexp.MustCompile(`ceph_cluster_capacity_bytes{cluster="ceph"} 5.9972050944e\+12`),
+		regexp.MustCompile(`ceph_pool_used_bytes{cluster="ceph",pool="rbd"} 5.0331648e\+09`),
+		regexp.MustCompile(`ceph_pool_read_total{cluster="ceph",pool="rbd"} 52000`),
+		regexp.MustCompile(`ceph_pool_write_total{cluster="ceph",pool="rbd"} 310000`),
+		regexp.MustCompile(`ceph_pool_num_objects_unfound{cluster="ceph",pool="rbd"} 0`),
+		regexp.MustCompile(`ceph_pool_num_objects_unfound{cluster="ceph",pool="cephfs.cephfs.data"} 2`),
+		regexp.MustCompile(`ceph_pool_size{cluster="ceph",pool="rbd",profile="replicated",root="default"} 3`),
+		regexp.MustCompile(`ceph_pool_read_balance_score{cluster="ceph",pool="rbd",profile="replicated",root="default"} 1.5`),
+		regexp.MustCompile(`ceph_pool_info{cluster="ceph",crush_rule="replicated_rule",erasure_profile="",k="",m="",min_size="2",pool="rbd",size="3"} 1`),
+		regexp.MustCompile(`ceph_monitor_quorum_count{cluster="ceph"} 3`),
+		regexp.MustCompile(`ceph_mons_total{cluster="ceph"} 3`),
+		regexp.MustCompile(`ceph_mon_in_quorum{cluster="ceph",monitor="b"} 1`),
+		regexp.MustCompile(`ceph_mon_quorum_age_seconds{cluster="ceph"} 86400`),
+		regexp.MustCompile(`ceph_cluster_info{cluster="ceph",fsid="8e3b2d3c-6a1e-4a4e-9f0a-1c2b3d4e5f60",leader="a"} 1`),
+		regexp.MustCompile(`ceph_osd_up{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.2",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_primary_affinity{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.2",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_crush_weight_set{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.0",rack="",root="default",weight_set="compat"} 1.75`),
+		regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.2"} 0`),
+		regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="ssd",ceph_version="ceph version 16.2.11 \(3cf40e2dca667f68c6ce3ff5cd94f01e711af894\) pacific \(stable\)",ceph_version_when_created="ceph version 16.2.11 \(3cf40e2dca667f68c6ce3ff5cd94f01e711af894\) pacific \(stable\)",cluster="ceph",created_at="2023-01-10T10:00:00.000000Z",db_device="",device_class="ssd",objectstore="bluestore",osd="0",wal_device=""} 1`),
+		regexp.MustCompile(`ceph_osd_class_nearfull_count{cluster="ceph",device_class="ssd"} 1`),
+		// one of the 8 PGs is backfilling
+		regexp.MustCompile(`ceph_osd_healthy_pgs{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.0",rack="",root="default"} 7`),
+		regexp.MustCompile(`ceph_osd_primary_pgs{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.0",rack="",root="default"} 3`),
+		// 1.5 is backfilling to osd.0
+		regexp.MustCompile(`ceph_osd_backfilling_pgs{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.0",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_backfilling_pgs{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.2",rack="",root="default"} 0`),
+		regexp.MustCompile(`ceph_pool_pgs_by_state{cluster="ceph",pool_id="1",state="active\+clean"} 5`),
+		regexp.MustCompile(`ceph_osd_snaptrimming_pgs{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.1",rack="",root="default"} 2`),
+		// one PG backfilling out of osd_max_backfills 1 times 3 in OSDs
+		regexp.MustCompile(`ceph_cluster_recovery_throttle_headroom{cluster="ceph"} 0.6666666666666667`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_max_backfills"} 1`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_op_complaint_time"} 30`),
+		regexp.MustCompile(`ceph_auth_entities_total{cluster="ceph"} 4`),
+		regexp.MustCompile(`ceph_mds_standby_count{cluster="ceph",fs="cephfs"} 0`),
+		// rbd has 8 PGs, the autoscaler wants 32
+		regexp.MustCompile(`ceph_pool_autoscale_pg_num_recommended{cluster="ceph",pool="rbd"} 32`),
+		regexp.MustCompile(`ceph_pool_autoscale_mode{cluster="ceph",mode="warn",pool="cephfs.cephfs.data"} 1`),
+		regexp.MustCompile(`ceph_progress_event{cluster="ceph",id="5c8b1a9e-3f2d-4c6a-9b1e-7d4f2a6c8e10",message="Rebalancing after osd.2 marked in"} 0.42`),
+		regexp.MustCompile(`ceph_crash_reports{cluster="ceph",entity="osd.1",hostname="ceph-node01",status="archived"} 1`),
+	} {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+
+	// librados stats aren't recorded, so they aren't made up either.
+	require.NotRegexp(t, `ceph_pool_unfound_objects_total{`, string(buf))
+}
+
+func TestExporterFixtureBackendPerfDump(t *testing.T) {
+	e, err := NewExporter(NewFixtureConn("testdata/fixture"), "ceph", ExporterOptions{User: "admin", OSDPerfDump: true}, logrus.New())
+	require.NoError(t, err)
+	defer e.Close()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	// Only osd.1 has a perf dump fixture.
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_bluestore_allocated_bytes{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.1",rack="",root="default"} 1.6777216e\+09`),
+		regexp.MustCompile(`ceph_osd_bluestore_stored_bytes{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.1",rack="",root="default"} 1.048576e\+09`),
+		regexp.MustCompile(`ceph_osd_op_read_latency_seconds_sum{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.1",rack="",root="default"} 310.478`),
+		regexp.MustCompile(`ceph_osd_op_read_latency_seconds_count{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.1",rack="",root="default"} 182634`),
+	} {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+	require.NotContains(t, string(buf), `ceph_osd_bluestore_allocated_bytes{cluster="ceph",device_class="ssd",host="ceph-node01",osd="osd.0"`)
+}
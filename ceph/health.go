@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -51,7 +52,10 @@ type ClusterHealthCollector struct {
 	conn   Conn
 	logger *logrus.Logger
 
-	// healthChecksMap stores warnings and their criticality
+	versionGates
+
+	// healthChecksMap stores warnings and their criticality, the built-in
+	// values merged with the cluster's health_check_severity.
 	healthChecksMap map[string]int
 
 	// HealthStatus shows the overall health status of a given cluster.
@@ -62,12 +66,54 @@ type ClusterHealthCollector struct {
 	// based on criticality.
 	HealthStatusInterpreter prometheus.Gauge
 
+	// HealthCheckActive shows which health checks are currently failing,
+	// labelled with the severity Ceph reports for them.
+	HealthCheckActive *prometheus.Desc
+
+	// HealthChecks counts the health checks that are currently failing, by
+	// the severity Ceph reports for them.
+	HealthChecks *prometheus.Desc
+
 	// MONsDown show the no. of Monitor that are int DOWN state
 	MONsDown *prometheus.Desc
 
+	// MONsTotal shows the no. of Monitors in the monmap, so that a Mon out
+	// of quorum shows even while the quorum still has a majority.
+	MONsTotal *prometheus.Desc
+
+	// MONsQuorum shows the no. of Monitors in quorum.
+	MONsQuorum *prometheus.Desc
+
+	// MONQuorumAge shows how long the current quorum has been formed.
+	MONQuorumAge *prometheus.Desc
+
+	// ClusterInfo identifies the cluster by its fsid and shows the current
+	// quorum leader, the value is always 1.
+	ClusterInfo *prometheus.Desc
+
+	// MONElectionEpoch shows the epoch of the last Mon election, a rapidly
+	// increasing epoch means elections are being called over and over.
+	MONElectionEpoch *prometheus.Desc
+
+	// MONClockSkew shows by how much each monitor's clock is skewed, taken
+	// from the MON_CLOCK_SKEW health check detail.
+	MONClockSkew *prometheus.Desc
+
+	// DaemonsOldVersion shows how many daemons run an older version of Ceph
+	// than the rest of the cluster, as reported by DAEMON_OLD_VERSION.
+	DaemonsOldVersion *prometheus.Desc
+
+	// DaemonVersions breaks DaemonsOldVersion down by the old version the
+	// daemons run, to follow a rolling upgrade.
+	DaemonVersions *prometheus.Desc
+
 	// TotalPGs shows the total no. of PGs the cluster constitutes of.
 	TotalPGs *prometheus.Desc
 
+	// CleanPGsRatio shows the fraction of PGs that are active+clean, i.e.
+	// how much of the data is fully healthy.
+	CleanPGsRatio *prometheus.Desc
+
 	// PGstate contains state of all PGs labelled with the name of states.
 	PGState *prometheus.Desc
 
@@ -162,13 +208,26 @@ type ClusterHealthCollector struct {
 	// RepairingPGs depicts no. of PGs that are currently repairing
 	RepairingPGs *prometheus.Desc
 
+	// CreatingPGs depicts no. of PGs that are still being created, e.g.
+	// right after a pool was created or its pg_num was raised.
+	CreatingPGs *prometheus.Desc
+
+	// ActivatingPGs depicts no. of PGs that have peered but are not yet active.
+	ActivatingPGs *prometheus.Desc
+
 	// SlowOps depicts no. of total slow ops in the cluster
 	SlowOps *prometheus.Desc
 
+	// DaemonSlowOps flags the daemons that SLOW_OPS names as having slow ops.
+	DaemonSlowOps *prometheus.Desc
+
 	// DegradedObjectsCount gives the no. of RADOS objects are constitute the degraded PGs.
 	// This includes object replicas in its count.
 	DegradedObjectsCount *prometheus.Desc
 
+	// DegradedRatio shows the ratio of degraded object copies to all object copies
+	DegradedRatio *prometheus.Desc
+
 	// MisplacedObjectsCount gives the no. of RADOS objects that constitute the misplaced PGs.
 	// Misplaced PGs usually represent the PGs that are not in the storage locations that
 	// they should be in. This is different than degraded PGs which means a PG has fewer copies
@@ -185,9 +244,25 @@ type ClusterHealthCollector struct {
 	// TooManyRepairs reports the number of OSDs exceeding mon_osd_warn_num_repaired
 	TooManyRepairs *prometheus.Desc
 
+	// PGsNotScrubbed and PGsNotDeepScrubbed report the number of PGs that
+	// haven't been (deep) scrubbed within the configured interval.
+	PGsNotScrubbed     *prometheus.Desc
+	PGsNotDeepScrubbed *prometheus.Desc
+
 	// Objects show the total no. of RADOS objects that are currently allocated
 	Objects *prometheus.Desc
 
+	// TotalBytes, DataBytes, RawUsedBytes and RawAvailableBytes show the
+	// cluster's capacity as summarized in the pgmap, so that it is available
+	// without the cluster usage collector's `ceph df`.
+	TotalBytes        *prometheus.Desc
+	DataBytes         *prometheus.Desc
+	RawUsedBytes      *prometheus.Desc
+	RawAvailableBytes *prometheus.Desc
+
+	// Pools shows the no. of pools in the cluster.
+	Pools *prometheus.Desc
+
 	// OSDMapFlags - **these are being deprecated in favor of using the OSDMapFlags ConstMetrics descriptor**
 	OSDMapFlagFull        prometheus.Gauge
 	OSDMapFlagPauseRd     prometheus.Gauge
@@ -234,6 +309,15 @@ type ClusterHealthCollector struct {
 	// RecoveryIOObjects shows the rate of rados objects being recovered.
 	RecoveryIOObjects *prometheus.Desc
 
+	// RecoveryObjectsLeft shows the degraded and misplaced object
+	// copies left to recover, which over RecoveryIOObjects gives an ETA.
+	RecoveryObjectsLeft *prometheus.Desc
+
+	// RecoveryThrottled flags degraded PGs that are not recovering because
+	// recovery or backfill is blocked by the norecover/nobackfill flags or
+	// by full OSDs.
+	RecoveryThrottled *prometheus.Desc
+
 	// ClientReadBytesPerSec shows the total client read i/o on the cluster.
 	ClientReadBytesPerSec *prometheus.Desc
 
@@ -264,8 +348,16 @@ type ClusterHealthCollector struct {
 	// MgrsNum shows the total number of mgrs, including standbys.
 	MgrsNum *prometheus.Desc
 
+	// MgrMultipleActive flags a mgrmap in which more than one mgr claims to
+	// be active, which can briefly happen during a botched failover.
+	MgrMultipleActive *prometheus.Desc
+
 	// RbdMirrorUp shows the alive rbd-mirror daemons
 	RbdMirrorUp *prometheus.Desc
+
+	// ServiceDaemon shows the daemons registered in the service map, e.g.
+	// rgw, nfs, iscsi or rbd-mirror, labelled by service and daemon id.
+	ServiceDaemon *prometheus.Desc
 }
 
 const (
@@ -376,8 +468,19 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 				ConstLabels: labels,
 			},
 		),
+		HealthCheckActive: prometheus.NewDesc(fmt.Sprintf("%s_health_check_active", cephNamespace), "Health checks that are currently failing, with the severity reported by Ceph", []string{"name", "severity"}, labels),
+		HealthChecks:      prometheus.NewDesc(fmt.Sprintf("%s_health_checks_total", cephNamespace), "Number of health checks that are currently failing, by severity", []string{"severity"}, labels),
 		MONsDown:          prometheus.NewDesc(fmt.Sprintf("%s_mons_down", cephNamespace), "Count of Mons that are in DOWN state", nil, labels),
+		MONsTotal:         prometheus.NewDesc(fmt.Sprintf("%s_mons_total", cephNamespace), "Count of Mons in the monmap", nil, labels),
+		MONsQuorum:        prometheus.NewDesc(fmt.Sprintf("%s_mons_quorum", cephNamespace), "Count of Mons that are in quorum", nil, labels),
+		MONQuorumAge:      prometheus.NewDesc(fmt.Sprintf("%s_mon_quorum_age_seconds", cephNamespace), "Time since the current Mon quorum was formed", nil, labels),
+		ClusterInfo:       prometheus.NewDesc(fmt.Sprintf("%s_cluster_info", cephNamespace), "Cluster fsid and Mon quorum leader, the value is always 1", []string{"fsid", "leader"}, labels),
+		MONElectionEpoch:  prometheus.NewDesc(fmt.Sprintf("%s_mon_election_epoch", cephNamespace), "Epoch of the last Mon election", nil, labels),
+		MONClockSkew:      prometheus.NewDesc(fmt.Sprintf("%s_mon_clock_skew_seconds", cephNamespace), "Magnitude of the clock skew of a Mon as reported by the MON_CLOCK_SKEW health check", []string{"mon"}, labels),
+		DaemonsOldVersion: prometheus.NewDesc(fmt.Sprintf("%s_daemons_old_version", cephNamespace), "Number of daemons running an older version of Ceph as reported by the DAEMON_OLD_VERSION health check", nil, labels),
+		DaemonVersions:    prometheus.NewDesc(fmt.Sprintf("%s_daemon_versions", cephNamespace), "Number of daemons running an older version of Ceph by version, as reported by the DAEMON_OLD_VERSION health check", []string{"version"}, labels),
 		TotalPGs:          prometheus.NewDesc(fmt.Sprintf("%s_total_pgs", cephNamespace), "Total no. of PGs in the cluster", nil, labels),
+		CleanPGsRatio:     prometheus.NewDesc(fmt.Sprintf("%s_pgs_clean_ratio", cephNamespace), "Ratio of active+clean PGs to total PGs in the cluster", nil, labels),
 		PGState:           prometheus.NewDesc(fmt.Sprintf("%s_pg_state", cephNamespace), "State of PGs in the cluster", []string{"state"}, labels),
 		ActivePGs:         prometheus.NewDesc(fmt.Sprintf("%s_active_pgs", cephNamespace), "No. of active PGs in the cluster", nil, labels),
 		ScrubbingPGs:      prometheus.NewDesc(fmt.Sprintf("%s_scrubbing_pgs", cephNamespace), "No. of scrubbing PGs in the cluster", nil, labels),
@@ -394,10 +497,13 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		SnaptrimPGs:       prometheus.NewDesc(fmt.Sprintf("%s_snaptrim_pgs", cephNamespace), "No. of snaptrim PGs in the cluster", nil, labels),
 		SnaptrimWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_snaptrim_wait_pgs", cephNamespace), "No. of PGs in the cluster with snaptrim_wait state", nil, labels),
 		RepairingPGs:      prometheus.NewDesc(fmt.Sprintf("%s_repairing_pgs", cephNamespace), "No. of PGs in the cluster with repair state", nil, labels),
+		CreatingPGs:       prometheus.NewDesc(fmt.Sprintf("%s_creating_pgs", cephNamespace), "No. of PGs in the cluster with creating state", nil, labels),
+		ActivatingPGs:     prometheus.NewDesc(fmt.Sprintf("%s_activating_pgs", cephNamespace), "No. of PGs in the cluster with activating state", nil, labels),
 		// with Nautilus, SLOW_OPS has replaced both REQUEST_SLOW and REQUEST_STUCK
 		// therefore slow_requests is deprecated, but for backwards compatibility
 		// the metric name will be kept the same for the time being
 		SlowOps:               prometheus.NewDesc(fmt.Sprintf("%s_slow_requests", cephNamespace), "No. of slow requests/slow ops", nil, labels),
+		DaemonSlowOps:         prometheus.NewDesc(fmt.Sprintf("%s_daemon_slow_ops", cephNamespace), "Daemons that have slow ops, as named by the SLOW_OPS health check", []string{"daemon"}, labels),
 		DegradedPGs:           prometheus.NewDesc(fmt.Sprintf("%s_degraded_pgs", cephNamespace), "No. of PGs in a degraded state", nil, labels),
 		StuckDegradedPGs:      prometheus.NewDesc(fmt.Sprintf("%s_stuck_degraded_pgs", cephNamespace), "No. of PGs stuck in a degraded state", nil, labels),
 		UncleanPGs:            prometheus.NewDesc(fmt.Sprintf("%s_unclean_pgs", cephNamespace), "No. of PGs in an unclean state", nil, labels),
@@ -410,9 +516,17 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		DegradedObjectsCount:  prometheus.NewDesc(fmt.Sprintf("%s_degraded_objects", cephNamespace), "No. of degraded objects across all PGs, includes replicas", nil, labels),
 		MisplacedObjectsCount: prometheus.NewDesc(fmt.Sprintf("%s_misplaced_objects", cephNamespace), "No. of misplaced objects across all PGs, includes replicas", nil, labels),
 		MisplacedRatio:        prometheus.NewDesc(fmt.Sprintf("%s_misplaced_ratio", cephNamespace), "ratio of misplaced objects to total objects", nil, labels),
+		DegradedRatio:         prometheus.NewDesc(fmt.Sprintf("%s_degraded_total_ratio", cephNamespace), "ratio of degraded object copies to total object copies", nil, labels),
 		NewCrashReportCount:   prometheus.NewDesc(fmt.Sprintf("%s_new_crash_reports", cephNamespace), "Number of new crash reports available", nil, labels),
 		TooManyRepairs:        prometheus.NewDesc(fmt.Sprintf("%s_osds_too_many_repair", cephNamespace), "Number of OSDs with too many repaired reads", nil, labels),
+		PGsNotScrubbed:        prometheus.NewDesc(fmt.Sprintf("%s_pgs_not_scrubbed", cephNamespace), "Number of PGs not scrubbed in time", nil, labels),
+		PGsNotDeepScrubbed:    prometheus.NewDesc(fmt.Sprintf("%s_pgs_not_deep_scrubbed", cephNamespace), "Number of PGs not deep-scrubbed in time", nil, labels),
 		Objects:               prometheus.NewDesc(fmt.Sprintf("%s_cluster_objects", cephNamespace), "No. of rados objects within the cluster", nil, labels),
+		TotalBytes:            prometheus.NewDesc(fmt.Sprintf("%s_cluster_total_bytes", cephNamespace), "Raw capacity of the cluster as reported in the pgmap", nil, labels),
+		DataBytes:             prometheus.NewDesc(fmt.Sprintf("%s_cluster_data_bytes", cephNamespace), "Bytes of data stored in the cluster, excluding replicas", nil, labels),
+		RawUsedBytes:          prometheus.NewDesc(fmt.Sprintf("%s_cluster_raw_used_bytes", cephNamespace), "Raw capacity used in the cluster as reported in the pgmap", nil, labels),
+		RawAvailableBytes:     prometheus.NewDesc(fmt.Sprintf("%s_cluster_raw_available_bytes", cephNamespace), "Raw capacity available in the cluster as reported in the pgmap", nil, labels),
+		Pools:                 prometheus.NewDesc(fmt.Sprintf("%s_cluster_pools", cephNamespace), "No. of pools in the cluster", nil, labels),
 		OSDMapFlagFull: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -527,6 +641,8 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		RecoveryIORate:         prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_bytes", cephNamespace), "Rate of bytes being recovered in cluster per second", nil, labels),
 		RecoveryIOKeys:         prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_keys", cephNamespace), "Rate of keys being recovered in cluster per second", nil, labels),
 		RecoveryIOObjects:      prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_objects", cephNamespace), "Rate of objects being recovered in cluster per second", nil, labels),
+		RecoveryObjectsLeft:    prometheus.NewDesc(fmt.Sprintf("%s_recovery_objects_remaining", cephNamespace), "No. of degraded and misplaced object copies left to recover or move", nil, labels),
+		RecoveryThrottled:      prometheus.NewDesc(fmt.Sprintf("%s_recovery_throttled", cephNamespace), "Whether degraded PGs are not recovering because recovery is blocked by flags or full OSDs", nil, labels),
 		ClientReadBytesPerSec:  prometheus.NewDesc(fmt.Sprintf("%s_client_io_read_bytes", cephNamespace), "Rate of bytes being read by all clients per second", nil, labels),
 		ClientWriteBytesPerSec: prometheus.NewDesc(fmt.Sprintf("%s_client_io_write_bytes", cephNamespace), "Rate of bytes being written by all clients per second", nil, labels),
 		ClientIOOps:            prometheus.NewDesc(fmt.Sprintf("%s_client_io_ops", cephNamespace), "Total client ops on the cluster measured per second", nil, labels),
@@ -537,7 +653,9 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		CachePromoteIOOps:      prometheus.NewDesc(fmt.Sprintf("%s_cache_promote_io_ops", cephNamespace), "Total cache promote operations measured per second", nil, labels),
 		MgrsActive:             prometheus.NewDesc(fmt.Sprintf("%s_mgrs_active", cephNamespace), "Count of active mgrs, can be either 0 or 1", nil, labels),
 		MgrsNum:                prometheus.NewDesc(fmt.Sprintf("%s_mgrs", cephNamespace), "Total number of mgrs, including standbys", nil, labels),
+		MgrMultipleActive:      prometheus.NewDesc(fmt.Sprintf("%s_mgr_multiple_active", cephNamespace), "Whether more than one mgr claims to be active", nil, labels),
 		RbdMirrorUp:            prometheus.NewDesc(fmt.Sprintf("%s_rbd_mirror_up", cephNamespace), "Alive rbd-mirror daemons", []string{"name"}, labels),
+		ServiceDaemon:          prometheus.NewDesc(fmt.Sprintf("%s_service_daemon", cephNamespace), "Daemons registered in the service map", []string{"service", "id"}, labels),
 	}
 
 	// This is here to support backwards compatibility with gauges, but also exists as a general list of possible flags
@@ -557,6 +675,10 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		"notieragent":  &collector.OSDMapFlagNoTierAgent,
 	}
 
+	for check, severity := range exporter.HealthCheckSeverity {
+		collector.healthChecksMap[check] = severity
+	}
+
 	return collector
 }
 
@@ -585,8 +707,19 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 	return []*prometheus.Desc{
 		c.HealthStatus,
 		c.HealthStatusInterpreter.Desc(),
+		c.HealthCheckActive,
+		c.HealthChecks,
 		c.MONsDown,
+		c.MONsTotal,
+		c.MONsQuorum,
+		c.MONQuorumAge,
+		c.ClusterInfo,
+		c.MONElectionEpoch,
+		c.MONClockSkew,
+		c.DaemonsOldVersion,
+		c.DaemonVersions,
 		c.TotalPGs,
+		c.CleanPGsRatio,
 		c.DegradedPGs,
 		c.ActivePGs,
 		c.StuckDegradedPGs,
@@ -611,13 +744,24 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 		c.SnaptrimPGs,
 		c.SnaptrimWaitPGs,
 		c.RepairingPGs,
+		c.CreatingPGs,
+		c.ActivatingPGs,
 		c.SlowOps,
+		c.DaemonSlowOps,
 		c.DegradedObjectsCount,
+		c.DegradedRatio,
 		c.MisplacedObjectsCount,
 		c.MisplacedRatio,
 		c.NewCrashReportCount,
 		c.TooManyRepairs,
+		c.PGsNotScrubbed,
+		c.PGsNotDeepScrubbed,
 		c.Objects,
+		c.TotalBytes,
+		c.DataBytes,
+		c.RawUsedBytes,
+		c.RawAvailableBytes,
+		c.Pools,
 		c.OSDMapFlagFull.Desc(),
 		c.OSDMapFlagPauseRd.Desc(),
 		c.OSDMapFlagPauseWr.Desc(),
@@ -637,8 +781,10 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 		c.OSDsNum,
 		c.RemappedPGs,
 		c.RecoveryIORate,
+		c.RecoveryThrottled,
 		c.RecoveryIOKeys,
 		c.RecoveryIOObjects,
+		c.RecoveryObjectsLeft,
 		c.ClientReadBytesPerSec,
 		c.ClientWriteBytesPerSec,
 		c.ClientIOOps,
@@ -649,6 +795,7 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 		c.CachePromoteIOOps,
 		c.MgrsActive,
 		c.MgrsNum,
+		c.MgrMultipleActive,
 		c.PGState,
 	}
 }
@@ -670,14 +817,28 @@ type cephHealthStats struct {
 		Checks map[string]struct {
 			Severity string `json:"severity"`
 			Summary  struct {
-				Message string `json:"message"`
+				Message string  `json:"message"`
+				Count   float64 `json:"count"`
 			} `json:"summary"`
 		} `json:"checks"`
 	} `json:"health"`
+	FSID          string   `json:"fsid"`
+	ElectionEpoch *float64 `json:"election_epoch"`
+	Quorum        []int    `json:"quorum"`
+	QuorumNames   []string `json:"quorum_names"`
+	// QuorumAge is only reported since Octopus.
+	QuorumAge *float64 `json:"quorum_age"`
+	// MonMap only lists the Mons before Octopus, and only counts them since.
+	MonMap cephMonMap             `json:"monmap"`
 	OSDMap map[string]interface{} `json:"osdmap"`
 	PGMap  struct {
 		NumPGs                  float64 `json:"num_pgs"`
 		TotalObjects            float64 `json:"num_objects"`
+		NumPools                float64 `json:"num_pools"`
+		DataBytes               float64 `json:"data_bytes"`
+		BytesUsed               float64 `json:"bytes_used"`
+		BytesAvail              float64 `json:"bytes_avail"`
+		BytesTotal              float64 `json:"bytes_total"`
 		WriteOpPerSec           float64 `json:"write_op_per_sec"`
 		ReadOpPerSec            float64 `json:"read_op_per_sec"`
 		WriteBytePerSec         float64 `json:"write_bytes_sec"`
@@ -689,7 +850,10 @@ type cephHealthStats struct {
 		CacheEvictBytePerSec    float64 `json:"evict_bytes_sec"`
 		CachePromoteOpPerSec    float64 `json:"promote_op_per_sec"`
 		DegradedObjects         float64 `json:"degraded_objects"`
+		DegradedTotal           float64 `json:"degraded_total"`
+		DegradedRatio           float64 `json:"degraded_ratio"`
 		MisplacedObjects        float64 `json:"misplaced_objects"`
+		MisplacedTotal          float64 `json:"misplaced_total"`
 		MisplacedRatio          float64 `json:"misplaced_ratio"`
 		PGsByState              []struct {
 			Count  float64 `json:"count"`
@@ -702,20 +866,64 @@ type cephHealthStats struct {
 		NumStandBys int  `json:"num_standbys"`
 
 		// Nautilus fields
+		ActiveGID  int64  `json:"active_gid"`
 		ActiveName string `json:"active_name"`
 		StandBys   []struct {
+			GID  int64  `json:"gid"`
 			Name string `json:"name"`
 		} `json:"standbys"`
 	} `json:"mgrmap"`
 	ServiceMap struct {
-		Services struct {
-			RbdMirror struct {
-				Daemons map[string]json.RawMessage `json:"daemons"`
-			} `json:"rbd-mirror"`
+		Services map[string]struct {
+			Daemons map[string]json.RawMessage `json:"daemons"`
 		} `json:"services"`
 	} `json:"servicemap"`
 }
 
+type cephMonMap struct {
+	Mons []struct {
+		Name string `json:"name"`
+	} `json:"mons"`
+	NumMons *float64 `json:"num_mons"`
+}
+
+// activeMgrClaims counts the mgrs that claim to be active. The mgrmap only
+// names a single active mgr, so a second claim shows up as a standby that
+// shares the active mgr's name or gid. The Octopus+ status only carries
+// availability and counts, which can never show more than one claim.
+func (s *cephHealthStats) activeMgrClaims() int {
+	if s.MgrMap.ActiveName == "" && s.MgrMap.ActiveGID == 0 {
+		if s.MgrMap.Available {
+			return 1
+		}
+		return 0
+	}
+
+	claims := 1
+	for _, standby := range s.MgrMap.StandBys {
+		if (s.MgrMap.ActiveName != "" && standby.Name == s.MgrMap.ActiveName) ||
+			(s.MgrMap.ActiveGID != 0 && standby.GID == s.MgrMap.ActiveGID) {
+			claims++
+		}
+	}
+
+	return claims
+}
+
+// checkCriticality returns the criticality of a health check with the given
+// Ceph severity, as the status is interpreted: a warning is critical (2) and
+// an error is an error (3).
+func checkCriticality(severity string) int {
+	switch severity {
+	case CephHealthWarn:
+		return 2
+	case CephHealthErr:
+		return 3
+	}
+
+	return 0
+}
+
 func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *Version) error {
 	cmd := c.cephUsageCommand(jsonFormat)
 	buf, _, err := c.conn.MonCommand(cmd)
@@ -755,19 +963,37 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 	}
 
 	var (
-		monsDownRegex        = regexp.MustCompile(`([\d]+)/([\d]+) mons down, quorum \b+`)
+		monsDownRegex        = regexp.MustCompile(`([\d]+)/([\d]+) mons down`)
 		stuckDegradedRegex   = regexp.MustCompile(`([\d]+) pgs stuck degraded`)
 		stuckUncleanRegex    = regexp.MustCompile(`([\d]+) pgs stuck unclean`)
 		stuckUndersizedRegex = regexp.MustCompile(`([\d]+) pgs stuck undersized`)
 		stuckStaleRegex      = regexp.MustCompile(`([\d]+) pgs stuck stale`)
 		slowOpsRegexNautilus = regexp.MustCompile(`([\d]+) slow ops, oldest one blocked for ([\d]+) sec`)
+		slowOpsDaemonRegex   = regexp.MustCompile(`(?:daemons \[([^\]]*)\] have|(\S+) has) slow ops`)
 		newCrashreportRegex  = regexp.MustCompile(`([\d]+) daemons have recently crashed`)
 		tooManyRepairs       = regexp.MustCompile(`Too many repaired reads on ([\d]+) OSDs`)
+		notScrubbedRegex     = regexp.MustCompile(`([\d]+) pgs not scrubbed in time`)
+		notDeepScrubbedRegex = regexp.MustCompile(`([\d]+) pgs not deep-scrubbed in time`)
 		osdmapFlagsRegex     = regexp.MustCompile(`([^ ]+) flag\(s\) set`)
 	)
 
 	var mapEmpty = len(c.healthChecksMap) == 0
 
+	// recoveryBlocked is set by any flag or health check that keeps
+	// degraded PGs from recovering.
+	recoveryBlocked := false
+
+	// Transitional releases, e.g. Luminous with mon_health_preluminous_compat,
+	// report a condition both in the summary and as a check. Each metric is
+	// only reported once, preferring the check, and the first summary line
+	// matching it otherwise.
+	summaryValues := make(map[*prometheus.Desc]float64)
+	setSummaryValue := func(desc *prometheus.Desc, v int) {
+		if _, ok := summaryValues[desc]; !ok {
+			summaryValues[desc] = float64(v)
+		}
+	}
+
 	for _, s := range stats.Health.Summary {
 		matched := stuckDegradedRegex.FindStringSubmatch(s.Summary)
 		if len(matched) == 2 {
@@ -775,7 +1001,7 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			if err != nil {
 				return err
 			}
-			ch <- prometheus.MustNewConstMetric(c.StuckDegradedPGs, prometheus.GaugeValue, float64(v))
+			setSummaryValue(c.StuckDegradedPGs, v)
 		}
 
 		matched = stuckUncleanRegex.FindStringSubmatch(s.Summary)
@@ -784,7 +1010,7 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			if err != nil {
 				return err
 			}
-			ch <- prometheus.MustNewConstMetric(c.StuckUncleanPGs, prometheus.GaugeValue, float64(v))
+			setSummaryValue(c.StuckUncleanPGs, v)
 		}
 
 		matched = stuckUndersizedRegex.FindStringSubmatch(s.Summary)
@@ -793,7 +1019,7 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			if err != nil {
 				return err
 			}
-			ch <- prometheus.MustNewConstMetric(c.StuckUndersizedPGs, prometheus.GaugeValue, float64(v))
+			setSummaryValue(c.StuckUndersizedPGs, v)
 		}
 
 		matched = stuckStaleRegex.FindStringSubmatch(s.Summary)
@@ -802,7 +1028,7 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			if err != nil {
 				return err
 			}
-			ch <- prometheus.MustNewConstMetric(c.StuckStalePGs, prometheus.GaugeValue, float64(v))
+			setSummaryValue(c.StuckStalePGs, v)
 		}
 
 		matched = slowOpsRegexNautilus.FindStringSubmatch(s.Summary)
@@ -811,12 +1037,66 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			if err != nil {
 				return err
 			}
-			ch <- prometheus.MustNewConstMetric(c.SlowOps, prometheus.GaugeValue, float64(v))
+			setSummaryValue(c.SlowOps, v)
 		}
 	}
 
+	if check, ok := stats.Health.Checks["SLOW_OPS"]; ok && slowOpsRegexNautilus.MatchString(check.Summary.Message) {
+		delete(summaryValues, c.SlowOps)
+	}
+	for desc, v := range summaryValues {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+
+	// Both severities are always reported, so that a healthy cluster has 0
+	// rather than no checks at all.
+	checksBySeverity := map[string]float64{
+		CephHealthWarn: 0,
+		CephHealthErr:  0,
+	}
+	for _, check := range stats.Health.Checks {
+		checksBySeverity[check.Severity]++
+	}
+	for severity, count := range checksBySeverity {
+		ch <- prometheus.MustNewConstMetric(c.HealthChecks, prometheus.GaugeValue, count, severity)
+	}
+
+	// Report no old daemons once an upgrade is done, rather than nothing.
+	oldVersionCheck := c.versionAtLeast(version, Pacific, "daemon_old_version")
+	if _, ok := stats.Health.Checks["DAEMON_OLD_VERSION"]; !ok && oldVersionCheck {
+		ch <- prometheus.MustNewConstMetric(c.DaemonsOldVersion, prometheus.GaugeValue, 0)
+	}
+
+	// The Mon count of MON_DOWN is only used if the monmap isn't known.
+	monsTotal := -1.0
+
+	// interp is the highest criticality of the failing checks, a check
+	// missing from healthChecksMap is as critical as the severity Ceph
+	// reports for it.
+	interp := 0
+
+	// `ceph health detail` is only fetched if a check that needs it is
+	// raised, and then only once for all of them.
+	var (
+		healthDetail        *cephHealthDetail
+		healthDetailErr     error
+		healthDetailFetched bool
+	)
+	getHealthDetail := func() (*cephHealthDetail, error) {
+		if !healthDetailFetched {
+			healthDetail, healthDetailErr = c.getHealthDetail()
+			healthDetailFetched = true
+		}
+		return healthDetail, healthDetailErr
+	}
+
 	// This stores OSD map flags that were found, so the rest can be set to 0
 	for k, check := range stats.Health.Checks {
+		// Checks missing from healthChecksMap are exported as well, so that
+		// newly introduced ones can be alerted on. Cleared checks are simply
+		// not reported anymore.
+		ch <- prometheus.MustNewConstMetric(c.HealthCheckActive, prometheus.GaugeValue, 1, k, check.Severity)
+
 		if k == "MON_DOWN" {
 			matched := monsDownRegex.FindStringSubmatch(check.Summary.Message)
 			if len(matched) == 3 {
@@ -824,7 +1104,22 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 				if err != nil {
 					return err
 				}
+				total, err := strconv.Atoi(matched[2])
+				if err != nil {
+					return err
+				}
 				ch <- prometheus.MustNewConstMetric(c.MONsDown, prometheus.GaugeValue, float64(v))
+				monsTotal = float64(total)
+			}
+		}
+
+		if k == "MON_CLOCK_SKEW" {
+			detail, err := getHealthDetail()
+			if err == nil {
+				err = c.collectClockSkewDetail(ch, detail)
+			}
+			if err != nil {
+				c.logger.WithError(err).Error("error collecting mon clock skew detail")
 			}
 		}
 
@@ -837,6 +1132,27 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 				}
 				ch <- prometheus.MustNewConstMetric(c.SlowOps, prometheus.GaugeValue, float64(v))
 			}
+
+			// The daemons are either named one by one, e.g. "osd.39 has
+			// slow ops", or as a list, e.g. "daemons [osd.114,mon.a] have
+			// slow ops".
+			matched = slowOpsDaemonRegex.FindStringSubmatch(check.Summary.Message)
+			if len(matched) == 3 {
+				daemons := []string{matched[2]}
+				if matched[1] != "" {
+					daemons = strings.Split(matched[1], ",")
+				}
+
+				seen := make(map[string]bool)
+				for _, daemon := range daemons {
+					// Long lists may be cut short with "...".
+					if daemon = strings.TrimSpace(daemon); daemon == "" || daemon == "..." || seen[daemon] {
+						continue
+					}
+					seen[daemon] = true
+					ch <- prometheus.MustNewConstMetric(c.DaemonSlowOps, prometheus.GaugeValue, 1, daemon)
+				}
+			}
 		}
 
 		if k == "RECENT_CRASH" {
@@ -861,6 +1177,28 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			}
 		}
 
+		if k == "PG_NOT_SCRUBBED" {
+			matched := notScrubbedRegex.FindStringSubmatch(check.Summary.Message)
+			if len(matched) == 2 {
+				v, err := strconv.Atoi(matched[1])
+				if err != nil {
+					return err
+				}
+				ch <- prometheus.MustNewConstMetric(c.PGsNotScrubbed, prometheus.GaugeValue, float64(v))
+			}
+		}
+
+		if k == "PG_NOT_DEEP_SCRUBBED" {
+			matched := notDeepScrubbedRegex.FindStringSubmatch(check.Summary.Message)
+			if len(matched) == 2 {
+				v, err := strconv.Atoi(matched[1])
+				if err != nil {
+					return err
+				}
+				ch <- prometheus.MustNewConstMetric(c.PGsNotDeepScrubbed, prometheus.GaugeValue, float64(v))
+			}
+		}
+
 		if k == "OSDMAP_FLAGS" {
 			matched := osdmapFlagsRegex.FindStringSubmatch(check.Summary.Message)
 			if len(matched) > 0 {
@@ -872,24 +1210,57 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 					if _, exists := c.OSDFlagToGaugeMap[f]; exists {
 						(*c.OSDFlagToGaugeMap[f]).Set(1)
 					}
+
+					switch f {
+					case "norecover", "nobackfill", "full":
+						recoveryBlocked = true
+					}
 				}
 			}
 		}
 
-		if version.IsAtLeast(Pacific) {
+		switch k {
+		case "OSD_FULL", "PG_RECOVERY_FULL", "PG_BACKFILL_FULL":
+			recoveryBlocked = true
+		}
+
+		if oldVersionCheck {
 			// pacific adds the DAEMON_OLD_VERSION health check
 			// that indicates that multiple versions of Ceph have been running for longer than mon_warn_older_version_delay
-			// we'll interpret this is a critical warning (2)
-			c.healthChecksMap["DAEMON_OLD_VERSION"] = 2
-		}
+			// we'll interpret this is a critical warning (2), unless configured otherwise
+			if _, present := c.healthChecksMap["DAEMON_OLD_VERSION"]; !present {
+				c.healthChecksMap["DAEMON_OLD_VERSION"] = 2
+			}
+
+			if k == "DAEMON_OLD_VERSION" {
+				detail, err := getHealthDetail()
+				if err != nil {
+					c.logger.WithError(err).Error("error collecting daemon old version detail")
 
-		if !mapEmpty {
-			if val, present := c.healthChecksMap[k]; present {
-				c.HealthStatusInterpreter.Set(float64(val))
-				// migration of HealthStatusInterpreter to ConstMetrics had to be reverted due to duplication issues with the current structure (and labels not being used)
-				//ch <- prometheus.MustNewConstMetric(c.HealthStatusInterpreter, prometheus.GaugeValue, float64(val))
+					// The total is still known from the summary.
+					ch <- prometheus.MustNewConstMetric(c.DaemonsOldVersion, prometheus.GaugeValue, check.Summary.Count)
+				} else {
+					c.collectOldVersionDetail(ch, check.Summary.Count, detail)
+				}
 			}
 		}
+
+		// A criticality of 0 means the check is configured to be ignored.
+		val, present := c.healthChecksMap[k]
+		if !present {
+			val = checkCriticality(check.Severity)
+		}
+		if val > interp {
+			interp = val
+		}
+	}
+
+	// The status is only interpreted as is when there are no checks, e.g.
+	// on releases before Luminous, or nothing to interpret them with.
+	if !mapEmpty && len(stats.Health.Checks) > 0 {
+		c.HealthStatusInterpreter.Set(float64(interp))
+		// migration of HealthStatusInterpreter to ConstMetrics had to be reverted due to duplication issues with the current structure (and labels not being used)
+		//ch <- prometheus.MustNewConstMetric(c.HealthStatusInterpreter, prometheus.GaugeValue, float64(interp))
 	}
 
 	var (
@@ -913,6 +1284,8 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 		snaptrimPGs       float64
 		snaptrimWaitPGs   float64
 		repairingPGs      float64
+		creatingPGs       float64
+		activatingPGs     float64
 
 		pgStateCounterMap = map[string]*float64{
 			"degraded":        &degradedPGs,
@@ -935,6 +1308,8 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			"snaptrim":        &snaptrimPGs,
 			"snaptrim_wait":   &snaptrimWaitPGs,
 			"repair":          &repairingPGs,
+			"creating":        &creatingPGs,
+			"activating":      &activatingPGs,
 		}
 		pgStateGaugeMap = map[string]*prometheus.Desc{
 			"degraded":        c.DegradedPGs,
@@ -957,20 +1332,37 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 			"snaptrim":        c.SnaptrimPGs,
 			"snaptrim_wait":   c.SnaptrimWaitPGs,
 			"repair":          c.RepairingPGs,
+			"creating":        c.CreatingPGs,
+			"activating":      c.ActivatingPGs,
 		}
 	)
 
+	cleanPGs := float64(0)
 	for _, p := range stats.PGMap.PGsByState {
 		p.States = strings.ReplaceAll(p.States, "scrubbing+deep", "deep_scrubbing")
 		stateArray := strings.Split(p.States, "+")
 
+		isActive, isClean := false, false
 		for _, state := range stateArray {
 			if count, has := pgStateCounterMap[state]; has {
 				*count += p.Count
 			}
+
+			switch state {
+			case "active":
+				isActive = true
+			case "clean":
+				isClean = true
+			}
+		}
+
+		if isActive && isClean {
+			cleanPGs += p.Count
 		}
 	}
 
+	ch <- prometheus.MustNewConstMetric(c.CleanPGsRatio, prometheus.GaugeValue, cleanPGsRatio(cleanPGs, stats.PGMap.NumPGs))
+
 	for state, gauge := range pgStateGaugeMap {
 		val := *pgStateCounterMap[state]
 
@@ -986,12 +1378,15 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 	ch <- prometheus.MustNewConstMetric(c.RecoveryIOKeys, prometheus.GaugeValue, stats.PGMap.RecoveringKeysPerSec)
 	ch <- prometheus.MustNewConstMetric(c.RecoveryIOObjects, prometheus.GaugeValue, stats.PGMap.RecoveringObjectsPerSec)
 	ch <- prometheus.MustNewConstMetric(c.RecoveryIORate, prometheus.GaugeValue, stats.PGMap.RecoveringBytePerSec)
+	ch <- prometheus.MustNewConstMetric(c.RecoveryThrottled, prometheus.GaugeValue, recoveryThrottled(
+		degradedPGs, stats.PGMap.RecoveringBytePerSec+stats.PGMap.RecoveringObjectsPerSec, recoveryBlocked))
 	ch <- prometheus.MustNewConstMetric(c.CacheEvictIORate, prometheus.GaugeValue, stats.PGMap.CacheEvictBytePerSec)
 	ch <- prometheus.MustNewConstMetric(c.CacheFlushIORate, prometheus.GaugeValue, stats.PGMap.CacheFlushBytePerSec)
 	ch <- prometheus.MustNewConstMetric(c.CachePromoteIOOps, prometheus.GaugeValue, stats.PGMap.CachePromoteOpPerSec)
 
+	// Before Octopus the osdmap of the status is nested in another.
 	var actualOsdMap osdMap
-	if version.IsAtLeast(Octopus) {
+	if c.versionAtLeast(version, Octopus, "octopus_osdmap") {
 		if stats.OSDMap != nil {
 			actualOsdMap = osdMap{
 				NumOSDs:        stats.OSDMap["num_osds"].(float64),
@@ -1024,14 +1419,23 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 	ch <- prometheus.MustNewConstMetric(c.RemappedPGs, prometheus.GaugeValue, actualOsdMap.NumRemappedPGs)
 	ch <- prometheus.MustNewConstMetric(c.TotalPGs, prometheus.GaugeValue, stats.PGMap.NumPGs)
 	ch <- prometheus.MustNewConstMetric(c.Objects, prometheus.GaugeValue, stats.PGMap.TotalObjects)
+	ch <- prometheus.MustNewConstMetric(c.TotalBytes, prometheus.GaugeValue, stats.PGMap.BytesTotal)
+	ch <- prometheus.MustNewConstMetric(c.DataBytes, prometheus.GaugeValue, stats.PGMap.DataBytes)
+	ch <- prometheus.MustNewConstMetric(c.RawUsedBytes, prometheus.GaugeValue, stats.PGMap.BytesUsed)
+	ch <- prometheus.MustNewConstMetric(c.RawAvailableBytes, prometheus.GaugeValue, stats.PGMap.BytesAvail)
+	ch <- prometheus.MustNewConstMetric(c.Pools, prometheus.GaugeValue, stats.PGMap.NumPools)
 
 	ch <- prometheus.MustNewConstMetric(c.DegradedObjectsCount, prometheus.GaugeValue, stats.PGMap.DegradedObjects)
+	ch <- prometheus.MustNewConstMetric(c.DegradedRatio, prometheus.GaugeValue, stats.PGMap.DegradedRatio)
 	ch <- prometheus.MustNewConstMetric(c.MisplacedObjectsCount, prometheus.GaugeValue, stats.PGMap.MisplacedObjects)
 	ch <- prometheus.MustNewConstMetric(c.MisplacedRatio, prometheus.GaugeValue, stats.PGMap.MisplacedRatio)
+	ch <- prometheus.MustNewConstMetric(c.RecoveryObjectsLeft, prometheus.GaugeValue,
+		remainingObjects(stats.PGMap.DegradedObjects, stats.PGMap.DegradedTotal, stats.PGMap.DegradedRatio)+
+			remainingObjects(stats.PGMap.MisplacedObjects, stats.PGMap.MisplacedTotal, stats.PGMap.MisplacedRatio))
 
 	activeMgr := 0
 	standByMgrs := 0
-	if version.IsAtLeast(Octopus) {
+	if c.versionAtLeast(version, Octopus, "octopus_mgrmap") {
 		if stats.MgrMap.Available {
 			activeMgr = 1
 		}
@@ -1046,27 +1450,196 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric, version *V
 	ch <- prometheus.MustNewConstMetric(c.MgrsActive, prometheus.GaugeValue, float64(activeMgr))
 	ch <- prometheus.MustNewConstMetric(c.MgrsNum, prometheus.GaugeValue, float64(activeMgr+standByMgrs))
 
-	for name, data := range stats.ServiceMap.Services.RbdMirror.Daemons {
-		if name == "summary" {
-			continue
-		}
+	multipleActive := 0.0
+	if stats.activeMgrClaims() > 1 {
+		multipleActive = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.MgrMultipleActive, prometheus.GaugeValue, multipleActive)
+
+	c.collectMonQuorum(ch, stats, monsTotal)
+	c.collectClusterInfo(ch, stats)
 
-		md := struct {
-			Metadata struct {
-				Id string `json:"id"`
-			} `json:"metadata"`
-		}{}
+	for service, svc := range stats.ServiceMap.Services {
+		seen := make(map[string]bool, len(svc.Daemons))
+		for name, data := range svc.Daemons {
+			if name == "summary" {
+				continue
+			}
+
+			md := struct {
+				Metadata struct {
+					Id string `json:"id"`
+				} `json:"metadata"`
+			}{}
+
+			// Extract id from metadata
+			if err := json.Unmarshal(data, &md); err != nil {
+				continue
+			}
+
+			// Daemons are keyed by their gid on newer releases, with the
+			// name only available in the metadata.
+			id := md.Metadata.Id
+			if id == "" {
+				id = name
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
 
-		// Extract id from metadata
-		if err := json.Unmarshal(data, &md); err == nil {
 			ch <- prometheus.MustNewConstMetric(
-				c.RbdMirrorUp, prometheus.GaugeValue, 1.0, md.Metadata.Id)
+				c.ServiceDaemon, prometheus.GaugeValue, 1.0, service, id)
+
+			if service == "rbd-mirror" {
+				ch <- prometheus.MustNewConstMetric(
+					c.RbdMirrorUp, prometheus.GaugeValue, 1.0, md.Metadata.Id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectMonQuorum reports the number of Mons in quorum and in the monmap.
+// The number of Mons is taken from the monmap of the status, falling back to
+// monsTotal, if known, when the status doesn't carry it.
+func (c *ClusterHealthCollector) collectMonQuorum(ch chan<- prometheus.Metric, stats *cephHealthStats, monsTotal float64) {
+	ch <- prometheus.MustNewConstMetric(c.MONsQuorum, prometheus.GaugeValue, float64(len(stats.Quorum)))
+	if stats.QuorumAge != nil {
+		ch <- prometheus.MustNewConstMetric(c.MONQuorumAge, prometheus.GaugeValue, *stats.QuorumAge)
+	}
+
+	if len(stats.MonMap.Mons) > 0 {
+		monsTotal = float64(len(stats.MonMap.Mons))
+	} else if stats.MonMap.NumMons != nil {
+		monsTotal = *stats.MonMap.NumMons
+	}
+	if monsTotal >= 0 {
+		ch <- prometheus.MustNewConstMetric(c.MONsTotal, prometheus.GaugeValue, monsTotal)
+	}
+}
+
+// collectClusterInfo reports the cluster's identity and the Mon elections,
+// either of which is left out if the status doesn't carry it.
+func (c *ClusterHealthCollector) collectClusterInfo(ch chan<- prometheus.Metric, stats *cephHealthStats) {
+	if stats.FSID != "" {
+		// The leader is always the Mon of the lowest rank in quorum, which
+		// is the first of the quorum names.
+		leader := ""
+		if len(stats.QuorumNames) > 0 {
+			leader = stats.QuorumNames[0]
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.ClusterInfo, prometheus.GaugeValue, 1, stats.FSID, leader)
+	}
+
+	if stats.ElectionEpoch != nil {
+		ch <- prometheus.MustNewConstMetric(c.MONElectionEpoch, prometheus.GaugeValue, *stats.ElectionEpoch)
+	}
+}
+
+// clockSkewDetailRegex matches the MON_CLOCK_SKEW detail messages, e.g.
+// "mon.b clock skew 0.0823471s > max 0.05s (latency 0.00154s)", as well as
+// the older form that includes the monitor's address, e.g.
+// "mon.b addr 10.0.0.2:6789/0 clock skew 0.5s > max 0.05s (latency 0.001s)".
+var clockSkewDetailRegex = regexp.MustCompile(`^mon\.(\S+) (?:addr \S+ )?clock skew (-?[\d.]+(?:e[-+]?\d+)?)s`)
+
+type cephHealthDetail struct {
+	Checks map[string]struct {
+		Detail []struct {
+			Message string `json:"message"`
+		} `json:"detail"`
+	} `json:"checks"`
+}
+
+// getHealthDetail runs `ceph health detail`, which lists the daemons each
+// health check is raised for.
+func (c *ClusterHealthCollector) getHealthDetail() (*cephHealthDetail, error) {
+	cmd := c.cephHealthDetailCommand()
+	buf, _, err := c.conn.MonCommand(cmd)
+	if err != nil {
+		c.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return nil, err
+	}
+
+	detail := &cephHealthDetail{}
+	if err := json.Unmarshal(buf, detail); err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// collectClockSkewDetail reports the skew of each monitor named in the
+// MON_CLOCK_SKEW check. The detail is only part of `ceph health detail`, so
+// this is only called while the check is raised.
+func (c *ClusterHealthCollector) collectClockSkewDetail(ch chan<- prometheus.Metric, detail *cephHealthDetail) error {
+	for _, d := range detail.Checks["MON_CLOCK_SKEW"].Detail {
+		matched := clockSkewDetailRegex.FindStringSubmatch(d.Message)
+		if len(matched) != 3 {
+			continue
 		}
+
+		skew, err := strconv.ParseFloat(matched[2], 64)
+		if err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.MONClockSkew, prometheus.GaugeValue, math.Abs(skew), matched[1])
 	}
 
 	return nil
 }
 
+// remainingObjects returns the no. of degraded or misplaced object copies,
+// derived from the total no. of object copies and the ratio if only those
+// are reported.
+func remainingObjects(objects, total, ratio float64) float64 {
+	if objects > 0 {
+		return objects
+	}
+
+	return math.Round(total * ratio)
+}
+
+// oldVersionDetailRegex matches the DAEMON_OLD_VERSION detail messages, e.g.
+// "osd.1 osd.2 are running an older version of ceph: 16.2.10".
+var oldVersionDetailRegex = regexp.MustCompile(`^(.+?) (?:is|are) running an older version of ceph: (\S+)`)
+
+// collectOldVersionDetail reports how many daemons run an older version, in
+// total and by version. The summary only carries the total, the versions are
+// only part of `ceph health detail`, so this is only called while the check
+// is raised.
+func (c *ClusterHealthCollector) collectOldVersionDetail(ch chan<- prometheus.Metric, count float64, detail *cephHealthDetail) {
+	versions := make(map[string]float64)
+	total := 0.0
+	for _, d := range detail.Checks["DAEMON_OLD_VERSION"].Detail {
+		matched := oldVersionDetailRegex.FindStringSubmatch(d.Message)
+		if len(matched) != 3 {
+			continue
+		}
+
+		daemons := float64(len(strings.Fields(matched[1])))
+		versions[matched[2]] += daemons
+		total += daemons
+	}
+
+	// Releases that don't report the count in the summary.
+	if count == 0 {
+		count = total
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.DaemonsOldVersion, prometheus.GaugeValue, count)
+	for v, n := range versions {
+		ch <- prometheus.MustNewConstMetric(c.DaemonVersions, prometheus.GaugeValue, n, v)
+	}
+
+}
+
 type format string
 
 const (
@@ -1085,6 +1658,18 @@ func (c *ClusterHealthCollector) cephUsageCommand(f format) []byte {
 	return cmd
 }
 
+func (c *ClusterHealthCollector) cephHealthDetailCommand() []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "health",
+		"detail": "detail",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		c.logger.WithError(err).Panic("error marshalling ceph health detail")
+	}
+	return cmd
+}
+
 func (c *ClusterHealthCollector) collectRecoveryClientIO(ch chan<- prometheus.Metric) error {
 	cmd := c.cephUsageCommand(plainFormat)
 	buf, _, err := c.conn.MonCommand(cmd)
@@ -1100,13 +1685,6 @@ func (c *ClusterHealthCollector) collectRecoveryClientIO(ch chan<- prometheus.Me
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
 
-		// If we discover the health check is Luminous-specific
-		// we stop continuing extracting recovery/client I/O,
-		// because we already get it from health function.
-		if line == "cluster:" {
-			return nil
-		}
-
 		switch {
 		case strings.HasPrefix(line, "recovery io"):
 			if err := c.collectRecoveryIO(line, ch); err != nil {
@@ -1326,6 +1904,7 @@ func (c *ClusterHealthCollector) collectCacheIO(clientStr string, ch chan<- prom
 // to the provided prometheus channel.
 func (c *ClusterHealthCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.RbdMirrorUp
+	ch <- c.ServiceDaemon
 
 	for _, metric := range c.descriptorList() {
 		ch <- metric
@@ -1334,7 +1913,8 @@ func (c *ClusterHealthCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect sends all the collected metrics to the provided prometheus channel.
 // It requires the caller to handle synchronization.
-func (c *ClusterHealthCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (c *ClusterHealthCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	errs := &collectErrors{}
 	wg := &sync.WaitGroup{}
 
 	wg.Add(1)
@@ -1344,22 +1924,52 @@ func (c *ClusterHealthCollector) Collect(ch chan<- prometheus.Metric, version *V
 		c.logger.Debug("collecting cluster health metrics")
 		if err := c.collect(ch, version); err != nil {
 			c.logger.WithError(err).Error("error collecting cluster health metrics " + err.Error())
+			errs.add(err)
 		}
 	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		c.logger.Debug("collecting cluster recovery/client I/O metrics")
-		if err := c.collectRecoveryClientIO(ch); err != nil {
-			c.logger.WithError(err).Error("error collecting cluster recovery/client I/O metrics")
-		}
-	}()
+	// Since Luminous the recovery, client and cache I/O are part of the pgmap
+	// of the JSON status, only older releases need the plain one.
+	if !c.versionAtLeast(version, Luminous, "status_io") {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			c.logger.Debug("collecting cluster recovery/client I/O metrics")
+			if err := c.collectRecoveryClientIO(ch); err != nil {
+				c.logger.WithError(err).Error("error collecting cluster recovery/client I/O metrics")
+				errs.add(err)
+			}
+		}()
+	}
 
 	wg.Wait()
 
 	for _, metric := range c.collectorsList() {
 		metric.Collect(ch)
 	}
+
+	return errs.err()
+}
+
+// cleanPGsRatio returns the fraction of PGs that are active+clean, clamped to
+// [0, 1]. A cluster without any PGs has nothing unhealthy, so it reports 1.
+func cleanPGsRatio(clean, total float64) float64 {
+	if total <= 0 {
+		return 1
+	}
+
+	return math.Max(0, math.Min(1, clean/total))
+}
+
+// recoveryThrottled returns 1 when there are degraded PGs, recovery i/o has
+// stalled and something is known to be blocking recovery, which answers why
+// recovery isn't progressing. A slow trickle of recovery i/o still counts as
+// progress.
+func recoveryThrottled(degradedPGs, recoveryIO float64, blocked bool) float64 {
+	if blocked && degradedPGs > 0 && recoveryIO < 1 {
+		return 1
+	}
+
+	return 0
 }
@@ -101,6 +101,42 @@ func TestClusterHealthCollector(t *testing.T) {
 				regexp.MustCompile(`misplaced_objects{cluster="ceph"} 20`),
 			},
 		},
+		{
+			name: "objects left to recover",
+			input: `
+{
+	"pgmap": {
+		"degraded_objects": 10,
+		"degraded_total": 3000,
+		"degraded_ratio": 0.0033333,
+		"misplaced_objects": 20,
+		"misplaced_total": 3000,
+		"misplaced_ratio": 0.0066667,
+		"recovering_objects_per_sec": 5
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`recovery_objects_remaining{cluster="ceph"} 30`),
+				regexp.MustCompile(`recovery_io_objects{cluster="ceph"} 5`),
+			},
+		},
+		{
+			name: "objects left to recover from ratios",
+			input: `
+{
+	"pgmap": {
+		"degraded_total": 3000,
+		"degraded_ratio": 0.01,
+		"misplaced_total": 3000,
+		"misplaced_ratio": 0.02
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`recovery_objects_remaining{cluster="ceph"} 90`),
+			},
+		},
 		{
 			name:    "10 down osds",
 			version: `{"version":"ceph version 14.2.9-12-zasd (1337) pacific (stable)"}`,
@@ -231,7 +267,7 @@ $ sudo ceph -s
   recovery io 5779 MB/s, 4 keys/s, 1522 objects/s
   client io 4273 kB/s rd, 2740 MB/s wr, 2863 op/s
 `,
-			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			version: `{"version":"ceph version 10.2.11 (e4b061b47f07f583c92a050d9e84b1813a35671e) jewel (stable)"}`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`recovery_io_bytes{cluster="ceph"} 5.779e`),
 				regexp.MustCompile(`recovery_io_keys{cluster="ceph"} 4`),
@@ -252,7 +288,7 @@ $ sudo ceph -s
   client io 2863 op/s rd, 5847 op/s wr
   cache io 251 MB/s flush, 6646 kB/s evict, 55 op/s promote
 `,
-			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			version: `{"version":"ceph version 10.2.11 (e4b061b47f07f583c92a050d9e84b1813a35671e) jewel (stable)"}`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`recovery_io_bytes{cluster="ceph"} 5.779e`),
 				regexp.MustCompile(`recovery_io_keys{cluster="ceph"} 4`),
@@ -326,6 +362,230 @@ $ sudo ceph -s
 				regexp.MustCompile(`repairing_pgs{cluster="ceph"} 1`),
 			},
 		},
+		{
+			name: "creating pgs",
+			input: `
+{
+	"pgmap": {
+		"pgs_by_state": [
+			{
+				"state_name": "active+clean",
+				"count": 64
+			},
+			{
+				"state_name": "creating",
+				"count": 24
+			},
+			{
+				"state_name": "creating+peering",
+				"count": 6
+			},
+			{
+				"state_name": "activating",
+				"count": 2
+			}
+		],
+		"num_pgs": 96,
+		"num_objects": 13156
+	},
+	"health": {"summary": [{"severity": "HEALTH_WARN", "summary": "Reduced data availability: 32 pgs inactive"}]}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`creating_pgs{cluster="ceph"} 30`),
+				regexp.MustCompile(`activating_pgs{cluster="ceph"} 2`),
+				regexp.MustCompile(`peering_pgs{cluster="ceph"} 6`),
+				regexp.MustCompile(`active_pgs{cluster="ceph"} 64`),
+				regexp.MustCompile(`pg_state{cluster="ceph",state="creating"} 30`),
+				regexp.MustCompile(`pg_state{cluster="ceph",state="activating"} 2`),
+			},
+		},
+		{
+			name: "clean pg ratio",
+			input: `
+{
+	"pgmap": {
+		"pgs_by_state": [
+			{
+				"state_name": "active+clean",
+				"count": 60
+			},
+			{
+				"state_name": "active+clean+scrubbing+deep",
+				"count": 10
+			},
+			{
+				"state_name": "active+undersized+degraded",
+				"count": 20
+			},
+			{
+				"state_name": "peering",
+				"count": 10
+			}
+		],
+		"num_pgs": 100,
+		"num_objects": 13156
+	},
+	"health": {"status": "HEALTH_WARN"}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`pgs_clean_ratio{cluster="ceph"} 0.7`),
+			},
+		},
+		{
+			name: "clean pg ratio without pgs",
+			input: `
+{
+	"pgmap": {
+		"pgs_by_state": [],
+		"num_pgs": 0
+	},
+	"health": {"status": "HEALTH_OK"}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`pgs_clean_ratio{cluster="ceph"} 1`),
+			},
+		},
+		{
+			name: "health checks by severity",
+			input: `
+{
+	"health": {
+		"status": "HEALTH_ERR",
+		"checks": {
+			"OSD_DOWN": {"severity": "HEALTH_WARN", "summary": {"message": "1 osds down"}},
+			"POOL_NO_REDUNDANCY": {"severity": "HEALTH_WARN", "summary": {"message": "1 pool(s) have no replicas configured"}},
+			"OSD_FULL": {"severity": "HEALTH_ERR", "summary": {"message": "1 full osd(s)"}}
+		}
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`health_checks_total{cluster="ceph",severity="HEALTH_WARN"} 2`),
+				regexp.MustCompile(`health_checks_total{cluster="ceph",severity="HEALTH_ERR"} 1`),
+			},
+		},
+		{
+			name: "no health checks",
+			input: `
+{
+	"health": {"status": "HEALTH_OK", "checks": {}}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`health_checks_total{cluster="ceph",severity="HEALTH_WARN"} 0`),
+				regexp.MustCompile(`health_checks_total{cluster="ceph",severity="HEALTH_ERR"} 0`),
+			},
+		},
+		{
+			name: "daemons on an old version",
+			// the mock returns this for both `status` and `health detail`
+			input: `
+{
+	"health": {
+		"status": "HEALTH_WARN",
+		"checks": {
+			"DAEMON_OLD_VERSION": {
+				"severity": "HEALTH_WARN",
+				"summary": {"message": "There are daemons running multiple old versions of ceph", "count": 4}
+			}
+		}
+	},
+	"checks": {
+		"DAEMON_OLD_VERSION": {
+			"severity": "HEALTH_WARN",
+			"summary": {"message": "There are daemons running multiple old versions of ceph", "count": 4},
+			"detail": [
+				{"message": "osd.1 osd.2 mon.b are running an older version of ceph: 16.2.10"},
+				{"message": "mds.cephfs-a is running an older version of ceph: 15.2.17"}
+			]
+		}
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`daemons_old_version{cluster="ceph"} 4`),
+				regexp.MustCompile(`daemon_versions{cluster="ceph",version="16.2.10"} 3`),
+				regexp.MustCompile(`daemon_versions{cluster="ceph",version="15.2.17"} 1`),
+				regexp.MustCompile(`health_status_interp{cluster="ceph"} 2`),
+			},
+		},
+		{
+			name: "no daemons on an old version",
+			input: `
+{
+	"health": {"status": "HEALTH_OK", "checks": {}}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`daemons_old_version{cluster="ceph"} 0`),
+			},
+		},
+		{
+			name: "mon clock skew",
+			// the mock returns this for both `status` and `health detail`
+			input: `
+{
+	"health": {
+		"status": "HEALTH_WARN",
+		"checks": {
+			"MON_CLOCK_SKEW": {
+				"severity": "HEALTH_WARN",
+				"summary": {"message": "clock skew detected on mon.b, mon.c"}
+			}
+		}
+	},
+	"checks": {
+		"MON_CLOCK_SKEW": {
+			"severity": "HEALTH_WARN",
+			"summary": {"message": "clock skew detected on mon.b, mon.c", "count": 2},
+			"detail": [
+				{"message": "mon.b clock skew 0.0823471s > max 0.05s (latency 0.00154195s)"},
+				{"message": "mon.c clock skew -1.25s > max 0.05s (latency 0.00210353s)"}
+			]
+		}
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`mon_clock_skew_seconds{cluster="ceph",mon="b"} 0.0823471`),
+				regexp.MustCompile(`mon_clock_skew_seconds{cluster="ceph",mon="c"} 1.25`),
+				regexp.MustCompile(`health_status_interp{cluster="ceph"} 2`),
+			},
+		},
+		{
+			name: "mon clock skew with addresses",
+			// the mock returns this for both `status` and `health detail`
+			input: `
+{
+	"health": {
+		"status": "HEALTH_WARN",
+		"checks": {
+			"MON_CLOCK_SKEW": {
+				"severity": "HEALTH_WARN",
+				"summary": {"message": "clock skew detected on mon.b, mon.c"}
+			}
+		}
+	},
+	"checks": {
+		"MON_CLOCK_SKEW": {
+			"severity": "HEALTH_WARN",
+			"summary": {"message": "clock skew detected on mon.b, mon.c", "count": 2},
+			"detail": [
+				{"message": "mon.b addr 10.0.0.2:6789/0 clock skew 0.5s > max 0.05s (latency 0.001s)"},
+				{"message": "mon.c addr [v2:10.0.0.3:3300/0,v1:10.0.0.3:6789/0] clock skew 2s > max 0.05s (latency 0.002s)"}
+			]
+		}
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`mon_clock_skew_seconds{cluster="ceph",mon="b"} 0.5`),
+				regexp.MustCompile(`mon_clock_skew_seconds{cluster="ceph",mon="c"} 2`),
+			},
+		},
 		{
 			name: "mon down",
 			input: `
@@ -344,6 +604,70 @@ $ sudo ceph -s
 			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`mons_down{cluster="ceph"} 1`),
+				regexp.MustCompile(`mons_total{cluster="ceph"} 3`),
+			},
+		},
+		{
+			name: "mon down with dashed and dotted names",
+			input: `
+{
+  "health": {
+    "checks": {
+      "MON_DOWN": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "2/5 mons down, quorum ceph-mon-01,mon.host-2,ceph-mon3"
+        }
+      }
+    }
+  }
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`mons_down{cluster="ceph"} 2`),
+				regexp.MustCompile(`mons_total{cluster="ceph"} 5`),
+			},
+		},
+		{
+			name: "mon down with numeric names",
+			input: `
+{
+  "health": {
+    "checks": {
+      "MON_DOWN": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "1/3 mons down, quorum 0,1"
+        }
+      }
+    }
+  }
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`mons_down{cluster="ceph"} 1`),
+				regexp.MustCompile(`mons_total{cluster="ceph"} 3`),
+			},
+		},
+		{
+			name: "mon down with a single mon in quorum",
+			input: `
+{
+  "health": {
+    "checks": {
+      "MON_DOWN": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "12/13 mons down, quorum a"
+        }
+      }
+    }
+  }
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`mons_down{cluster="ceph"} 12`),
+				regexp.MustCompile(`mons_total{cluster="ceph"} 13`),
 			},
 		},
 		{
@@ -382,6 +706,47 @@ $ sudo ceph -s
 			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`slow_requests{cluster="ceph"} 3`),
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="osd.39"} 1`),
+			},
+		},
+		{
+			name: "slow ops in both summary and checks",
+			input: `
+{
+  "health": {
+    "summary": [
+      {
+        "severity": "HEALTH_WARN",
+        "summary": "3 slow ops, oldest one blocked for 1 sec, osd.39 has slow ops"
+      },
+      {
+        "severity": "HEALTH_WARN",
+        "summary": "3 slow ops, oldest one blocked for 1 sec, osd.39 has slow ops"
+      },
+      {
+        "severity": "HEALTH_WARN",
+        "summary": "7 pgs stuck degraded"
+      },
+      {
+        "severity": "HEALTH_WARN",
+        "summary": "7 pgs stuck degraded"
+      }
+    ],
+    "checks": {
+      "SLOW_OPS": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "3 slow ops, oldest one blocked for 1 sec, osd.39 has slow ops"
+        }
+      }
+    }
+  }
+}`,
+			version: `{"version":"ceph version 12.2.13 (584a20eb0237c657dc0567da126be145106aa47e) luminous (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`slow_requests{cluster="ceph"} 3`),
+				regexp.MustCompile(`stuck_degraded_pgs{cluster="ceph"} 7`),
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="osd.39"} 1`),
 			},
 		},
 		{
@@ -401,9 +766,34 @@ $ sudo ceph -s
 }`,
 			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
 			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="osd.114"} 1`),
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="osd.53"} 1`),
 				regexp.MustCompile(`slow_requests{cluster="ceph"} 18`),
 			},
 		},
+		{
+			name: "slow ops on osds and mons",
+			input: `
+{
+  "health": {
+    "checks": {
+      "SLOW_OPS": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "42 slow ops, oldest one blocked for 31 sec, daemons [osd.7,mon.ceph-mon-01,mds.cephfs-a,...] have slow ops."
+        }
+      }
+    }
+  }
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="osd.7"} 1`),
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="mon.ceph-mon-01"} 1`),
+				regexp.MustCompile(`daemon_slow_ops{cluster="ceph",daemon="mds.cephfs-a"} 1`),
+				regexp.MustCompile(`slow_requests{cluster="ceph"} 42`),
+			},
+		},
 		{
 			name: "degraded cluster",
 			input: `
@@ -447,6 +837,33 @@ $ sudo ceph -s
 				regexp.MustCompile(`health_status_interp{cluster="ceph"} 1`),
 			},
 		},
+		{
+			name: "pgs not scrubbed in time",
+			input: `
+{
+  "health": {
+    "checks": {
+      "PG_NOT_SCRUBBED": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "12 pgs not scrubbed in time"
+        }
+      },
+      "PG_NOT_DEEP_SCRUBBED": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "247 pgs not deep-scrubbed in time"
+        }
+      }
+    }
+  }
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`pgs_not_scrubbed{cluster="ceph"} 12`),
+				regexp.MustCompile(`pgs_not_deep_scrubbed{cluster="ceph"} 247`),
+			},
+		},
 		{
 			name: "too many repaired reads",
 			input: `
@@ -549,6 +966,112 @@ $ sudo ceph -s
 				regexp.MustCompile(`health_status_interp{cluster="ceph"} 1`),
 			},
 		},
+		{
+			name: "recovery throttled by norecover",
+			input: `
+{
+	"health": {
+		"checks": {
+			"OSDMAP_FLAGS": {
+				"severity": "HEALTH_WARN",
+				"summary": {
+					"message": "noout,norecover flag(s) set"
+				}
+			}
+		}
+	},
+	"pgmap": {
+		"pgs_by_state": [
+			{
+				"state_name": "active+clean",
+				"count": 90
+			},
+			{
+				"state_name": "active+recovery_wait+degraded",
+				"count": 10
+			}
+		],
+		"num_pgs": 100,
+		"degraded_objects": 2500,
+		"recovering_bytes_per_sec": 0
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`osdmap_flag_norecover{cluster="ceph"} 1`),
+				regexp.MustCompile(`degraded_pgs{cluster="ceph"} 10`),
+				regexp.MustCompile(`recovery_throttled{cluster="ceph"} 1`),
+			},
+		},
+		{
+			name: "recovery progressing despite norecover",
+			input: `
+{
+	"health": {
+		"checks": {
+			"OSDMAP_FLAGS": {
+				"severity": "HEALTH_WARN",
+				"summary": {
+					"message": "noout,norecover flag(s) set"
+				}
+			}
+		}
+	},
+	"pgmap": {
+		"pgs_by_state": [
+			{
+				"state_name": "active+clean",
+				"count": 90
+			},
+			{
+				"state_name": "active+recovery_wait+degraded",
+				"count": 10
+			}
+		],
+		"num_pgs": 100,
+		"degraded_objects": 2500,
+		"recovering_bytes_per_sec": 4194304
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`osdmap_flag_norecover{cluster="ceph"} 1`),
+				regexp.MustCompile(`degraded_pgs{cluster="ceph"} 10`),
+				regexp.MustCompile(`recovery_throttled{cluster="ceph"} 0`),
+			},
+		},
+		{
+			name: "degraded objects, pgs and ratio",
+			input: `
+{
+	"pgmap": {
+		"pgs_by_state": [
+			{
+				"state_name": "active+clean",
+				"count": 90
+			},
+			{
+				"state_name": "active+undersized+degraded",
+				"count": 6
+			},
+			{
+				"state_name": "active+recovery_wait+degraded",
+				"count": 4
+			}
+		],
+		"num_pgs": 100,
+		"degraded_objects": 2500,
+		"degraded_total": 60000,
+		"degraded_ratio": 0.041667
+	}
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`degraded_objects{cluster="ceph"} 2500`),
+				regexp.MustCompile(`degraded_pgs{cluster="ceph"} 10`),
+				regexp.MustCompile(`degraded_total_ratio{cluster="ceph"} 0.041667`),
+			},
+		},
 		{
 			name: "lots of PG data",
 			input: `
@@ -668,6 +1191,11 @@ $ sudo ceph -s
 				regexp.MustCompile(`forced_backfill_pgs{cluster="ceph"} 10`),
 				regexp.MustCompile(`down_pgs{cluster="ceph"} 37`),
 				regexp.MustCompile(`incomplete_pgs{cluster="ceph"} 2`),
+				regexp.MustCompile(`ceph_cluster_total_bytes{cluster="ceph"} 2.537720565469184e\+15`),
+				regexp.MustCompile(`ceph_cluster_data_bytes{cluster="ceph"} 1.230716754607e\+12`),
+				regexp.MustCompile(`ceph_cluster_raw_used_bytes{cluster="ceph"} 1.86123808768e\+12`),
+				regexp.MustCompile(`ceph_cluster_raw_available_bytes{cluster="ceph"} 2.535859327381504e\+15`),
+				regexp.MustCompile(`ceph_cluster_pools{cluster="ceph"} 29`),
 				regexp.MustCompile(`recovery_io_bytes{cluster="ceph"} 65536`),
 				regexp.MustCompile(`recovery_io_keys{cluster="ceph"} 25`),
 				regexp.MustCompile(`recovery_io_objects{cluster="ceph"} 140`),
@@ -696,6 +1224,63 @@ $ sudo ceph -s
 				regexp.MustCompile(`pg_state{cluster="ceph",state="incomplete"} 2`),
 			},
 		},
+		{
+			name:    "mon quorum with monmap",
+			version: `{"version":"ceph version 14.2.22 (ca74598065096e6fcbd8433c8779a2be0c889351) nautilus (stable)"}`,
+			input: `
+{
+	"quorum": [0, 1],
+	"quorum_names": ["a", "b"],
+	"monmap": {"mons": [{"rank": 0, "name": "a"}, {"rank": 1, "name": "b"}, {"rank": 2, "name": "c"}]}
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mons_total{cluster="ceph"} 3`),
+				regexp.MustCompile(`ceph_mons_quorum{cluster="ceph"} 2`),
+			},
+		},
+		{
+			name:    "mon out of quorum with a majority left",
+			version: `{"version":"ceph version 14.2.22 (ca74598065096e6fcbd8433c8779a2be0c889351) nautilus (stable)"}`,
+			input: `
+{
+	"health": {
+		"checks": {
+			"MON_DOWN": {
+				"severity": "HEALTH_WARN",
+				"summary": {"message": "1/5 mons down, quorum a,b,c,d"}
+			}
+		}
+	},
+	"quorum": [0, 1, 2, 3],
+	"quorum_names": ["a", "b", "c", "d"],
+	"monmap": {"mons": [{"rank": 0, "name": "a"}, {"rank": 1, "name": "b"}, {"rank": 2, "name": "c"}, {"rank": 3, "name": "d"}, {"rank": 4, "name": "e"}]}
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mons_total{cluster="ceph"} 5`),
+				regexp.MustCompile(`ceph_mons_quorum{cluster="ceph"} 4`),
+				regexp.MustCompile(`ceph_mons_down{cluster="ceph"} 1`),
+			},
+		},
+		{
+			name:    "mon quorum age",
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			input: `
+{
+	"fsid": "8e3b2d3c-6a1e-4a4e-9f0a-1c2b3d4e5f60",
+	"election_epoch": 42,
+	"quorum": [0, 1, 2],
+	"quorum_names": ["a", "b", "c"],
+	"quorum_age": 3600,
+	"monmap": {"epoch": 3, "num_mons": 3}
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_cluster_info{cluster="ceph",fsid="8e3b2d3c-6a1e-4a4e-9f0a-1c2b3d4e5f60",leader="a"} 1`),
+				regexp.MustCompile(`ceph_mon_election_epoch{cluster="ceph"} 42`),
+				regexp.MustCompile(`ceph_mons_quorum{cluster="ceph"} 3`),
+				regexp.MustCompile(`ceph_mon_quorum_age_seconds{cluster="ceph"} 3600`),
+				regexp.MustCompile(`ceph_mons_total{cluster="ceph"} 3`),
+			},
+		},
 		{
 			name:    "manager map",
 			version: `{"version":"ceph version 14.2.9-12-zasd (1337) pacific (stable)"}`,
@@ -745,6 +1330,7 @@ $ sudo ceph -s
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`mgrs_active{cluster="ceph"} 1`),
 				regexp.MustCompile(`mgrs{cluster="ceph"} 3`),
+				regexp.MustCompile(`mgr_multiple_active{cluster="ceph"} 0`),
 			},
 		},
 		{
@@ -770,6 +1356,35 @@ $ sudo ceph -s
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`mgrs_active{cluster="ceph"} 1`),
 				regexp.MustCompile(`mgrs{cluster="ceph"} 3`),
+				regexp.MustCompile(`mgr_multiple_active{cluster="ceph"} 0`),
+			},
+		},
+		{
+			name:    "multiple active managers",
+			version: `{"version":"ceph version 14.2.9-12-zasd (1337) nautilus (stable)"}`,
+			input: `
+{
+    "mgrmap": {
+        "epoch": 631,
+        "active_gid": 48000003,
+        "active_name": "mon03",
+        "active_addr": "10.0.0.3:6800/1746",
+        "available": true,
+        "standbys": [
+            {
+                "gid": 48000001,
+                "name": "mon01"
+            },
+            {
+                "gid": 48000004,
+                "name": "mon03"
+            }
+        ]
+    }
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`mgrs_active{cluster="ceph"} 1`),
+				regexp.MustCompile(`mgr_multiple_active{cluster="ceph"} 1`),
 			},
 		},
 		{
@@ -811,6 +1426,61 @@ $ sudo ceph -s
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`rbd_mirror_up{cluster="ceph",\s*name="prod-mon01-block01"} 1`),
 				regexp.MustCompile(`rbd_mirror_up{cluster="ceph",\s*name="prod-mon02-block01"} 1`),
+				regexp.MustCompile(`service_daemon{cluster="ceph",id="prod-mon01-block01",service="rbd-mirror"} 1`),
+			},
+		},
+		{
+			name: "service map (nfs and rgw)",
+			input: `
+{
+    "servicemap": {
+        "epoch": 112,
+        "modified": "2023-05-02T10:11:12.345678+0000",
+        "services": {
+            "nfs": {
+                "daemons": {
+                    "summary": "",
+                    "nfs.share.0.0.prod-nfs01.abcdef": {
+                        "start_epoch": 90,
+                        "start_stamp": "2023-05-01T08:00:00.000000+0000",
+                        "addr": "10.39.70.121:0/1122334455",
+                        "metadata": {}
+                    }
+                }
+            },
+            "rgw": {
+                "daemons": {
+                    "summary": "",
+                    "854125": {
+                        "start_epoch": 96,
+                        "start_stamp": "2023-05-01T08:10:00.000000+0000",
+                        "gid": 854125,
+                        "addr": "10.39.70.131:0/2233445566",
+                        "metadata": {
+                            "arch": "x86_64",
+                            "id": "prod-rgw01"
+                        }
+                    },
+                    "854200": {
+                        "start_epoch": 97,
+                        "start_stamp": "2023-05-01T08:11:00.000000+0000",
+                        "gid": 854200,
+                        "addr": "10.39.70.132:0/3344556677",
+                        "metadata": {
+                            "arch": "x86_64",
+                            "id": "prod-rgw02"
+                        }
+                    }
+                }
+            }
+        }
+    }
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`service_daemon{cluster="ceph",id="nfs.share.0.0.prod-nfs01.abcdef",service="nfs"} 1`),
+				regexp.MustCompile(`service_daemon{cluster="ceph",id="prod-rgw01",service="rgw"} 1`),
+				regexp.MustCompile(`service_daemon{cluster="ceph",id="prod-rgw02",service="rgw"} 1`),
 			},
 		},
 	} {
@@ -819,7 +1489,7 @@ $ sudo ceph -s
 			conn.On("MonCommand", mock.Anything).Return(
 				[]byte(tt.input), "", nil,
 			)
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"clusterHealth": NewClusterHealthCollector(e),
 			}
@@ -846,3 +1516,223 @@ $ sudo ceph -s
 		})
 	}
 }
+
+func TestClusterHealthCollectorCheckActive(t *testing.T) {
+	status := `
+{
+	"health": {
+		"status": "HEALTH_WARN",
+		"checks": {
+			"MON_DOWN": {"severity": "HEALTH_WARN", "summary": {"message": "1/3 mons down, quorum a,b"}},
+			"SOME_NEW_CHECK": {"severity": "HEALTH_ERR", "summary": {"message": "something new is broken"}}
+		}
+	}
+}`
+
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+	conn.On("MonCommand", mock.Anything).Return(
+		func([]byte) []byte { return []byte(status) }, "", nil,
+	)
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	e.Version = Pacific
+	e.cc = map[string]versionedCollector{
+		"clusterHealth": NewClusterHealthCollector(e),
+	}
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	scrape := func() []byte {
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		buf, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return buf
+	}
+
+	buf := scrape()
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`ceph_health_check_active{cluster="ceph",name="MON_DOWN",severity="HEALTH_WARN"} 1`),
+		regexp.MustCompile(`ceph_health_check_active{cluster="ceph",name="SOME_NEW_CHECK",severity="HEALTH_ERR"} 1`),
+	} {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+
+	// The mon is back, its check must not be left behind.
+	status = `
+{
+	"health": {
+		"status": "HEALTH_WARN",
+		"checks": {
+			"OSD_DOWN": {"severity": "HEALTH_WARN", "summary": {"message": "1 osds down"}}
+		}
+	}
+}`
+
+	buf = scrape()
+	require.Regexp(t, `ceph_health_check_active{cluster="ceph",name="OSD_DOWN",severity="HEALTH_WARN"} 1`, string(buf))
+	require.NotRegexp(t, `name="MON_DOWN"`, string(buf))
+	require.NotRegexp(t, `name="SOME_NEW_CHECK"`, string(buf))
+}
+
+func TestClusterHealthCollectorIgnoredCheck(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status string
+		checks string
+		interp string
+	}{
+		{
+			// OSD_NEARFULL would be a critical warning (2) if it wasn't
+			// ignored.
+			name:   "ignored and soft warning",
+			status: "HEALTH_WARN",
+			checks: `
+			"OSD_DOWN": {"severity": "HEALTH_WARN", "summary": {"message": "1 osds down"}},
+			"OSD_NEARFULL": {"severity": "HEALTH_WARN", "summary": {"message": "1 nearfull osd(s)"}}`,
+			interp: "1",
+		},
+		{
+			name:   "only ignored",
+			status: "HEALTH_WARN",
+			checks: `
+			"POOL_NEAR_FULL": {"severity": "HEALTH_WARN", "summary": {"message": "1 pool(s) nearfull"}}`,
+			interp: "0",
+		},
+		{
+			name:   "highest of several",
+			status: "HEALTH_WARN",
+			checks: `
+			"OSD_DOWN": {"severity": "HEALTH_WARN", "summary": {"message": "1 osds down"}},
+			"MON_CLOCK_SKEW": {"severity": "HEALTH_WARN", "summary": {"message": "clock skew detected on mon.b"}},
+			"POOL_NEAR_FULL": {"severity": "HEALTH_WARN", "summary": {"message": "1 pool(s) nearfull"}}`,
+			interp: "2",
+		},
+		{
+			// Checks without a criticality are interpreted by their severity.
+			name:   "unknown error",
+			status: "HEALTH_ERR",
+			checks: `
+			"OSD_DOWN": {"severity": "HEALTH_WARN", "summary": {"message": "1 osds down"}},
+			"SOME_NEW_CHECK": {"severity": "HEALTH_ERR", "summary": {"message": "something new"}}`,
+			interp: "3",
+		},
+		{
+			name:   "no checks",
+			status: "HEALTH_WARN",
+			checks: ``,
+			interp: "2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+			conn.On("MonCommand", mock.Anything).Return([]byte(`
+{
+	"health": {
+		"status": "`+tt.status+`",
+		"checks": {`+tt.checks+`
+		}
+	}
+}`), "", nil)
+
+			e := newExporter(conn, "ceph", ExporterOptions{HealthCheckSeverity: map[string]int{"OSD_NEARFULL": 0, "POOL_NEAR_FULL": 0}}, logrus.New())
+			e.Version = Pacific
+			e.cc = map[string]versionedCollector{
+				"clusterHealth": NewClusterHealthCollector(e),
+			}
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(e))
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Regexp(t, `ceph_health_status_interp{cluster="ceph"} `+tt.interp+`\n`, string(buf))
+		})
+	}
+}
+
+func TestClusterHealthCollectorHealthDetailOnce(t *testing.T) {
+	detail := `
+{
+	"checks": {
+		"MON_CLOCK_SKEW": {
+			"severity": "HEALTH_WARN",
+			"summary": {"message": "clock skew detected on mon.b"},
+			"detail": [
+				{"message": "mon.b clock skew 0.0823471s > max 0.05s (latency 0.00154195s)"}
+			]
+		},
+		"DAEMON_OLD_VERSION": {
+			"severity": "HEALTH_WARN",
+			"summary": {"message": "There is a daemon running an older version of ceph", "count": 1},
+			"detail": [
+				{"message": "osd.1 is running an older version of ceph: 16.2.10"}
+			]
+		}
+	}
+}`
+
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+	isHealthDetail := isMonCommand(map[string]interface{}{
+		"prefix": "health",
+		"detail": "detail",
+		"format": "json",
+	})
+	conn.On("MonCommand", mock.MatchedBy(isHealthDetail)).Return([]byte(detail), "", nil)
+	conn.On("MonCommand", mock.Anything).Return([]byte(`
+{
+	"health": {
+		"status": "HEALTH_WARN",
+		"checks": {
+			"MON_CLOCK_SKEW": {"severity": "HEALTH_WARN", "summary": {"message": "clock skew detected on mon.b"}},
+			"DAEMON_OLD_VERSION": {"severity": "HEALTH_WARN", "summary": {"message": "There is a daemon running an older version of ceph", "count": 1}}
+		}
+	}
+}`), "", nil)
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	e.Version = Pacific
+	e.cc = map[string]versionedCollector{
+		"clusterHealth": NewClusterHealthCollector(e),
+	}
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Regexp(t, `ceph_mon_clock_skew_seconds{cluster="ceph",mon="b"} 0.0823471\n`, string(buf))
+	require.Regexp(t, `ceph_daemon_versions{cluster="ceph",version="16.2.10"} 1\n`, string(buf))
+
+	// Both checks are served by the same `ceph health detail`.
+	detailCalls := 0
+	for _, call := range conn.Calls {
+		if call.Method == "MonCommand" && isHealthDetail(call.Arguments.Get(0).([]byte)) {
+			detailCalls++
+		}
+	}
+	require.Equal(t, 1, detailCalls)
+}
@@ -0,0 +1,163 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mdsStateActive        = "up:active"
+	mdsStateStandby       = "up:standby"
+	mdsStateStandbyReplay = "up:standby-replay"
+
+	// mdsAnyFS is the join_fscid of a standby that may take over any file
+	// system.
+	mdsAnyFS = -1
+)
+
+// MDSCollector reports the MDS daemons backing each CephFS file system, so
+// that a file system without standby redundancy can be alerted on.
+type MDSCollector struct {
+	conn   Conn
+	logger *logrus.Logger
+
+	activeDesc        *prometheus.Desc
+	standbyDesc       *prometheus.Desc
+	standbyReplayDesc *prometheus.Desc
+}
+
+// NewMDSCollector creates a new MDSCollector instance
+func NewMDSCollector(exporter *Exporter) *MDSCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+
+	return &MDSCollector{
+		conn:   exporter.Conn,
+		logger: exporter.Logger,
+
+		activeDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_mds_active_count", cephNamespace),
+			"Number of active MDS daemons of a file system",
+			[]string{"fs"},
+			labels,
+		),
+		standbyDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_mds_standby_count", cephNamespace),
+			"Number of standby MDS daemons that can take over a rank of a file system",
+			[]string{"fs"},
+			labels,
+		),
+		standbyReplayDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_mds_standby_replay_count", cephNamespace),
+			"Number of standby-replay MDS daemons following a rank of a file system",
+			[]string{"fs"},
+			labels,
+		),
+	}
+}
+
+type cephMDSInfo struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	JoinFSCID *int   `json:"join_fscid"`
+}
+
+// canJoin returns whether the MDS may take over a rank of the file system
+// with the given id. Releases before Octopus don't report join_fscid.
+func (i cephMDSInfo) canJoin(fscid int) bool {
+	return i.JoinFSCID == nil || *i.JoinFSCID == mdsAnyFS || *i.JoinFSCID == fscid
+}
+
+// cephFSDump is used rather than `ceph fs status`, whose JSON output doesn't
+// tell which file system an MDS belongs to.
+type cephFSDump struct {
+	Standbys    []cephMDSInfo `json:"standbys"`
+	Filesystems []struct {
+		ID     int `json:"id"`
+		MDSMap struct {
+			FSName string                 `json:"fs_name"`
+			Info   map[string]cephMDSInfo `json:"info"`
+		} `json:"mdsmap"`
+	} `json:"filesystems"`
+}
+
+func (m *MDSCollector) getFSDump() (*cephFSDump, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "fs dump",
+		"format": "json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := m.conn.MonCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	fsDump := &cephFSDump{}
+	if err := json.Unmarshal(buf, fsDump); err != nil {
+		return nil, err
+	}
+
+	return fsDump, nil
+}
+
+// Describe provides the metrics descriptions to Prometheus
+func (m *MDSCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.activeDesc
+	ch <- m.standbyDesc
+	ch <- m.standbyReplayDesc
+}
+
+// Collect sends all the collected metrics Prometheus.
+func (m *MDSCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	fsDump, err := m.getFSDump()
+	if err != nil {
+		m.logger.WithError(err).Error("failed to run 'ceph fs dump'")
+		return err
+	}
+
+	for _, fs := range fsDump.Filesystems {
+		var active, standby, standbyReplay float64
+		for _, info := range fs.MDSMap.Info {
+			switch info.State {
+			case mdsStateActive:
+				active++
+			case mdsStateStandbyReplay:
+				standbyReplay++
+			}
+		}
+
+		// Standbys are shared by all file systems, unless they are
+		// configured to join a particular one.
+		for _, info := range fsDump.Standbys {
+			if info.State == mdsStateStandby && info.canJoin(fs.ID) {
+				standby++
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(m.activeDesc, prometheus.GaugeValue, active, fs.MDSMap.FSName)
+		ch <- prometheus.MustNewConstMetric(m.standbyDesc, prometheus.GaugeValue, standby, fs.MDSMap.FSName)
+		ch <- prometheus.MustNewConstMetric(m.standbyReplayDesc, prometheus.GaugeValue, standbyReplay, fs.MDSMap.FSName)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMDSCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		input              string
+		version            string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name: "one active and no standby",
+			input: `
+{
+	"epoch": 12,
+	"standbys": [],
+	"filesystems": [
+		{
+			"mdsmap": {
+				"fs_name": "cephfs",
+				"max_mds": 1,
+				"info": {
+					"gid_14502": {"gid": 14502, "name": "cephfs.node01.vwxlmq", "rank": 0, "state": "up:active", "join_fscid": -1}
+				}
+			},
+			"id": 1
+		}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mds_active_count{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_mds_standby_count{cluster="ceph",fs="cephfs"} 0`),
+				regexp.MustCompile(`ceph_mds_standby_replay_count{cluster="ceph",fs="cephfs"} 0`),
+			},
+		},
+		{
+			name: "standbys shared and pinned to a file system",
+			input: `
+{
+	"epoch": 30,
+	"standbys": [
+		{"gid": 24301, "name": "node03", "rank": -1, "state": "up:standby", "join_fscid": -1},
+		{"gid": 24302, "name": "node04", "rank": -1, "state": "up:standby", "join_fscid": 2}
+	],
+	"filesystems": [
+		{
+			"mdsmap": {
+				"fs_name": "cephfs",
+				"max_mds": 2,
+				"info": {
+					"gid_14502": {"gid": 14502, "name": "node01", "rank": 0, "state": "up:active", "join_fscid": -1},
+					"gid_14503": {"gid": 14503, "name": "node02", "rank": 1, "state": "up:active", "join_fscid": -1},
+					"gid_14504": {"gid": 14504, "name": "node05", "rank": 0, "state": "up:standby-replay", "join_fscid": -1}
+				}
+			},
+			"id": 1
+		},
+		{
+			"mdsmap": {
+				"fs_name": "archive",
+				"max_mds": 1,
+				"info": {
+					"gid_14601": {"gid": 14601, "name": "node06", "rank": 0, "state": "up:active", "join_fscid": 2}
+				}
+			},
+			"id": 2
+		}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mds_active_count{cluster="ceph",fs="cephfs"} 2`),
+				regexp.MustCompile(`ceph_mds_standby_count{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_mds_standby_replay_count{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_mds_active_count{cluster="ceph",fs="archive"} 1`),
+				regexp.MustCompile(`ceph_mds_standby_count{cluster="ceph",fs="archive"} 2`),
+				regexp.MustCompile(`ceph_mds_standby_replay_count{cluster="ceph",fs="archive"} 0`),
+			},
+		},
+		{
+			name:    "no file systems",
+			input:   `{"epoch": 1, "standbys": [], "filesystems": []}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mds_`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(tt.version, "{}")
+			conn.On("MonCommand", mock.Anything).Return(
+				[]byte(tt.input), "", nil,
+			)
+
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+			e.cc = map[string]versionedCollector{
+				"mds": NewMDSCollector(e),
+			}
+			err := prometheus.Register(e)
+			require.NoError(t, err)
+			defer prometheus.Unregister(e)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), "expected %s to match", re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), "expected %s not to match", re.String())
+			}
+		})
+	}
+}
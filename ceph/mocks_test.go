@@ -79,6 +79,36 @@ func (_m *MockConn) MgrCommand(_a0 [][]byte) ([]byte, string, error) {
 	return r0, r1, r2
 }
 
+// OsdCommand provides a mock function with given fields: _a0, _a1
+func (_m *MockConn) OsdCommand(_a0 int, _a1 [][]byte) ([]byte, string, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(int, [][]byte) []byte); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(int, [][]byte) string); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(int, [][]byte) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // MonCommand provides a mock function with given fields: _a0
 func (_m *MockConn) MonCommand(_a0 []byte) ([]byte, string, error) {
 	ret := _m.Called(_a0)
@@ -16,6 +16,7 @@ package ceph
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
 
 	"github.com/Jeffail/gabs"
@@ -49,9 +50,21 @@ type MonitorCollector struct {
 	// metric can imply a significant issue in the cluster if it is not manually changed.
 	NodesinQuorum prometheus.Gauge
 
+	// MonInQuorum shows for each Monitor of the monmap whether it is in
+	// quorum, so the Monitors that dropped out, or never joined, can be told
+	// apart.
+	MonInQuorum *prometheus.GaugeVec
+
 	// CephVersions exposes a view of the `ceph versions` command.
 	CephVersions *prometheus.GaugeVec
 
+	// OSDVersions counts the OSDs running each Ceph version, ignoring build
+	// suffixes, and OSDVersionMin and OSDVersionMax flag the oldest and newest
+	// of those, so stragglers can be watched converging during an upgrade.
+	OSDVersions   *prometheus.GaugeVec
+	OSDVersionMin *prometheus.GaugeVec
+	OSDVersionMax *prometheus.GaugeVec
+
 	// CephFeatures exposes a view of the `ceph features` command.
 	CephFeatures *prometheus.GaugeVec
 }
@@ -92,6 +105,15 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 				ConstLabels: labels,
 			},
 		),
+		MonInQuorum: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "mon_in_quorum",
+				Help:        "Whether a Mon is in quorum",
+				ConstLabels: labels,
+			},
+			[]string{"monitor"},
+		),
 		CephVersions: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -101,6 +123,33 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 			},
 			[]string{"daemon", "version_tag", "sha1", "release_name"},
 		),
+		OSDVersions: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_version",
+				Help:        "Number of OSDs running a Ceph version",
+				ConstLabels: labels,
+			},
+			[]string{"version"},
+		),
+		OSDVersionMin: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_version_min",
+				Help:        "Oldest Ceph version run by an OSD, the value is always 1",
+				ConstLabels: labels,
+			},
+			[]string{"version"},
+		),
+		OSDVersionMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_version_max",
+				Help:        "Newest Ceph version run by an OSD, the value is always 1",
+				ConstLabels: labels,
+			},
+			[]string{"version"},
+		),
 		CephFeatures: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -117,7 +166,11 @@ func (m *MonitorCollector) collectorList() []prometheus.Collector {
 	return []prometheus.Collector{
 		m.ClockSkew,
 		m.Latency,
+		m.MonInQuorum,
 		m.CephVersions,
+		m.OSDVersions,
+		m.OSDVersionMin,
+		m.OSDVersionMax,
 		m.CephFeatures,
 	}
 }
@@ -136,8 +189,12 @@ type cephTimeSyncStatus struct {
 	} `json:"time_skew_status"`
 }
 
+// cephMonitorStats is the output of `ceph quorum_status`, which unlike `ceph
+// status` lists the Mons of the monmap on every release.
 type cephMonitorStats struct {
-	Quorum []int `json:"quorum"`
+	Quorum      []int      `json:"quorum"`
+	QuorumNames []string   `json:"quorum_names"`
+	MonMap      cephMonMap `json:"monmap"`
 }
 
 // Note that this is a dict with repeating keys in Luminous
@@ -152,8 +209,8 @@ func (m *MonitorCollector) collect() error {
 
 	stats := &cephMonitorStats{}
 	eg.Go(func() error {
-		// Ceph usage
-		cmd := m.cephUsageCommand()
+		// Ceph quorum status
+		cmd := m.cephQuorumStatusCommand()
 		buf, _, err := m.conn.MonCommand(cmd)
 		if err != nil {
 			m.logger.WithError(err).WithField(
@@ -255,7 +312,11 @@ func (m *MonitorCollector) collect() error {
 	// Reset daemon specifc metrics; daemons can leave the cluster
 	m.Latency.Reset()
 	m.ClockSkew.Reset()
+	m.MonInQuorum.Reset()
 	m.CephVersions.Reset()
+	m.OSDVersions.Reset()
+	m.OSDVersionMin.Reset()
+	m.OSDVersionMax.Reset()
 	m.CephFeatures.Reset()
 
 	for monNode, tstat := range timeStats.TimeChecks {
@@ -274,6 +335,13 @@ func (m *MonitorCollector) collect() error {
 
 	m.NodesinQuorum.Set(float64(len(stats.Quorum)))
 
+	for _, mon := range stats.MonMap.Mons {
+		m.MonInQuorum.WithLabelValues(mon.Name).Set(0)
+	}
+	for _, name := range stats.QuorumNames {
+		m.MonInQuorum.WithLabelValues(name).Set(1)
+	}
+
 	// Ceph versions, one loop for each daemon.
 	// In a consistent cluster, there will only be one iteration (and label set) per daemon.
 	for daemon, vers := range versions {
@@ -291,6 +359,8 @@ func (m *MonitorCollector) collect() error {
 		}
 	}
 
+	m.collectOSDVersions(versions["osd"])
+
 	// Ceph features, generic handling of arbitrary daemons
 	for daemon, groups := range features {
 		for _, group := range groups {
@@ -301,13 +371,45 @@ func (m *MonitorCollector) collect() error {
 	return nil
 }
 
-func (m *MonitorCollector) cephUsageCommand() []byte {
+// collectOSDVersions counts the OSDs by Ceph version, taken from the `osd`
+// section of `ceph versions`.
+func (m *MonitorCollector) collectOSDVersions(vers map[string]float64) {
+	var min, max *Version
+	for version, num := range vers {
+		v, err := ParseCephVersion(version)
+		if err != nil {
+			m.logger.WithError(err).WithField("version", version).Debug("unable to parse OSD version")
+			continue
+		}
+
+		m.OSDVersions.WithLabelValues(osdVersionLabel(v)).Add(num)
+
+		if min == nil || !v.IsAtLeast(min) {
+			min = v
+		}
+		if max == nil || v.IsAtLeast(max) {
+			max = v
+		}
+	}
+
+	if min != nil {
+		m.OSDVersionMin.WithLabelValues(osdVersionLabel(min)).Set(1)
+		m.OSDVersionMax.WithLabelValues(osdVersionLabel(max)).Set(1)
+	}
+}
+
+// osdVersionLabel returns the version without its build suffix, e.g. 16.2.11.
+func osdVersionLabel(v *Version) string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (m *MonitorCollector) cephQuorumStatusCommand() []byte {
 	cmd, err := json.Marshal(map[string]interface{}{
-		"prefix": "status",
+		"prefix": "quorum_status",
 		"format": "json",
 	})
 	if err != nil {
-		m.logger.WithError(err).Panic("error marshalling ceph status")
+		m.logger.WithError(err).Panic("error marshalling ceph quorum_status")
 	}
 	return cmd
 }
@@ -348,11 +450,11 @@ func (m *MonitorCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect extracts the given metrics from the Monitors and sends it to the prometheus
 // channel.
-func (m *MonitorCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (m *MonitorCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
 	m.logger.Debug("collecting ceph monitor metrics")
 	if err := m.collect(); err != nil {
 		m.logger.WithError(err).Error("error collecting ceph monitor metrics")
-		return
+		return err
 	}
 
 	for _, metric := range m.collectorList() {
@@ -362,4 +464,6 @@ func (m *MonitorCollector) Collect(ch chan<- prometheus.Metric, version *Version
 	for _, metric := range m.metricsList() {
 		ch <- metric
 	}
+
+	return nil
 }
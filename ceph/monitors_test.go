@@ -86,6 +86,30 @@ func TestMonitorCollector(t *testing.T) {
 				regexp.MustCompile(`ceph_monitor_quorum_count{cluster="ceph"} 5`),
 			},
 		},
+		{
+			input: `
+{
+    "election_epoch": 72,
+    "quorum": [0, 1],
+    "quorum_names": ["a", "b"],
+    "monmap": {
+        "epoch": 3,
+        "mons": [
+            {"rank": 0, "name": "a", "addr": "10.0.0.1:6789/0"},
+            {"rank": 1, "name": "b", "addr": "10.0.0.2:6789/0"},
+            {"rank": 2, "name": "c", "addr": "10.0.0.3:6789/0"}
+        ]
+    }
+}
+`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			regexes: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_monitor_quorum_count{cluster="ceph"} 2`),
+				regexp.MustCompile(`ceph_mon_in_quorum{cluster="ceph",monitor="a"} 1`),
+				regexp.MustCompile(`ceph_mon_in_quorum{cluster="ceph",monitor="b"} 1`),
+				regexp.MustCompile(`ceph_mon_in_quorum{cluster="ceph",monitor="c"} 0`),
+			},
+		},
 	} {
 		func() {
 			conn := setupVersionMocks(tt.version, "{}")
@@ -93,7 +117,7 @@ func TestMonitorCollector(t *testing.T) {
 				[]byte(tt.input), "", nil,
 			)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"mon": NewMonitorCollector(e),
 			}
@@ -224,7 +248,7 @@ func TestMonitorTimeSyncStats(t *testing.T) {
 				[]byte(tt.input), "", nil,
 			)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"mon": NewMonitorCollector(e),
 			}
@@ -284,6 +308,32 @@ func TestMonitorCephVersions(t *testing.T) {
 				regexp.MustCompile(`ceph_versions{cluster="ceph",daemon="rgw",release_name="luminous",sha1="58a2283da6a62d2cc1600d4a9928a0799d63c7c9",version_tag="12.2.5-8-g58a2283"} 4`),
 			},
 		},
+		{
+			input: `
+{
+    "mon": {
+        "ceph version 16.2.11 (3cf40e2dca667f68c6ce3ff5cd94f01e711af894) pacific (stable)": 3
+    },
+    "osd": {
+        "ceph version 16.2.9 (4c3647a322c0ff5a1dd2344e039859dcbd28c830) pacific (stable)": 7,
+        "ceph version 16.2.11 (3cf40e2dca667f68c6ce3ff5cd94f01e711af894) pacific (stable)": 40,
+        "ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)": 1
+    },
+    "overall": {
+        "ceph version 16.2.9 (4c3647a322c0ff5a1dd2344e039859dcbd28c830) pacific (stable)": 7,
+        "ceph version 16.2.11 (3cf40e2dca667f68c6ce3ff5cd94f01e711af894) pacific (stable)": 43,
+        "ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)": 1
+    }
+}
+`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_version{cluster="ceph",version="16.2.9"} 7`),
+				regexp.MustCompile(`ceph_osd_version{cluster="ceph",version="16.2.11"} 41`),
+				regexp.MustCompile(`ceph_osd_version_min{cluster="ceph",version="16.2.9"} 1`),
+				regexp.MustCompile(`ceph_osd_version_max{cluster="ceph",version="16.2.11"} 1`),
+			},
+		},
 	} {
 		func() {
 			conn := setupVersionMocks(tt.version, tt.input)
@@ -291,7 +341,7 @@ func TestMonitorCephVersions(t *testing.T) {
 				[]byte(tt.input), "", nil,
 			)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"mon": NewMonitorCollector(e),
 			}
@@ -310,7 +360,7 @@ func TestMonitorCephVersions(t *testing.T) {
 			require.NoError(t, err)
 
 			for _, re := range tt.reMatch {
-				require.True(t, re.Match(buf))
+				require.True(t, re.Match(buf), "expected %s to match", re.String())
 			}
 		}()
 	}
@@ -377,7 +427,7 @@ func TestMonitorCephFeatures(t *testing.T) {
 				[]byte(tt.input), "", nil,
 			)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"mon": NewMonitorCollector(e),
 			}
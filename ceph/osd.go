@@ -19,6 +19,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,8 +38,48 @@ const (
 	scrubStateDeepScrubbing = 2
 
 	oldestInactivePGUpdatePeriod = 10 * time.Second
+
+	// PGs peering for longer than peeringPGThreshold are reported by PG ID,
+	// the peeringPGTopN longest only to bound the number of series.
+	peeringPGThreshold = time.Minute
+	peeringPGTopN      = 10
+
+	// Blocklist entries are reported by address for the blocklistTopN
+	// expiring last only, the blocklist can hold any number of clients.
+	blocklistTopN = 50
+
+	// OSDs back up keep reading 0 as down for osdDownRecoveredRetention, long
+	// enough for alerts on them to resolve, after which they are dropped so
+	// that the series of every status an OSD ever had don't pile up.
+	osdDownRecoveredRetention = 15 * time.Minute
+
+	// osdPerfDumpConcurrency bounds how many OSD daemons are queried at once
+	// for their perf counters or ops in flight. A scrape takes about one command timeout per
+	// osdPerfDumpConcurrency unresponsive OSDs, so it stays within the scrape
+	// timeout unless many OSDs hang at once.
+	osdPerfDumpConcurrency = 16
+
+	// osdDefaultOpComplaintTime is Ceph's default osd_op_complaint_time, in
+	// seconds, used when the configured one can't be fetched.
+	osdDefaultOpComplaintTime = 30
+
+	// pgQueryConcurrency bounds how many backfilling PGs are queried at
+	// once, in the same way as osdPerfDumpConcurrency.
+	pgQueryConcurrency = 16
 )
 
+// osdConfigOptions is the curated list of OSD recovery, scrub and snaptrim
+// tunables that are exposed, along with osd_op_complaint_time for the slow
+// ops. It is fixed so that the number of mon commands per scrape stays
+// bounded.
+var osdConfigOptions = []string{
+	"osd_max_backfills",
+	"osd_recovery_max_active",
+	"osd_scrub_sleep",
+	"osd_snap_trim_sleep",
+	"osd_op_complaint_time",
+}
+
 // OSDCollector displays statistics about OSD in the Ceph cluster.
 // An important aspect of monitoring OSDs is to ensure that when the cluster is
 // up and running that all OSDs that are in the cluster are up and running, too
@@ -45,20 +87,100 @@ type OSDCollector struct {
 	conn   Conn
 	logger *logrus.Logger
 
+	// pgDump is the PG dump of the scrape, shared with PoolUsageCollector.
+	pgDump *scrapePGDump
+
+	versionGates
+
+	// opQueue enables querying each OSD daemon for its op queue and ops in
+	// flight
+	opQueue bool
+
+	// perfDump enables querying each OSD daemon for its op latencies
+	perfDump bool
+
+	// pgQuery enables querying each backfilling PG for its recovery progress
+	pgQuery bool
+
+	// pgBackfills holds the backfilling PGs seen at the previous collect, to
+	// count the objects backfilled since
+	pgBackfills map[string]*pgBackfill
+
 	// osdScrubCache holds the cache of previous PG scrubs
 	osdScrubCache map[int]int
 
+	// osdDownCache holds when the OSDs previously reported down were last
+	// seen down, so that they read 0 once they are back rather than
+	// disappearing
+	osdDownCache map[osdDownSeries]time.Time
+
+	// osdStates holds the up and in state of each OSD at the previous
+	// collect, to count the changes since
+	osdStates map[int64]osdState
+
 	// osdLabelsCache holds a cache of osd labels
 	osdLabelsCache map[int64]*cephOSDLabel
 
+	// osdMetadataCache holds the OSDs' metadata, a failed `osd metadata`
+	// keeps serving the previous one
+	osdMetadataCache []cephOSDMetadata
+
+	// osdLabelsRefreshed is when osdLabelsCache was last rebuilt successfully,
+	// a failed rebuild keeps serving the previous labels
+	osdLabelsRefreshed time.Time
+
+	// now is time.Now, swapped out in tests.
+	now func() time.Time
+
+	// deviceClasses holds the device classes of the OSDs to report on, OSDs
+	// of any class are reported when it is empty
+	deviceClasses map[string]bool
+
+	// labelRelabels rewrite the host, rack and root labels of the OSDs when
+	// the OSD tree is read
+	labelRelabels []OSDLabelRelabel
+
+	// scrubbingPGs holds the PGs that were scrubbing at the previous collect,
+	// to count the scrubs that have finished since
+	scrubbingPGs map[string]bool
+
 	// oldestInactivePGMap keeps track of how long we've known
 	// a PG to not have an active state in it.
 	oldestInactivePGMap map[string]time.Time
 
+	// peeringPGMap keeps track of how long we've known a PG to be peering,
+	// it is only used by oldestInactivePGLoop.
+	peeringPGMap map[string]time.Time
+
+	// peeringPGThreshold and peeringPGTopN bound the PGs reported as stuck
+	// peering, swapped out in tests.
+	peeringPGThreshold time.Duration
+	peeringPGTopN      int
+
+	// peeringPGs holds the PGs stuck peering with their peering duration in
+	// seconds, as last updated by oldestInactivePGLoop.
+	peeringPGs   map[string]float64
+	peeringPGsMu sync.Mutex
+
+	// maxBackfills, numInOSDs and numRecoveringPGs are found by the
+	// concurrent collects of a scrape for RecoveryHeadroomDesc, they are -1
+	// if unknown.
+	maxBackfills     float64
+	numInOSDs        float64
+	numRecoveringPGs float64
+
+	// opComplaintTime is the configured osd_op_complaint_time, after which
+	// an op in flight is reported as slow.
+	opComplaintTime float64
+
 	// CrushWeight is a persistent setting, and it affects how CRUSH assigns data to OSDs.
 	// It displays the CRUSH weight for the OSD
 	CrushWeight *prometheus.GaugeVec
 
+	// CrushWeightSet displays the weight of the OSD in a CRUSH weight-set,
+	// i.e. the weight the balancer has adjusted the CRUSH weight to
+	CrushWeightSet *prometheus.GaugeVec
+
 	// Depth displays the OSD's level of hierarchy in the CRUSH map
 	Depth *prometheus.GaugeVec
 
@@ -78,6 +200,10 @@ type OSDCollector struct {
 	// Utilization displays current utilization of the OSD
 	Utilization *prometheus.GaugeVec
 
+	// UsedRatio displays the fraction of the OSD's capacity that is in use.
+	// Unlike Utilization, which is a percentage, this is a plain ratio.
+	UsedRatio *prometheus.GaugeVec
+
 	// Variance displays current variance of the OSD from the standard utilization
 	Variance *prometheus.GaugeVec
 
@@ -100,6 +226,13 @@ type OSDCollector struct {
 	// OSDUp displays the Up state of the OSD
 	OSDUp *prometheus.GaugeVec
 
+	// OSDPrimaryAffinity displays the primary affinity of an OSD
+	OSDPrimaryAffinity *prometheus.GaugeVec
+
+	// OSDStateChanges counts the changes of the up and in state of an OSD
+	// seen between collects, to spot flapping OSDs
+	OSDStateChanges *prometheus.CounterVec
+
 	// OSDMetaData displays metadata of an OSD
 	OSDMetadata *prometheus.GaugeVec
 
@@ -121,9 +254,62 @@ type OSDCollector struct {
 	// OSDBackfillFull flags if an OSD is backfill full
 	OSDBackfillFull *prometheus.GaugeVec
 
+	// ClassNearFullCount counts the nearfull, backfillfull or full OSDs of
+	// each device class, to show capacity pressure on a single tier.
+	ClassNearFullCount *prometheus.GaugeVec
+
 	// OSDDownDesc displays OSDs present in the cluster in "down" state
 	OSDDownDesc *prometheus.Desc
 
+	// OSDDownReasonDesc joins OSDs in "down" state with their host, so that
+	// a whole host failure shows up as many down OSDs sharing one host
+	OSDDownReasonDesc *prometheus.Desc
+
+	// HostOSDCount displays the number of OSDs on each host by device class
+	HostOSDCount *prometheus.GaugeVec
+
+	// BlocklistEntries displays the number of client addresses in the OSD
+	// blocklist
+	BlocklistEntries prometheus.Gauge
+
+	// BlocklistExpiry displays the unix timestamp at which a client address is
+	// removed from the OSD blocklist
+	BlocklistExpiry *prometheus.GaugeVec
+
+	// BlocklistExpiredEntries displays the number of blocklist entries whose
+	// expiry has already passed but which are still listed
+	BlocklistExpiredEntries prometheus.Gauge
+
+	// BlocklistLatestExpiry displays the unix timestamp at which the last
+	// blocklist entry expires
+	BlocklistLatestExpiry prometheus.Gauge
+
+	// ConfigValue displays the configured value of OSD tunables, so that
+	// recovery and scrub throughput can be correlated with current tuning
+	ConfigValue *prometheus.GaugeVec
+
+	// OpReadLatencyDesc and OpWriteLatencyDesc display the total latency
+	// and count of the client reads and writes an OSD completed since it
+	// started, which averaged over any interval give the op latency.
+	OpReadLatencyDesc  *prometheus.Desc
+	OpWriteLatencyDesc *prometheus.Desc
+
+	// BluestoreAllocated and BluestoreStored display the space BlueStore
+	// allocated on disk for an OSD's data, rounded up to its min_alloc_size,
+	// and the size of the data itself.
+	BluestoreAllocated *prometheus.GaugeVec
+	BluestoreStored    *prometheus.GaugeVec
+
+	// OpsInProgress displays the number of ops an OSD is currently working
+	// on, taken from the OSD daemon's perf counters
+	OpsInProgress *prometheus.GaugeVec
+
+	// OpsInFlight and SlowOps display the ops an OSD is tracking as in
+	// flight, and those of them older than osd_op_complaint_time, taken from
+	// the OSD daemon's dump_ops_in_flight
+	OpsInFlight *prometheus.GaugeVec
+	SlowOps     *prometheus.GaugeVec
+
 	// TotalBytes displays total bytes in all OSDs
 	TotalBytes prometheus.Gauge
 
@@ -140,10 +326,66 @@ type OSDCollector struct {
 	// labeled by OSD
 	ScrubbingStateDesc *prometheus.Desc
 
+	// IdleDesc flags up and in OSDs that are not the acting primary for any
+	// PG, which usually points at CRUSH or weight problems.
+	IdleDesc *prometheus.Desc
+
+	// LabelCacheAgeDesc is the time since the OSD labels (host, rack, root
+	// etc.) were last refreshed from the OSD tree.
+	LabelCacheAgeDesc *prometheus.Desc
+
+	// RemappedPGsDesc counts the remapped PGs whose acting set includes an
+	// OSD, i.e. the OSDs data is currently being moved off of or onto.
+	RemappedPGsDesc *prometheus.Desc
+
+	// PGsUnavailableDesc counts the down, incomplete or stale PGs whose
+	// acting set includes an OSD, i.e. the OSDs blocking PG availability.
+	PGsUnavailableDesc *prometheus.Desc
+
+	// HealthyPGsDesc counts the active+clean PGs whose acting set includes
+	// an OSD
+	HealthyPGsDesc *prometheus.Desc
+
+	// PrimaryPGsDesc counts the PGs an OSD is the acting primary of, which
+	// serves all their reads.
+	PrimaryPGsDesc *prometheus.Desc
+
+	// BackfillingPGsDesc and BackfillWaitPGsDesc count the PGs backfilling,
+	// or waiting to, to an OSD, i.e. with the OSD in their up but not their
+	// acting set.
+	BackfillingPGsDesc  *prometheus.Desc
+	BackfillWaitPGsDesc *prometheus.Desc
+
+	// DegradedPGsDesc counts the degraded PGs whose acting set includes an
+	// OSD.
+	DegradedPGsDesc *prometheus.Desc
+
+	// MisplacedPGsDesc counts the remapped PGs whose acting set includes an
+	// OSD that aren't clean yet, i.e. that still have objects to move to
+	// their up set. A PG stays remapped and clean when CRUSH can't map it.
+	MisplacedPGsDesc *prometheus.Desc
+
+	// PoolPGsDesc and PoolPGsByStateDesc count the PGs of each pool, by pool
+	// ID as the PG dump doesn't name the pools.
+	PoolPGsDesc        *prometheus.Desc
+	PoolPGsByStateDesc *prometheus.Desc
+
+	// SnaptrimmingPGsDesc counts the PGs trimming or waiting to trim
+	// snapshots whose acting set includes an OSD.
+	SnaptrimmingPGsDesc *prometheus.Desc
+
+	// RecoveryHeadroomDesc is the share of the cluster's backfill and
+	// recovery reservations (osd_max_backfills per in OSD) that is unused.
+	RecoveryHeadroomDesc *prometheus.Desc
+
 	// PGObjectsRecoveredDesc displays total number of objects recovered in a PG
 	PGObjectsRecoveredDesc *prometheus.Desc
 
-	// OSDObjectsBackfilled displays average number of objects backfilled in an OSD
+	// PGPeeringDurationDesc gives the PG IDs of the PGs that have been
+	// peering for the longest, along with how long they have been peering.
+	PGPeeringDurationDesc *prometheus.Desc
+
+	// OSDObjectsBackfilled counts the objects backfilled to an OSD by a PG
 	OSDObjectsBackfilled *prometheus.CounterVec
 
 	// OldestInactivePG gives us the amount of time that the oldest inactive PG
@@ -151,6 +393,18 @@ type OSDCollector struct {
 	// (such as when issuing a bunch of upmaps or weight changes) and a single PG
 	// stuck peering, for example.
 	OldestInactivePG prometheus.Gauge
+
+	// OldestUnscrubbedPG gives us the age in seconds of the oldest
+	// last_scrub_stamp across all PGs, i.e. our worst scrub backlog.
+	OldestUnscrubbedPG prometheus.Gauge
+
+	// BackfillBytesRemaining estimates the bytes that are still to be copied
+	// by backfilling PGs, including those waiting to backfill.
+	BackfillBytesRemaining prometheus.Gauge
+
+	// ScrubsCompleted counts the PGs seen leaving the scrubbing state between
+	// collects, i.e. the scrub throughput.
+	ScrubsCompleted prometheus.Counter
 }
 
 // NewOSDCollector creates an instance of the OSDCollector and instantiates the
@@ -159,15 +413,32 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
 	osdLabels := []string{"osd", "device_class", "host", "rack", "root"}
-	osdMetadataLabels := []string{"osd", "objectstore", "ceph_version_when_created", "created_at"}
+	weightSetLabels := []string{"osd", "device_class", "host", "rack", "root", "weight_set"}
+	osdMetadataLabels := []string{"osd", "objectstore", "ceph_version_when_created", "created_at",
+		"ceph_version", "device_class", "bluestore_bdev_type", "db_device", "wal_device"}
 
 	o := &OSDCollector{
-		conn:   exporter.Conn,
-		logger: exporter.Logger,
+		conn:    exporter.Conn,
+		logger:  exporter.Logger,
+		pgDump:  exporter.pgDump,
+		opQueue: exporter.OSDOpQueue,
+
+		perfDump: exporter.OSDPerfDump,
+		pgQuery:  exporter.PGQuery,
 
 		osdScrubCache:       make(map[int]int),
+		osdDownCache:        make(map[osdDownSeries]time.Time),
+		osdStates:           make(map[int64]osdState),
+		pgBackfills:         make(map[string]*pgBackfill),
 		osdLabelsCache:      make(map[int64]*cephOSDLabel),
 		oldestInactivePGMap: make(map[string]time.Time),
+		peeringPGMap:        make(map[string]time.Time),
+		peeringPGThreshold:  peeringPGThreshold,
+		peeringPGTopN:       peeringPGTopN,
+		scrubbingPGs:        make(map[string]bool),
+		deviceClasses:       make(map[string]bool),
+		labelRelabels:       exporter.OSDLabelRelabels,
+		now:                 time.Now,
 
 		CrushWeight: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -179,6 +450,16 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			osdLabels,
 		),
 
+		CrushWeightSet: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_crush_weight_set",
+				Help:        "OSD weight in a CRUSH weight-set",
+				ConstLabels: labels,
+			},
+			weightSetLabels,
+		),
+
 		Depth: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -239,6 +520,16 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			osdLabels,
 		),
 
+		UsedRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_used_ratio",
+				Help:        "Ratio of used to total bytes of the OSD",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
 		Variance: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -343,6 +634,28 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			osdLabels,
 		),
 
+		OSDPrimaryAffinity: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_primary_affinity",
+				Help:        "OSD Primary Affinity",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
+		// Only labelled by the OSD, so that the count isn't restarted when
+		// an OSD moves in the CRUSH tree.
+		OSDStateChanges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_state_changes_total",
+				Help:        "Number of times the up or in state of an OSD changed between scrapes",
+				ConstLabels: labels,
+			},
+			[]string{"osd"},
+		),
+
 		OSDFullRatio: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -400,6 +713,16 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			osdLabels,
 		),
 
+		ClassNearFullCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_class_nearfull_count",
+				Help:        "Number of OSDs of a device class that are nearfull, backfillfull or full",
+				ConstLabels: labels,
+			},
+			[]string{"device_class"},
+		),
+
 		OSDMetadata: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -417,6 +740,134 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			labels,
 		),
 
+		OSDDownReasonDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_down_reason", cephNamespace),
+			"OSDs down in the cluster along with the host they are on",
+			[]string{"osd", "host"},
+			labels,
+		),
+
+		OpReadLatencyDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_op_read_latency_seconds", cephNamespace),
+			"Latency of the client reads completed by the OSD since it started",
+			osdLabels,
+			labels,
+		),
+
+		OpWriteLatencyDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_op_write_latency_seconds", cephNamespace),
+			"Latency of the client writes completed by the OSD since it started",
+			osdLabels,
+			labels,
+		),
+
+		BluestoreAllocated: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_bluestore_allocated_bytes",
+				Help:        "Bytes allocated by BlueStore for the OSD's data, including the min_alloc_size overhead",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
+		BluestoreStored: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_bluestore_stored_bytes",
+				Help:        "Bytes of data stored by BlueStore for the OSD",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
+		OpsInProgress: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_ops_in_progress",
+				Help:        "Number of ops currently in progress on the OSD",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
+		OpsInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_ops_in_flight",
+				Help:        "Number of ops in flight on the OSD",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
+		SlowOps: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_slow_ops",
+				Help:        "Number of ops in flight on the OSD for longer than osd_op_complaint_time",
+				ConstLabels: labels,
+			},
+			osdLabels,
+		),
+
+		BlocklistEntries: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_blocklist_entries",
+				Help:        "Number of client addresses in the OSD blocklist",
+				ConstLabels: labels,
+			},
+		),
+
+		BlocklistExpiry: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_blocklist_expiry_timestamp_seconds",
+				Help:        "Unix timestamp at which a client address expires from the OSD blocklist, for the entries expiring last only",
+				ConstLabels: labels,
+			},
+			[]string{"addr"},
+		),
+
+		BlocklistExpiredEntries: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_blocklist_expired_entries",
+				Help:        "Number of OSD blocklist entries that are past their expiry but still listed",
+				ConstLabels: labels,
+			},
+		),
+
+		BlocklistLatestExpiry: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_blocklist_latest_expiry_timestamp_seconds",
+				Help:        "Unix timestamp at which the last OSD blocklist entry expires",
+				ConstLabels: labels,
+			},
+		),
+
+		ConfigValue: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "osd_config_value",
+				Help:        "Configured value of OSD recovery, scrub and snaptrim tunables",
+				ConstLabels: labels,
+			},
+			[]string{"option"},
+		),
+
+		HostOSDCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "host_osd_count",
+				Help:        "Number of OSDs on a host by device class",
+				ConstLabels: labels,
+			},
+			[]string{"host", "device_class"},
+		),
+
 		ScrubbingStateDesc: prometheus.NewDesc(
 			fmt.Sprintf("%s_osd_scrub_state", cephNamespace),
 			"State of OSDs involved in a scrub",
@@ -424,6 +875,104 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			labels,
 		),
 
+		IdleDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_idle", cephNamespace),
+			"Whether an up and in OSD is the acting primary for no PGs",
+			osdLabels,
+			labels,
+		),
+
+		LabelCacheAgeDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_exporter_osd_label_cache_age_seconds", cephNamespace),
+			"Seconds since the OSD labels were last refreshed from the OSD tree",
+			nil,
+			labels,
+		),
+
+		RemappedPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_remapped_pgs", cephNamespace),
+			"Number of remapped PGs whose acting set includes the OSD",
+			osdLabels,
+			labels,
+		),
+
+		PGsUnavailableDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_pgs_unavailable", cephNamespace),
+			"Number of down, incomplete or stale PGs whose acting set includes the OSD",
+			osdLabels,
+			labels,
+		),
+
+		SnaptrimmingPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_snaptrimming_pgs", cephNamespace),
+			"Number of PGs in snaptrim or snaptrim_wait state whose acting set includes the OSD",
+			osdLabels,
+			labels,
+		),
+
+		HealthyPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_healthy_pgs", cephNamespace),
+			"Number of active+clean PGs whose acting set includes the OSD",
+			osdLabels,
+			labels,
+		),
+
+		PrimaryPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_primary_pgs", cephNamespace),
+			"Number of PGs the OSD is the acting primary of",
+			osdLabels,
+			labels,
+		),
+
+		BackfillingPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_backfilling_pgs", cephNamespace),
+			"Number of PGs backfilling to the OSD",
+			osdLabels,
+			labels,
+		),
+
+		BackfillWaitPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_backfill_wait_pgs", cephNamespace),
+			"Number of PGs waiting to backfill to the OSD",
+			osdLabels,
+			labels,
+		),
+
+		DegradedPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_degraded_pgs", cephNamespace),
+			"Number of degraded PGs whose acting set includes the OSD",
+			osdLabels,
+			labels,
+		),
+
+		MisplacedPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_osd_misplaced_pgs", cephNamespace),
+			"Number of remapped PGs with misplaced objects whose acting set includes the OSD",
+			osdLabels,
+			labels,
+		),
+
+		PoolPGsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_pgs", cephNamespace),
+			"Number of PGs of a pool",
+			[]string{"pool_id"},
+			labels,
+		),
+
+		PoolPGsByStateDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_pgs_by_state", cephNamespace),
+			"Number of PGs of a pool by state",
+			[]string{"pool_id", "state"},
+			labels,
+		),
+
+		RecoveryHeadroomDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_cluster_recovery_throttle_headroom", cephNamespace),
+			"Share of osd_max_backfills times the in OSDs not taken by recovering or backfilling PGs",
+			nil,
+			labels,
+		),
+
 		PGObjectsRecoveredDesc: prometheus.NewDesc(
 			fmt.Sprintf("%s_pg_objects_recovered", cephNamespace),
 			"Number of objects recovered in a PG",
@@ -431,11 +980,18 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 			labels,
 		),
 
+		PGPeeringDurationDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pg_peering_duration_seconds", cephNamespace),
+			"The amount of time in seconds that a PG stuck peering has been peering for, for the longest peering PGs only",
+			[]string{"pgid"},
+			labels,
+		),
+
 		OSDObjectsBackfilled: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace:   cephNamespace,
 				Name:        "osd_objects_backfilled",
-				Help:        "Average number of objects backfilled in an OSD",
+				Help:        "Number of objects a backfilling PG recovered while backfilling to the OSD",
 				ConstLabels: labels,
 			},
 			append([]string{"pgid"}, osdLabels...),
@@ -449,21 +1005,53 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 				ConstLabels: labels,
 			},
 		),
+
+		OldestUnscrubbedPG: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "pg_oldest_unscrubbed_age_seconds",
+				Help:        "The amount of time in seconds since the least recently scrubbed PG was last scrubbed",
+				ConstLabels: labels,
+			},
+		),
+
+		BackfillBytesRemaining: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "backfill_bytes_remaining",
+				Help:        "Estimated bytes remaining to be backfilled across all backfilling PGs",
+				ConstLabels: labels,
+			},
+		),
+
+		ScrubsCompleted: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   cephNamespace,
+				Name:        "scrubs_completed_total",
+				Help:        "Number of PG scrubs seen completing",
+				ConstLabels: labels,
+			},
+		),
+	}
+
+	for _, class := range exporter.OSDDeviceClassAllowlist {
+		o.deviceClasses[class] = true
 	}
 
-	go o.oldestInactivePGLoop()
 	return o
 }
 
 func (o *OSDCollector) collectorList() []prometheus.Collector {
 	return []prometheus.Collector{
 		o.CrushWeight,
+		o.CrushWeightSet,
 		o.Depth,
 		o.Reweight,
 		o.Bytes,
 		o.UsedBytes,
 		o.AvailBytes,
 		o.Utilization,
+		o.UsedRatio,
 		o.Variance,
 		o.Pgs,
 		o.PgUpmapItemsTotal,
@@ -475,6 +1063,8 @@ func (o *OSDCollector) collectorList() []prometheus.Collector {
 		o.ApplyLatency,
 		o.OSDIn,
 		o.OSDUp,
+		o.OSDPrimaryAffinity,
+		o.OSDStateChanges,
 		o.OSDMetadata,
 		o.OSDFullRatio,
 		o.OSDNearFullRatio,
@@ -482,8 +1072,23 @@ func (o *OSDCollector) collectorList() []prometheus.Collector {
 		o.OSDFull,
 		o.OSDNearFull,
 		o.OSDBackfillFull,
+		o.ClassNearFullCount,
+		o.HostOSDCount,
+		o.ConfigValue,
+		o.OpsInProgress,
+		o.OpsInFlight,
+		o.SlowOps,
+		o.BluestoreAllocated,
+		o.BluestoreStored,
+		o.BlocklistEntries,
+		o.BlocklistExpiry,
+		o.BlocklistExpiredEntries,
+		o.BlocklistLatestExpiry,
 		o.OSDObjectsBackfilled,
 		o.OldestInactivePG,
+		o.OldestUnscrubbedPG,
+		o.BackfillBytesRemaining,
+		o.ScrubsCompleted,
 	}
 }
 
@@ -525,10 +1130,11 @@ type CephOSDPerfStat struct {
 
 type cephOSDDump struct {
 	OSDs []struct {
-		OSD   json.Number `json:"osd"`
-		Up    json.Number `json:"up"`
-		In    json.Number `json:"in"`
-		State []string    `json:"state"`
+		OSD             json.Number `json:"osd"`
+		Up              json.Number `json:"up"`
+		In              json.Number `json:"in"`
+		PrimaryAffinity json.Number `json:"primary_affinity"`
+		State           []string    `json:"state"`
 	} `json:"osds"`
 
 	PgUpmapItems []struct {
@@ -552,6 +1158,7 @@ type cephOSDTree struct {
 		Status      string  `json:"status"`
 		Class       string  `json:"device_class"`
 		CrushWeight float64 `json:"crush_weight"`
+		Reweight    float64 `json:"reweight"`
 		Children    []int64 `json:"children"`
 	} `json:"nodes"`
 	Stray []struct {
@@ -576,33 +1183,341 @@ type cephOSDTreeDown struct {
 	Stray []osdNode `json:"stray"`
 }
 
-type cephPGDumpBrief struct {
-	PGStats []struct {
-		PGID          string `json:"pgid"`
-		ActingPrimary int64  `json:"acting_primary"`
-		Acting        []int  `json:"acting"`
-		State         string `json:"state"`
-	} `json:"pg_stats"`
+// osdState is the up and in state of an OSD in the OSD map.
+type osdState struct {
+	up, in float64
 }
 
-type cephOSDLabel struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Type        string  `json:"type"`
-	Status      string  `json:"status"`
-	DeviceClass string  `json:"device_class"`
-	CrushWeight float64 `json:"crush_weight"`
-	Root        string  `json:"root"`
-	Rack        string  `json:"rack"`
+// osdDownSeries are the label values of an OSD reported down.
+type osdDownSeries struct {
+	id          int64
+	status      string
+	osd         string
+	deviceClass string
+	host        string
+	rack        string
+	root        string
+}
+
+// cephPGQuery holds the fields of `pg query` that the backfill progress of a
+// PG is read from.
+type cephPGQuery struct {
+	Info struct {
+		Stats struct {
+			StatSum struct {
+				NumObjectsRecovered float64 `json:"num_objects_recovered"`
+			} `json:"stat_sum"`
+		} `json:"stats"`
+	} `json:"info"`
+	RecoveryState []struct {
+		RecoveryProgress *struct {
+			BackfillTargets []string `json:"backfill_targets"`
+		} `json:"recovery_progress"`
+	} `json:"recovery_state"`
+}
+
+// backfillTargets returns the OSDs the PG is backfilling to. Shards of
+// erasure coded PGs are listed as the OSD followed by the shard, e.g. 7(1).
+func (q *cephPGQuery) backfillTargets() []int64 {
+	seen := make(map[int64]bool)
+	var targets []int64
+	for _, state := range q.RecoveryState {
+		if state.RecoveryProgress == nil {
+			continue
+		}
+
+		for _, target := range state.RecoveryProgress.BackfillTargets {
+			id, err := strconv.ParseInt(strings.SplitN(target, "(", 2)[0], 10, 64)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			targets = append(targets, id)
+		}
+	}
+
+	return targets
+}
+
+// pgBackfill is the progress of a backfilling PG at the previous collect.
+type pgBackfill struct {
+	recovered float64
+
+	// targets are the label values of OSDObjectsBackfilled for each OSD the
+	// PG backfilled to, to delete them once the PG is done backfilling
+	targets map[int64][]string
+}
+
+// cephPGDump is the output of `ceph pg dump pgs`. That of `ceph pg dump
+// pgs_brief` has the same form, without the scrub stamps and stat sums.
+type cephPGDump struct {
+	PGStats []struct {
+		PGID           string `json:"pgid"`
+		ActingPrimary  int64  `json:"acting_primary"`
+		Acting         []int  `json:"acting"`
+		Up             []int  `json:"up"`
+		State          string `json:"state"`
+		LastScrubStamp string `json:"last_scrub_stamp"`
+		StatSum        struct {
+			Bytes   float64 `json:"num_bytes"`
+			Objects float64 `json:"num_objects"`
+
+			// Not reported by every release, a PG without either can't be
+			// estimated.
+			ObjectsDegraded  *float64 `json:"num_objects_degraded"`
+			ObjectsMisplaced *float64 `json:"num_objects_misplaced"`
+		} `json:"stat_sum"`
+	} `json:"pg_stats"`
+}
+
+// backfillTargets returns the OSDs of the up set missing from the acting
+// set, which a remapped PG backfills to.
+func backfillTargets(up, acting []int) []int64 {
+	var targets []int64
+	for _, osd := range up {
+		inActing := false
+		for _, a := range acting {
+			if a == osd {
+				inActing = true
+				break
+			}
+		}
+		if !inActing {
+			targets = append(targets, int64(osd))
+		}
+	}
+
+	return targets
+}
+
+// cephStampFormats are the layouts Ceph has used for timestamps such as PG
+// scrub stamps and blocklist expiries, newest first.
+var cephStampFormats = []string{
+	"2006-01-02T15:04:05.999999-0700",
+	"2006-01-02T15:04:05.999999Z07:00",
+	"2006-01-02 15:04:05.999999",
+}
+
+// parseCephStamp parses a Ceph timestamp in any of the known formats.
+func parseCephStamp(stamp string) (time.Time, error) {
+	for _, layout := range cephStampFormats {
+		if t, err := time.Parse(layout, stamp); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", stamp)
+}
+
+// oldestUnscrubbedAge returns the largest time since last scrub across all
+// PGs, relative to now. PGs with stamps that cannot be parsed are skipped.
+func (d *cephPGDump) oldestUnscrubbedAge(now time.Time) float64 {
+	var oldest time.Duration
+	for _, pg := range d.PGStats {
+		stamp, err := parseCephStamp(pg.LastScrubStamp)
+		if err != nil {
+			continue
+		}
+
+		if age := now.Sub(stamp); age > oldest {
+			oldest = age
+		}
+	}
+
+	return oldest.Seconds()
+}
+
+// backfillBytesRemaining estimates the bytes left to backfill. The object
+// copies each backfilling PG still has to move are taken from its degraded
+// and misplaced counts, and are assumed to be of the PG's average object size.
+func (d *cephPGDump) backfillBytesRemaining() float64 {
+	var remaining float64
+	for _, pg := range d.PGStats {
+		if !strings.Contains(pg.State, "backfill") {
+			continue
+		}
+
+		sum := pg.StatSum
+		if sum.Objects <= 0 || (sum.ObjectsDegraded == nil && sum.ObjectsMisplaced == nil) {
+			continue
+		}
+
+		var copies float64
+		if sum.ObjectsDegraded != nil {
+			copies += *sum.ObjectsDegraded
+		}
+		if sum.ObjectsMisplaced != nil {
+			copies += *sum.ObjectsMisplaced
+		}
+
+		remaining += copies * sum.Bytes / sum.Objects
+	}
+
+	return remaining
+}
+
+type cephOSDBlocklist []struct {
+	Addr  string `json:"addr"`
+	Until string `json:"until"`
+}
+
+// cephOSDOpsInFlight holds the output of dump_ops_in_flight, the age of an op
+// is in seconds.
+type cephOSDOpsInFlight struct {
+	NumOps float64 `json:"num_ops"`
+	Ops    []struct {
+		Description string  `json:"description"`
+		Age         float64 `json:"age"`
+	} `json:"ops"`
+}
+
+type cephOSDPerfDump struct {
+	OSD struct {
+		OpWip      float64     `json:"op_wip"`
+		OpRLatency cephPerfAvg `json:"op_r_latency"`
+		OpWLatency cephPerfAvg `json:"op_w_latency"`
+	} `json:"osd"`
+
+	// Bluestore is only there for BlueStore OSDs.
+	Bluestore *struct {
+		Allocated float64 `json:"bluestore_allocated"`
+		Stored    float64 `json:"bluestore_stored"`
+	} `json:"bluestore"`
+}
+
+// cephPerfAvg is a perf counter averaging a value, e.g. a latency in seconds,
+// over the events counted since the daemon started.
+type cephPerfAvg struct {
+	AvgCount uint64  `json:"avgcount"`
+	Sum      float64 `json:"sum"`
+}
+
+type cephOSDLabel struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Status      string  `json:"status"`
+	DeviceClass string  `json:"device_class"`
+	CrushWeight float64 `json:"crush_weight"`
+	Reweight    float64 `json:"reweight"`
+	Root        string  `json:"root"`
+	Rack        string  `json:"rack"`
 	Host        string  `json:"host"`
 	parent      int64   // parent id when building tables
 }
 
 type cephOSDMetadata struct {
 	ID                     int    `json:"id"`
+	CephVersion            string `json:"ceph_version"`
 	CephVersionWhenCreated string `json:"ceph_version_when_created"`
 	CreatedAt              string `json:"created_at"`
 	OsdObjectstore         string `json:"osd_objectstore"`
+	BluestoreBdevType      string `json:"bluestore_bdev_type"`
+	BluefsDedicatedDB      string `json:"bluefs_dedicated_db"`
+	BluefsDBDevNode        string `json:"bluefs_db_dev_node"`
+	BluefsDedicatedWAL     string `json:"bluefs_dedicated_wal"`
+	BluefsWALDevNode       string `json:"bluefs_wal_dev_node"`
+}
+
+// dbDevice returns the device of the OSD's dedicated BlueFS DB, if any.
+func (m cephOSDMetadata) dbDevice() string {
+	if m.BluefsDedicatedDB != "1" {
+		return ""
+	}
+
+	return m.BluefsDBDevNode
+}
+
+// walDevice returns the device of the OSD's dedicated BlueFS WAL, if any.
+func (m cephOSDMetadata) walDevice() string {
+	if m.BluefsDedicatedWAL != "1" {
+		return ""
+	}
+
+	return m.BluefsWALDevNode
+}
+
+// cephOSDCrushDump holds the parts of the CRUSH map needed to map the
+// weight-sets to OSDs.
+type cephOSDCrushDump struct {
+	Buckets []struct {
+		ID    int64 `json:"id"`
+		Items []struct {
+			ID  int64 `json:"id"`
+			Pos int   `json:"pos"`
+		} `json:"items"`
+	} `json:"buckets"`
+	// ChooseArgs are the weight-sets, keyed by the pool id they apply to or
+	// -1 for the compat weight-set. They hold one weight per position of
+	// each bucket's items.
+	ChooseArgs map[string][]struct {
+		BucketID  int64       `json:"bucket_id"`
+		WeightSet [][]float64 `json:"weight_set"`
+	} `json:"choose_args"`
+}
+
+// weightSetName returns the name of the weight-set with the given
+// choose_args key, as shown by `ceph osd crush weight-set ls`.
+func weightSetName(key string) string {
+	if key == "-1" {
+		return "compat"
+	}
+
+	return key
+}
+
+// collectCrushWeightSets reports the weights of the OSDs in each CRUSH
+// weight-set, which the balancer adjusts instead of the CRUSH weights
+// themselves. Only the first position is reported, the balancer only ever
+// creates weight-sets with a single one.
+func (o *OSDCollector) collectCrushWeightSets() error {
+	cmd := o.cephOSDCrushDumpCommand()
+	buf, _, err := o.conn.MonCommand(cmd)
+	if err != nil {
+		o.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	crushDump := &cephOSDCrushDump{}
+	if err := json.Unmarshal(buf, crushDump); err != nil {
+		return err
+	}
+
+	buckets := make(map[int64]int, len(crushDump.Buckets))
+	for i, bucket := range crushDump.Buckets {
+		buckets[bucket.ID] = i
+	}
+
+	for key, args := range crushDump.ChooseArgs {
+		weightSet := weightSetName(key)
+
+		for _, arg := range args {
+			i, ok := buckets[arg.BucketID]
+			if !ok || len(arg.WeightSet) == 0 {
+				continue
+			}
+			weights := arg.WeightSet[0]
+
+			for _, item := range crushDump.Buckets[i].Items {
+				// Negative ids are buckets rather than OSDs.
+				if item.ID < 0 || item.Pos >= len(weights) {
+					continue
+				}
+
+				lb := o.getOSDLabelFromID(item.ID)
+				if !o.allowDeviceClass(lb.DeviceClass) {
+					continue
+				}
+
+				o.CrushWeightSet.WithLabelValues(fmt.Sprintf("osd.%d", item.ID), lb.DeviceClass, lb.Host, lb.Rack, lb.Root, weightSet).Set(weights[item.Pos])
+			}
+		}
+	}
+
+	return nil
 }
 
 func (o *OSDCollector) collectOSDDF() error {
@@ -626,6 +1541,9 @@ func (o *OSDCollector) collectOSDDF() error {
 
 	for _, node := range osdDF.OSDNodes {
 		lb := o.getOSDLabelFromName(node.Name)
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
 
 		crushWeight, err := node.CrushWeight.Float64()
 		if err != nil {
@@ -662,6 +1580,11 @@ func (o *OSDCollector) collectOSDDF() error {
 
 		o.UsedBytes.WithLabelValues(node.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(usedKB * 1024)
 
+		// OSDs that are down or destroyed report no capacity
+		if osdKB > 0 {
+			o.UsedRatio.WithLabelValues(node.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(usedKB / osdKB)
+		}
+
 		availKB, err := node.AvailKB.Float64()
 		if err != nil {
 			return err
@@ -724,7 +1647,21 @@ func (o *OSDCollector) collectOSDDF() error {
 
 }
 
+// collectOSDMetadata sets the metadata of each OSD, from the previous
+// `osd metadata` if it failed this time.
 func (o *OSDCollector) collectOSDMetadata() error {
+	err := o.refreshOSDMetadata()
+
+	for _, osd := range o.osdMetadataCache {
+		lb := o.getOSDLabelFromID(int64(osd.ID))
+		o.OSDMetadata.WithLabelValues(strconv.Itoa(osd.ID), osd.OsdObjectstore, osd.CephVersionWhenCreated, osd.CreatedAt,
+			osd.CephVersion, lb.DeviceClass, osd.BluestoreBdevType, osd.dbDevice(), osd.walDevice()).Set(1)
+	}
+
+	return err
+}
+
+func (o *OSDCollector) refreshOSDMetadata() error {
 	cmd := o.cephOSDMetadataCommand()
 	buf, _, err := o.conn.MonCommand(cmd)
 	if err != nil {
@@ -739,10 +1676,7 @@ func (o *OSDCollector) collectOSDMetadata() error {
 	if err := json.Unmarshal(buf, &osdMetadata); err != nil {
 		return err
 	}
-
-	for _, osd := range osdMetadata {
-		o.OSDMetadata.WithLabelValues(strconv.Itoa(osd.ID), osd.OsdObjectstore, osd.CephVersionWhenCreated, osd.CreatedAt).Set(1)
-	}
+	o.osdMetadataCache = osdMetadata
 
 	return nil
 }
@@ -771,6 +1705,9 @@ func (o *OSDCollector) collectOSDPerf() error {
 		osdName := fmt.Sprintf(osdLabelFormat, osdID)
 
 		lb := o.getOSDLabelFromID(osdID)
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
 
 		commitLatency, err := perfStat.Stats.CommitLatency.Float64()
 		if err != nil {
@@ -788,7 +1725,26 @@ func (o *OSDCollector) collectOSDPerf() error {
 	return nil
 }
 
-func buildOSDLabels(data []byte) (map[int64]*cephOSDLabel, error) {
+// OSDLabelRelabel rewrites the host, rack or root label of the OSDs, e.g. to
+// strip the domain off CRUSH host names so that they match node_exporter's
+// instance label. The matches of Regex are replaced by Replacement, which may
+// refer to capture groups as $1 etc.
+type OSDLabelRelabel struct {
+	Label       string
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// apply returns the relabelled value of the label.
+func (r OSDLabelRelabel) apply(label, value string) string {
+	if r.Label != label {
+		return value
+	}
+
+	return r.Regex.ReplaceAllString(value, r.Replacement)
+}
+
+func buildOSDLabels(data []byte, relabels []OSDLabelRelabel) (map[int64]*cephOSDLabel, error) {
 	nodeList := &cephOSDTree{}
 	if err := json.Unmarshal(data, nodeList); err != nil {
 		return nil, err
@@ -803,6 +1759,7 @@ func buildOSDLabels(data []byte) (map[int64]*cephOSDLabel, error) {
 			Status:      node.Status,
 			DeviceClass: node.Class,
 			CrushWeight: node.CrushWeight,
+			Reweight:    node.Reweight,
 			parent:      math.MaxInt64,
 		}
 		nodeMap[node.ID] = &label
@@ -839,6 +1796,12 @@ func buildOSDLabels(data []byte) (map[int64]*cephOSDLabel, error) {
 		if root, ok := findParent(osdLabel, "root"); ok {
 			osdLabel.Root = root.Name
 		}
+
+		for _, r := range relabels {
+			osdLabel.Host = r.apply("host", osdLabel.Host)
+			osdLabel.Rack = r.apply("rack", osdLabel.Rack)
+			osdLabel.Root = r.apply("root", osdLabel.Root)
+		}
 	}
 
 	for k := range nodeMap {
@@ -861,71 +1824,226 @@ func (o *OSDCollector) buildOSDLabelCache() error {
 		return err
 	}
 
-	cache, err := buildOSDLabels(data)
+	cache, err := buildOSDLabels(data, o.labelRelabels)
 	if err != nil {
 		return err
 	}
 	o.osdLabelsCache = cache
+	o.osdLabelsRefreshed = o.now()
 	return nil
 }
 
-func (o *OSDCollector) getOSDLabelFromID(id int64) *cephOSDLabel {
-	if label, ok := o.osdLabelsCache[id]; ok {
-		return label
+// collectOSDLabelCacheAge reports how old the OSD labels are, so that labels
+// which look wrong after a CRUSH change can be told apart from stale ones.
+func (o *OSDCollector) collectOSDLabelCacheAge(ch chan<- prometheus.Metric) {
+	if o.osdLabelsRefreshed.IsZero() {
+		return
 	}
-	return &cephOSDLabel{}
+
+	ch <- prometheus.MustNewConstMetric(
+		o.LabelCacheAgeDesc,
+		prometheus.GaugeValue,
+		o.now().Sub(o.osdLabelsRefreshed).Seconds(),
+	)
 }
 
-func (o *OSDCollector) getOSDLabelFromName(osdid string) *cephOSDLabel {
-	var id int64
-	c, err := fmt.Sscanf(osdid, "osd.%d", &id)
-	if err != nil || c != 1 {
-		return &cephOSDLabel{}
-	}
+// collectOSDPerfDumps queries every up OSD daemon for its perf counters and
+// reports the number of ops it has in progress and its op latencies, as
+// enabled. Both are read from a single `perf dump` per OSD.
+func (o *OSDCollector) collectOSDPerfDumps(ch chan<- prometheus.Metric) {
+	o.commandUpOSDs(o.cephPerfDumpCommand(), func(id int64, lb *cephOSDLabel, buf []byte) {
+		perfDump := &cephOSDPerfDump{}
+		if err := json.Unmarshal(buf, perfDump); err != nil {
+			o.logger.WithError(err).WithField("osd", lb.Name).Error("error unmarshalling osd perf dump")
+			return
+		}
 
-	return o.getOSDLabelFromID(id)
+		if o.opQueue {
+			o.OpsInProgress.WithLabelValues(lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(perfDump.OSD.OpWip)
+		}
+
+		if o.perfDump {
+			o.collectOSDOpLatency(ch, lb, perfDump)
+
+			if perfDump.Bluestore != nil {
+				o.BluestoreAllocated.WithLabelValues(lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(perfDump.Bluestore.Allocated)
+				o.BluestoreStored.WithLabelValues(lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(perfDump.Bluestore.Stored)
+			}
+		}
+	})
 }
 
-func (o *OSDCollector) collectOSDTreeDown(ch chan<- prometheus.Metric) error {
-	cmd := o.cephOSDTreeCommand("down")
-	buff, _, err := o.conn.MonCommand(cmd)
-	if err != nil {
-		o.logger.WithError(err).WithField(
-			"args", string(cmd),
-		).Error("error executing mon command")
+// collectOSDOpsInFlight queries every up OSD daemon for the ops it is
+// tracking as in flight, and reports how many there are and how many of them
+// are slow. Unlike the cluster wide SLOW_OPS health check, this tells which
+// OSDs the slow ops are on.
+func (o *OSDCollector) collectOSDOpsInFlight() {
+	o.commandUpOSDs(o.cephDumpOpsInFlightCommand(), func(id int64, lb *cephOSDLabel, buf []byte) {
+		opsInFlight := &cephOSDOpsInFlight{}
+		if err := json.Unmarshal(buf, opsInFlight); err != nil {
+			o.logger.WithError(err).WithField("osd", lb.Name).Error("error unmarshalling osd ops in flight")
+			return
+		}
 
-		return err
+		slow := 0.0
+		for _, op := range opsInFlight.Ops {
+			if op.Age >= o.opComplaintTime {
+				slow++
+			}
+		}
+
+		o.OpsInFlight.WithLabelValues(lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(opsInFlight.NumOps)
+		o.SlowOps.WithLabelValues(lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(slow)
+	})
+}
+
+// commandUpOSDs sends the command to every up OSD daemon, at most
+// osdPerfDumpConcurrency at once, and passes the responses to handle. OSDs
+// that fail to answer are logged and skipped.
+func (o *OSDCollector) commandUpOSDs(args [][]byte, handle func(id int64, lb *cephOSDLabel, buf []byte)) {
+	sem := make(chan struct{}, osdPerfDumpConcurrency)
+	wg := &sync.WaitGroup{}
+	for id, lb := range o.osdLabelsCache {
+		if lb.Status != "up" || !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(id int64, lb *cephOSDLabel) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			buf, _, err := o.conn.OsdCommand(int(id), args)
+			if err != nil {
+				o.logger.WithError(err).WithField("osd", lb.Name).WithField(
+					"args", string(bytes.Join(args, []byte(","))),
+				).Error("error executing osd command")
+
+				return
+			}
+
+			handle(id, lb, buf)
+		}(id, lb)
 	}
 
-	osdDown := &cephOSDTreeDown{}
-	if err := json.Unmarshal(buff, osdDown); err != nil {
-		return err
+	wg.Wait()
+}
+
+// collectOSDOpLatency reports the latency of the reads and writes the OSD
+// completed as summaries without quantiles, whose sum and count are the perf
+// counters as is. The average latency over an interval is the rate of the sum
+// divided by the rate of the count.
+func (o *OSDCollector) collectOSDOpLatency(ch chan<- prometheus.Metric, lb *cephOSDLabel, perfDump *cephOSDPerfDump) {
+	ch <- prometheus.MustNewConstSummary(o.OpReadLatencyDesc, perfDump.OSD.OpRLatency.AvgCount, perfDump.OSD.OpRLatency.Sum, nil,
+		lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root)
+	ch <- prometheus.MustNewConstSummary(o.OpWriteLatencyDesc, perfDump.OSD.OpWLatency.AvgCount, perfDump.OSD.OpWLatency.Sum, nil,
+		lb.Name, lb.DeviceClass, lb.Host, lb.Rack, lb.Root)
+}
+
+// collectPGBackfills queries the primary OSD of every backfilling PG for how
+// many objects the PG recovered, and adds those recovered since the previous
+// collect to the OSDs it backfills to. PGs that stopped backfilling are
+// forgotten along with their counters.
+func (o *OSDCollector) collectPGBackfills(ch chan<- prometheus.Metric, pgDump *cephPGDump) {
+	type pgQueryResult struct {
+		pgid  string
+		query *cephPGQuery
 	}
 
-	downItems := append(osdDown.Nodes, osdDown.Stray...)
-	for _, downItem := range downItems {
-		if downItem.Type != "osd" {
+	var (
+		mu      sync.Mutex
+		results []pgQueryResult
+	)
+	backfilling := make(map[string]bool)
+
+	sem := make(chan struct{}, pgQueryConcurrency)
+	wg := &sync.WaitGroup{}
+	for _, pg := range pgDump.PGStats {
+		if !strings.Contains(pg.State, "backfilling") {
 			continue
 		}
+		backfilling[pg.PGID] = true
 
-		osdName := downItem.Name
-		lb := o.getOSDLabelFromName(osdName)
+		wg.Add(1)
+		go func(pgid string, primary int64) {
+			defer wg.Done()
 
-		ch <- prometheus.MustNewConstMetric(o.OSDDownDesc, prometheus.GaugeValue, 1,
-			downItem.Status,
-			osdName,
-			lb.DeviceClass,
-			lb.Host,
-			lb.Rack,
-			lb.Root)
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// The PG is queried through its primary OSD, the same way
+			// `ceph pg <pgid> query` is.
+			args := o.cephPGQueryCommand(pgid)
+			buf, _, err := o.conn.OsdCommand(int(primary), args)
+			if err != nil {
+				o.logger.WithError(err).WithField("pgid", pgid).WithField(
+					"args", string(bytes.Join(args, []byte(","))),
+				).Error("error executing osd command")
+
+				return
+			}
+
+			query := &cephPGQuery{}
+			if err := json.Unmarshal(buf, query); err != nil {
+				o.logger.WithError(err).WithField("pgid", pgid).Error("error unmarshalling pg query")
+				return
+			}
+
+			mu.Lock()
+			results = append(results, pgQueryResult{pgid: pgid, query: query})
+			mu.Unlock()
+		}(pg.PGID, pg.ActingPrimary)
 	}
+	wg.Wait()
 
-	return nil
+	for pgid, backfill := range o.pgBackfills {
+		if backfilling[pgid] {
+			continue
+		}
+		for _, values := range backfill.targets {
+			o.OSDObjectsBackfilled.DeleteLabelValues(values...)
+		}
+		delete(o.pgBackfills, pgid)
+	}
+
+	for _, result := range results {
+		recovered := result.query.Info.Stats.StatSum.NumObjectsRecovered
+		ch <- prometheus.MustNewConstMetric(o.PGObjectsRecoveredDesc, prometheus.GaugeValue, recovered, result.pgid)
+
+		backfill, ok := o.pgBackfills[result.pgid]
+		if !ok {
+			backfill = &pgBackfill{recovered: recovered, targets: make(map[int64][]string)}
+			o.pgBackfills[result.pgid] = backfill
+		}
+
+		// The count restarts when the PG is re-peered.
+		delta := recovered - backfill.recovered
+		if delta < 0 {
+			delta = recovered
+		}
+		backfill.recovered = recovered
+
+		for _, id := range result.query.backfillTargets() {
+			lb := o.getOSDLabelFromID(id)
+			if !o.allowDeviceClass(lb.DeviceClass) {
+				continue
+			}
+
+			values := []string{result.pgid, fmt.Sprintf(osdLabelFormat, id), lb.DeviceClass, lb.Host, lb.Rack, lb.Root}
+			o.OSDObjectsBackfilled.WithLabelValues(values...).Add(delta)
+			backfill.targets[id] = values
+		}
+	}
 }
 
-func (o *OSDCollector) collectOSDDump() error {
-	cmd := o.cephOSDDump()
-	buff, _, err := o.conn.MonCommand(cmd)
+// collectOSDBlocklist summarizes the OSD blocklist. All entries are counted,
+// but only the blocklistTopN expiring last are exported individually, as the
+// blocklist can hold any number of clients.
+func (o *OSDCollector) collectOSDBlocklist(version *Version) error {
+	cmd := o.cephOSDBlocklistCommand(version)
+	buf, _, err := o.conn.MonCommand(cmd)
 	if err != nil {
 		o.logger.WithError(err).WithField(
 			"args", string(cmd),
@@ -934,72 +2052,384 @@ func (o *OSDCollector) collectOSDDump() error {
 		return err
 	}
 
-	osdDump := cephOSDDump{}
-	if err := json.Unmarshal(buff, &osdDump); err != nil {
+	blocklist := cephOSDBlocklist{}
+	if err := json.Unmarshal(buf, &blocklist); err != nil {
 		return err
 	}
 
-	osdFullRatio, err := osdDump.FullRatio.Float64()
-	if err != nil {
-		return err
-	}
-	osdNearFullRatio, err := osdDump.NearFullRatio.Float64()
-	if err != nil {
-		return err
-	}
-	osdBackfillFullRatio, err := osdDump.BackfillFullRatio.Float64()
-	if err != nil {
-		return err
+	type blocklistEntry struct {
+		addr  string
+		until time.Time
 	}
-	o.OSDFullRatio.Set(osdFullRatio)
-	o.OSDNearFullRatio.Set(osdNearFullRatio)
-	o.OSDBackfillFullRatio.Set(osdBackfillFullRatio)
-	o.PgUpmapItemsTotal.Set(float64(len(osdDump.PgUpmapItems)))
 
-	for _, dumpInfo := range osdDump.OSDs {
-		osdID, err := dumpInfo.OSD.Int64()
+	now := time.Now()
+	expired := 0
+	var latest time.Time
+	var entries []blocklistEntry
+	for _, entry := range blocklist {
+		until, err := parseCephStamp(entry.Until)
 		if err != nil {
-			return err
+			o.logger.WithError(err).WithField("addr", entry.Addr).Debug("skipping blocklist entry expiry")
+			continue
 		}
-		osdName := fmt.Sprintf(osdLabelFormat, osdID)
-		lb := o.getOSDLabelFromID(osdID)
 
-		in, err := dumpInfo.In.Float64()
-		if err != nil {
-			return err
+		if until.Before(now) {
+			expired++
 		}
-
-		o.OSDIn.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(in)
-
-		up, err := dumpInfo.Up.Float64()
-		if err != nil {
-			return err
+		if until.After(latest) {
+			latest = until
 		}
+		entries = append(entries, blocklistEntry{addr: entry.Addr, until: until})
+	}
 
-		o.OSDUp.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(up)
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].until.Equal(entries[j].until) {
+			return entries[i].until.After(entries[j].until)
+		}
+		return entries[i].addr < entries[j].addr
+	})
+	if len(entries) > blocklistTopN {
+		entries = entries[:blocklistTopN]
+	}
+	for _, entry := range entries {
+		o.BlocklistExpiry.WithLabelValues(entry.addr).Set(float64(entry.until.Unix()))
+	}
+
+	o.BlocklistEntries.Set(float64(len(blocklist)))
+	o.BlocklistExpiredEntries.Set(float64(expired))
+	if latest.IsZero() {
+		o.BlocklistLatestExpiry.Set(0)
+	} else {
+		o.BlocklistLatestExpiry.Set(float64(latest.Unix()))
+	}
+
+	return nil
+}
+
+// parseConfigValue parses the output of `config get`, which depending on the
+// Ceph release and option type is a bare number, a quoted string, or an object
+// keyed by the option name.
+func parseConfigValue(buf []byte) (float64, error) {
+	var v interface{}
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return 0, err
+	}
+
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(val), 64)
+	case map[string]interface{}:
+		if len(val) == 1 {
+			for _, inner := range val {
+				b, err := json.Marshal(inner)
+				if err != nil {
+					return 0, err
+				}
+				return parseConfigValue(b)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("unexpected config value %s", string(buf))
+}
+
+// collectOSDConfig reads osdConfigOptions from the config database, which
+// `config get` was introduced with in Mimic.
+func (o *OSDCollector) collectOSDConfig(version *Version) {
+	if !o.versionAtLeast(version, Mimic, "config") {
+		return
+	}
+
+	for _, option := range osdConfigOptions {
+		cmd := o.cephConfigGetCommand(option)
+		buf, _, err := o.conn.MonCommand(cmd)
+		if err != nil {
+			o.logger.WithError(err).WithField(
+				"args", string(cmd),
+			).Error("error executing mon command")
+
+			continue
+		}
+
+		value, err := parseConfigValue(buf)
+		if err != nil {
+			o.logger.WithError(err).WithField("option", option).Error("error parsing osd config value")
+			continue
+		}
+
+		o.ConfigValue.WithLabelValues(option).Set(value)
+		switch option {
+		case "osd_max_backfills":
+			o.maxBackfills = value
+		case "osd_op_complaint_time":
+			o.opComplaintTime = value
+		}
+	}
+}
+
+func (o *OSDCollector) collectHostOSDCount() {
+	for _, lb := range o.osdLabelsCache {
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
+		o.HostOSDCount.WithLabelValues(lb.Host, lb.DeviceClass).Inc()
+	}
+}
+
+// osdInTree returns whether the OSD is in the latest OSD tree, so that the
+// OSDs removed from the cluster can be dropped from the caches. Every OSD is
+// assumed to be until the tree could be read.
+func (o *OSDCollector) osdInTree(id int64) bool {
+	if o.osdLabelsRefreshed.IsZero() {
+		return true
+	}
+
+	_, ok := o.osdLabelsCache[id]
+	return ok
+}
+
+func (o *OSDCollector) getOSDLabelFromID(id int64) *cephOSDLabel {
+	if label, ok := o.osdLabelsCache[id]; ok {
+		return label
+	}
+	return &cephOSDLabel{}
+}
+
+// allowDeviceClass returns whether metrics should be reported for OSDs of
+// the given device class.
+func (o *OSDCollector) allowDeviceClass(class string) bool {
+	return len(o.deviceClasses) == 0 || o.deviceClasses[class]
+}
+
+func (o *OSDCollector) getOSDLabelFromName(osdid string) *cephOSDLabel {
+	var id int64
+	c, err := fmt.Sscanf(osdid, "osd.%d", &id)
+	if err != nil || c != 1 {
+		return &cephOSDLabel{}
+	}
+
+	return o.getOSDLabelFromID(id)
+}
+
+func (o *OSDCollector) collectOSDTreeDown(ch chan<- prometheus.Metric) error {
+	cmd := o.cephOSDTreeCommand("down")
+	buff, _, err := o.conn.MonCommand(cmd)
+	if err != nil {
+		o.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	osdDown := &cephOSDTreeDown{}
+	if err := json.Unmarshal(buff, osdDown); err != nil {
+		return err
+	}
+
+	now := o.now()
+	down := make(map[osdDownSeries]bool)
+
+	downItems := append(osdDown.Nodes, osdDown.Stray...)
+	for _, downItem := range downItems {
+		if downItem.Type != "osd" {
+			continue
+		}
+
+		osdName := downItem.Name
+		lb := o.getOSDLabelFromName(osdName)
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
+
+		series := osdDownSeries{
+			id:          downItem.ID,
+			status:      downItem.Status,
+			osd:         osdName,
+			deviceClass: lb.DeviceClass,
+			host:        lb.Host,
+			rack:        lb.Rack,
+			root:        lb.Root,
+		}
+		down[series] = true
+		o.osdDownCache[series] = now
+	}
+
+	// OSDs reported down by a previous collect read 0 until they are removed
+	// from the tree or have been back for osdDownRecoveredRetention.
+	for series, lastDown := range o.osdDownCache {
+		if !down[series] && (!o.osdInTree(series.id) || now.Sub(lastDown) > osdDownRecoveredRetention) {
+			delete(o.osdDownCache, series)
+		}
+	}
+
+	// An OSD going from down to destroyed has a series for both statuses but
+	// a single down reason.
+	downReasons := make(map[[2]string]float64)
+	for series := range o.osdDownCache {
+		v := 0.0
+		if down[series] {
+			v = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(o.OSDDownDesc, prometheus.GaugeValue, v,
+			series.status,
+			series.osd,
+			series.deviceClass,
+			series.host,
+			series.rack,
+			series.root)
+
+		reason := [2]string{series.osd, series.host}
+		downReasons[reason] = math.Max(downReasons[reason], v)
+	}
+
+	for reason, v := range downReasons {
+		ch <- prometheus.MustNewConstMetric(o.OSDDownReasonDesc, prometheus.GaugeValue, v,
+			reason[0],
+			reason[1])
+	}
+
+	return nil
+}
+
+func (o *OSDCollector) collectOSDDump() error {
+	cmd := o.cephOSDDump()
+	buff, _, err := o.conn.MonCommand(cmd)
+	if err != nil {
+		o.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	osdDump := cephOSDDump{}
+	if err := json.Unmarshal(buff, &osdDump); err != nil {
+		return err
+	}
+
+	osdFullRatio, err := osdDump.FullRatio.Float64()
+	if err != nil {
+		return err
+	}
+	osdNearFullRatio, err := osdDump.NearFullRatio.Float64()
+	if err != nil {
+		return err
+	}
+	osdBackfillFullRatio, err := osdDump.BackfillFullRatio.Float64()
+	if err != nil {
+		return err
+	}
+	o.OSDFullRatio.Set(osdFullRatio)
+	o.OSDNearFullRatio.Set(osdNearFullRatio)
+	o.OSDBackfillFullRatio.Set(osdBackfillFullRatio)
+	o.PgUpmapItemsTotal.Set(float64(len(osdDump.PgUpmapItems)))
+
+	nearFullByClass := make(map[string]float64)
+	numInOSDs := 0.0
+	seen := make(map[int64]bool, len(osdDump.OSDs))
+	for _, dumpInfo := range osdDump.OSDs {
+		osdID, err := dumpInfo.OSD.Int64()
+		if err != nil {
+			return err
+		}
+
+		in, err := dumpInfo.In.Float64()
+		if err != nil {
+			return err
+		}
+		// Recovery reservations are taken on OSDs of any device class.
+		numInOSDs += in
+
+		osdName := fmt.Sprintf(osdLabelFormat, osdID)
+		lb := o.getOSDLabelFromID(osdID)
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
+
+		o.OSDIn.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(in)
+
+		up, err := dumpInfo.Up.Float64()
+		if err != nil {
+			return err
+		}
+
+		o.OSDUp.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(up)
+
+		if dumpInfo.PrimaryAffinity != "" {
+			primaryAffinity, err := dumpInfo.PrimaryAffinity.Float64()
+			if err != nil {
+				return err
+			}
+
+			o.OSDPrimaryAffinity.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(primaryAffinity)
+		}
+
+		state := osdState{up: up, in: in}
+		changes := o.OSDStateChanges.WithLabelValues(osdName)
+		if prev, ok := o.osdStates[osdID]; ok {
+			if prev.up != state.up {
+				changes.Inc()
+			}
+			if prev.in != state.in {
+				changes.Inc()
+			}
+		}
+		o.osdStates[osdID] = state
+		seen[osdID] = true
 
 		o.OSDFull.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(0)
 		o.OSDNearFull.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(0)
 		o.OSDBackfillFull.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(0)
+		nearFull := false
 		for _, state := range dumpInfo.State {
 			switch state {
 			case "full":
 				o.OSDFull.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(1)
+				nearFull = true
 			case "nearfull":
 				o.OSDNearFull.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(1)
+				nearFull = true
 			case "backfillfull":
 				o.OSDBackfillFull.WithLabelValues(osdName, lb.DeviceClass, lb.Host, lb.Rack, lb.Root).Set(1)
+				nearFull = true
 			}
 		}
+
+		// A full OSD is usually also backfillfull, count it only once, and
+		// still export 0 for classes without any nearfull OSD.
+		count := nearFullByClass[lb.DeviceClass]
+		if nearFull {
+			count++
+		}
+		nearFullByClass[lb.DeviceClass] = count
+	}
+
+	for class, count := range nearFullByClass {
+		o.ClassNearFullCount.WithLabelValues(class).Set(count)
+	}
+	o.numInOSDs = numInOSDs
+
+	// Forget the OSDs that were removed from the cluster.
+	for osdID := range o.osdStates {
+		if !seen[osdID] {
+			delete(o.osdStates, osdID)
+			o.OSDStateChanges.DeleteLabelValues(fmt.Sprintf(osdLabelFormat, osdID))
+		}
 	}
 
 	return nil
 
 }
 
-func (o *OSDCollector) performPGDumpBrief() (*cephPGDumpBrief, error) {
+// performPGDumpBrief dumps the PGs through conn for the background tracking
+// of inactive PGs, which only needs their states.
+func (o *OSDCollector) performPGDumpBrief(conn Conn) (*cephPGDump, error) {
 	args := o.cephPGDumpCommand()
-	buf, _, err := o.conn.MgrCommand(args)
+	buf, _, err := conn.MgrCommand(args)
 	if err != nil {
 		o.logger.WithError(err).WithField(
 			"args", string(bytes.Join(args, []byte(","))),
@@ -1008,30 +2438,29 @@ func (o *OSDCollector) performPGDumpBrief() (*cephPGDumpBrief, error) {
 		return nil, err
 	}
 
-	pgDumpBrief := cephPGDumpBrief{}
-	if err := json.Unmarshal(buf, &pgDumpBrief); err != nil {
+	pgDump := cephPGDump{}
+	if err := json.Unmarshal(buf, &pgDump); err != nil {
 		return nil, err
 	}
 
-	return &pgDumpBrief, nil
+	return &pgDump, nil
 }
 
-func (o *OSDCollector) collectOSDScrubState(ch chan<- prometheus.Metric) error {
-	pgDumpBrief, err := o.performPGDumpBrief()
-	if err != nil {
-		return err
-	}
-
+func (o *OSDCollector) collectOSDScrubState(ch chan<- prometheus.Metric, pgDump *cephPGDump) {
 	// need to reset the PG scrub state since the scrub might have ended within
 	// the last prom scrape interval.
 	// This forces us to report scrub state on all previously discovered OSDs We
 	// may be able to remove the "cache" when using Prometheus 2.0 if we can
 	// tune how unreported/abandoned gauges are treated (ie set to 0).
 	for i := range o.osdScrubCache {
+		if !o.osdInTree(int64(i)) {
+			delete(o.osdScrubCache, i)
+			continue
+		}
 		o.osdScrubCache[i] = scrubStateIdle
 	}
 
-	for _, pg := range pgDumpBrief.PGStats {
+	for _, pg := range pgDump.PGStats {
 		if strings.Contains(pg.State, "scrubbing") {
 			scrubState := scrubStateScrubbing
 			if strings.Contains(pg.State, "deep") {
@@ -1046,6 +2475,9 @@ func (o *OSDCollector) collectOSDScrubState(ch chan<- prometheus.Metric) error {
 
 	for i, v := range o.osdScrubCache {
 		lb := o.getOSDLabelFromID(int64(i))
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
 		ch <- prometheus.MustNewConstMetric(
 			o.ScrubbingStateDesc,
 			prometheus.GaugeValue,
@@ -1056,8 +2488,276 @@ func (o *OSDCollector) collectOSDScrubState(ch chan<- prometheus.Metric) error {
 			lb.Rack,
 			lb.Root)
 	}
+}
 
-	return nil
+// collectScrubsCompleted counts the PGs that were scrubbing at the previous
+// collect and no longer are. PGs that have since been removed, e.g. with their
+// pool, didn't finish their scrub and are dropped.
+func (o *OSDCollector) collectScrubsCompleted(pgDump *cephPGDump) {
+	scrubbing := make(map[string]bool)
+	for _, pg := range pgDump.PGStats {
+		if strings.Contains(pg.State, "scrubbing") {
+			scrubbing[pg.PGID] = true
+		} else if o.scrubbingPGs[pg.PGID] {
+			o.ScrubsCompleted.Inc()
+		}
+	}
+
+	o.scrubbingPGs = scrubbing
+}
+
+// collectOSDIdle reports which up and in OSDs are the acting primary for no
+// PGs. An OSD that is out has a reweight of 0 in the OSD tree.
+func (o *OSDCollector) collectOSDIdle(ch chan<- prometheus.Metric, pgDump *cephPGDump) {
+	primaries := make(map[int64]int)
+	for _, pg := range pgDump.PGStats {
+		primaries[pg.ActingPrimary]++
+	}
+
+	for id, lb := range o.osdLabelsCache {
+		if lb.Status != "up" || lb.Reweight <= 0 || !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
+
+		idle := 0.0
+		if primaries[id] == 0 {
+			idle = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			o.IdleDesc,
+			prometheus.GaugeValue,
+			idle,
+			fmt.Sprintf(osdLabelFormat, id),
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+	}
+}
+
+// recoveryHeadroom returns the share of the backfill and recovery budget,
+// osd_max_backfills reservations per in OSD, that isn't used by recovering
+// or backfilling PGs. Each of them holds at least the reservation of its
+// primary, so this is an upper bound. It returns false if any of the inputs
+// is unknown or there is no budget at all.
+func recoveryHeadroom(maxBackfills, inOSDs, recoveringPGs float64) (float64, bool) {
+	if maxBackfills < 0 || inOSDs < 0 || recoveringPGs < 0 {
+		return 0, false
+	}
+
+	budget := maxBackfills * inOSDs
+	if budget == 0 {
+		return 0, false
+	}
+
+	return math.Max(0, 1-recoveringPGs/budget), true
+}
+
+// collectPoolPGCounts reports how many PGs each pool has, in total and by
+// their full state, e.g. active+clean, so that stuck PGs can be told apart by
+// pool.
+func (o *OSDCollector) collectPoolPGCounts(ch chan<- prometheus.Metric, pgDump *cephPGDump) {
+	type poolPGState struct {
+		poolID, state string
+	}
+
+	pgs := make(map[string]float64)
+	pgsByState := make(map[poolPGState]float64)
+	for _, pg := range pgDump.PGStats {
+		// PG IDs are the pool ID and the PG number within it, e.g. 81.1f.
+		poolID, _, ok := strings.Cut(pg.PGID, ".")
+		if !ok {
+			o.logger.WithField("pgid", pg.PGID).Debug("skipping PG without a pool ID")
+			continue
+		}
+
+		pgs[poolID]++
+		pgsByState[poolPGState{poolID: poolID, state: pg.State}]++
+	}
+
+	for poolID, count := range pgs {
+		ch <- prometheus.MustNewConstMetric(o.PoolPGsDesc, prometheus.GaugeValue, count, poolID)
+	}
+	for s, count := range pgsByState {
+		ch <- prometheus.MustNewConstMetric(o.PoolPGsByStateDesc, prometheus.GaugeValue, count, s.poolID, s.state)
+	}
+}
+
+// collectOSDPGCounts reports, for every known OSD, how many remapped,
+// unavailable, snaptrimming, healthy, degraded and misplaced PGs it is part
+// of the acting set for.
+// It also counts the recovering and backfilling PGs for RecoveryHeadroomDesc.
+func (o *OSDCollector) collectOSDPGCounts(ch chan<- prometheus.Metric, pgDump *cephPGDump) {
+	remapped := make(map[int64]int)
+	unavailable := make(map[int64]int)
+	snaptrimming := make(map[int64]int)
+	healthy := make(map[int64]int)
+	primary := make(map[int64]int)
+	backfilling := make(map[int64]int)
+	backfillWait := make(map[int64]int)
+	degraded := make(map[int64]int)
+	misplaced := make(map[int64]int)
+	recovering := 0.0
+	for _, pg := range pgDump.PGStats {
+		// PGs without any OSD up have no primary, reported as -1.
+		if pg.ActingPrimary >= 0 {
+			primary[pg.ActingPrimary]++
+		}
+
+		isRemapped, isUnavailable, isSnaptrimming, isRecovering := false, false, false, false
+		isActive, isClean, isBackfilling, isBackfillWait := false, false, false, false
+		isDegraded := false
+		for _, state := range strings.Split(pg.State, "+") {
+			switch state {
+			case "active":
+				isActive = true
+			case "clean":
+				isClean = true
+			case "remapped":
+				isRemapped = true
+			case "down", "incomplete", "stale":
+				isUnavailable = true
+			case "snaptrim", "snaptrim_wait":
+				isSnaptrimming = true
+			case "recovering":
+				isRecovering = true
+			case "backfilling":
+				isRecovering, isBackfilling = true, true
+			case "backfill_wait":
+				isBackfillWait = true
+			case "degraded":
+				isDegraded = true
+			}
+		}
+		if isRecovering {
+			recovering++
+		}
+
+		if isBackfilling || isBackfillWait {
+			for _, osd := range backfillTargets(pg.Up, pg.Acting) {
+				if isBackfilling {
+					backfilling[osd]++
+				} else {
+					backfillWait[osd]++
+				}
+			}
+		}
+
+		for _, osd := range pg.Acting {
+			if isRemapped {
+				remapped[int64(osd)]++
+			}
+			if isUnavailable {
+				unavailable[int64(osd)]++
+			}
+			if isSnaptrimming {
+				snaptrimming[int64(osd)]++
+			}
+			if isDegraded {
+				degraded[int64(osd)]++
+			}
+			if isRemapped && !isClean {
+				misplaced[int64(osd)]++
+			}
+			// A stale PG's state is out of date, it isn't known to be
+			// healthy anymore.
+			if isActive && isClean && !isUnavailable {
+				healthy[int64(osd)]++
+			}
+		}
+	}
+
+	o.numRecoveringPGs = recovering
+
+	for id, lb := range o.osdLabelsCache {
+		if !o.allowDeviceClass(lb.DeviceClass) {
+			continue
+		}
+
+		osd := fmt.Sprintf(osdLabelFormat, id)
+		ch <- prometheus.MustNewConstMetric(
+			o.RemappedPGsDesc,
+			prometheus.GaugeValue,
+			float64(remapped[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.PGsUnavailableDesc,
+			prometheus.GaugeValue,
+			float64(unavailable[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.SnaptrimmingPGsDesc,
+			prometheus.GaugeValue,
+			float64(snaptrimming[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.HealthyPGsDesc,
+			prometheus.GaugeValue,
+			float64(healthy[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.PrimaryPGsDesc,
+			prometheus.GaugeValue,
+			float64(primary[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.BackfillingPGsDesc,
+			prometheus.GaugeValue,
+			float64(backfilling[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.BackfillWaitPGsDesc,
+			prometheus.GaugeValue,
+			float64(backfillWait[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.DegradedPGsDesc,
+			prometheus.GaugeValue,
+			float64(degraded[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+		ch <- prometheus.MustNewConstMetric(
+			o.MisplacedPGsDesc,
+			prometheus.GaugeValue,
+			float64(misplaced[id]),
+			osd,
+			lb.DeviceClass,
+			lb.Host,
+			lb.Rack,
+			lb.Root)
+	}
 }
 
 func (o *OSDCollector) cephOSDDump() []byte {
@@ -1104,6 +2804,17 @@ func (o *OSDCollector) cephOSDMetadataCommand() []byte {
 	return cmd
 }
 
+func (o *OSDCollector) cephOSDCrushDumpCommand() []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd crush dump",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		o.logger.WithError(err).Panic("error marshalling ceph osd crush dump")
+	}
+	return cmd
+}
+
 func (o *OSDCollector) cephOSDTreeCommand(states ...string) []byte {
 	req := map[string]interface{}{
 		"prefix": "osd tree",
@@ -1132,45 +2843,197 @@ func (o *OSDCollector) cephPGDumpCommand() [][]byte {
 	return [][]byte{cmd}
 }
 
-func (o *OSDCollector) oldestInactivePGLoop() {
+func (o *OSDCollector) cephOSDBlocklistCommand(version *Version) []byte {
+	// blacklist was renamed to blocklist in Pacific
+	prefix := "osd blacklist ls"
+	if o.versionAtLeast(version, Pacific, "osd_blocklist") {
+		prefix = "osd blocklist ls"
+	}
+
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": prefix,
+		"format": jsonFormat,
+	})
+	if err != nil {
+		o.logger.WithError(err).Panic("error marshalling ceph osd blocklist ls")
+	}
+	return cmd
+}
+
+func (o *OSDCollector) cephConfigGetCommand(option string) []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "config get",
+		"who":    "osd",
+		"key":    option,
+		"format": jsonFormat,
+	})
+	if err != nil {
+		o.logger.WithError(err).Panic("error marshalling ceph config get")
+	}
+	return cmd
+}
+
+func (o *OSDCollector) cephPerfDumpCommand() [][]byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "perf dump",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		o.logger.WithError(err).Panic("error marshalling ceph perf dump")
+	}
+	return [][]byte{cmd}
+}
+
+func (o *OSDCollector) cephDumpOpsInFlightCommand() [][]byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "dump_ops_in_flight",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		o.logger.WithError(err).Panic("error marshalling ceph dump_ops_in_flight")
+	}
+	return [][]byte{cmd}
+}
+
+func (o *OSDCollector) cephPGQueryCommand(pgid string) [][]byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "query",
+		"pgid":   pgid,
+		"format": jsonFormat,
+	})
+	if err != nil {
+		o.logger.WithError(err).Panic("error marshalling ceph pg query")
+	}
+	return [][]byte{cmd}
+}
+
+// collectBackground tracks the inactive PGs between scrapes.
+func (o *OSDCollector) collectBackground(conn Conn, stop <-chan struct{}) {
+	o.oldestInactivePGLoop(conn, stop)
+}
+
+func (o *OSDCollector) oldestInactivePGLoop(conn Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(oldestInactivePGUpdatePeriod)
+	defer ticker.Stop()
+
 	for {
-		pgDumpBrief, err := o.performPGDumpBrief()
+		pgDump, err := o.performPGDumpBrief(conn)
 		if err != nil {
 			o.logger.WithError(err).Warning("failed to get latest PG dump for oldest inactive PG update")
-			time.Sleep(oldestInactivePGUpdatePeriod)
+		} else {
+			o.updateInactivePGs(pgDump, time.Now())
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateInactivePGs updates how long the PGs of the dump have been inactive
+// and peering for, as of now.
+func (o *OSDCollector) updateInactivePGs(pgDump *cephPGDump, now time.Time) {
+	// - See if there are PGs that we're tracking that are now active
+	// - See if there are new ones to add
+	// - Find the oldest one
+	oldestTime := now
+
+	present := make(map[string]bool)
+	peering := make(map[string]bool)
+	for _, pg := range pgDump.PGStats {
+		present[pg.PGID] = true
+
+		// If we were tracking it, and it's now active, remove it
+		active := strings.Contains(pg.State, "active")
+		if active {
+			delete(o.oldestInactivePGMap, pg.PGID)
 			continue
 		}
 
-		// - See if there are PGs that we're tracking that are now active
-		// - See if there are new ones to add
-		// - Find the oldest one
-		now := time.Now()
-		oldestTime := now
+		// Now see if it's not here, we'll need to track it now
+		pgTime, ok := o.oldestInactivePGMap[pg.PGID]
+		if !ok {
+			pgTime = now
+			o.oldestInactivePGMap[pg.PGID] = now
+		}
 
-		for _, pg := range pgDumpBrief.PGStats {
-			// If we were tracking it, and it's now active, remove it
-			active := strings.Contains(pg.State, "active")
-			if active {
-				delete(o.oldestInactivePGMap, pg.PGID)
-				continue
-			}
+		// And finally, track our oldest time
+		if pgTime.Before(oldestTime) {
+			oldestTime = pgTime
+		}
 
-			// Now see if it's not here, we'll need to track it now
-			pgTime, ok := o.oldestInactivePGMap[pg.PGID]
-			if !ok {
-				pgTime = now
-				o.oldestInactivePGMap[pg.PGID] = now
+		for _, state := range strings.Split(pg.State, "+") {
+			if state == "peering" {
+				peering[pg.PGID] = true
 			}
+		}
+	}
 
-			// And finally, track our oldest time
-			if pgTime.Before(oldestTime) {
-				oldestTime = pgTime
-			}
+	o.OldestInactivePG.Set(float64(now.Unix() - oldestTime.Unix()))
+
+	// PGs that are gone, e.g. with their pool, are no longer tracked, should
+	// the same PG id come back it is a new PG.
+	for pgid := range o.oldestInactivePGMap {
+		if !present[pgid] {
+			delete(o.oldestInactivePGMap, pgid)
+		}
+	}
+
+	// PGs that stopped peering, or are gone, are no longer tracked.
+	for pgid := range o.peeringPGMap {
+		if !peering[pgid] {
+			delete(o.peeringPGMap, pgid)
+		}
+	}
+
+	type peeringPG struct {
+		pgid     string
+		duration time.Duration
+	}
+
+	var stuck []peeringPG
+	for pgid := range peering {
+		pgTime, ok := o.peeringPGMap[pgid]
+		if !ok {
+			pgTime = now
+			o.peeringPGMap[pgid] = now
+		}
+
+		if d := now.Sub(pgTime); d >= o.peeringPGThreshold {
+			stuck = append(stuck, peeringPG{pgid: pgid, duration: d})
+		}
+	}
+
+	sort.Slice(stuck, func(i, j int) bool {
+		if stuck[i].duration != stuck[j].duration {
+			return stuck[i].duration > stuck[j].duration
 		}
+		return stuck[i].pgid < stuck[j].pgid
+	})
+	if len(stuck) > o.peeringPGTopN {
+		stuck = stuck[:o.peeringPGTopN]
+	}
+
+	peeringPGs := make(map[string]float64, len(stuck))
+	for _, pg := range stuck {
+		peeringPGs[pg.pgid] = pg.duration.Seconds()
+	}
+
+	o.peeringPGsMu.Lock()
+	o.peeringPGs = peeringPGs
+	o.peeringPGsMu.Unlock()
+}
 
-		o.OldestInactivePG.Set(float64(now.Unix() - oldestTime.Unix()))
+// collectPGPeeringDurations reports the PGs stuck peering found by the last
+// run of oldestInactivePGLoop.
+func (o *OSDCollector) collectPGPeeringDurations(ch chan<- prometheus.Metric) {
+	o.peeringPGsMu.Lock()
+	defer o.peeringPGsMu.Unlock()
 
-		time.Sleep(oldestInactivePGUpdatePeriod)
+	for pgid, duration := range o.peeringPGs {
+		ch <- prometheus.MustNewConstMetric(o.PGPeeringDurationDesc, prometheus.GaugeValue, duration, pgid)
 	}
 }
 
@@ -1181,29 +3044,68 @@ func (o *OSDCollector) Describe(ch chan<- *prometheus.Desc) {
 		metric.Describe(ch)
 	}
 	ch <- o.OSDDownDesc
+	ch <- o.OSDDownReasonDesc
 	ch <- o.ScrubbingStateDesc
+	ch <- o.IdleDesc
+	ch <- o.LabelCacheAgeDesc
+	ch <- o.RemappedPGsDesc
+	ch <- o.PGsUnavailableDesc
+	ch <- o.SnaptrimmingPGsDesc
+	ch <- o.HealthyPGsDesc
+	ch <- o.PrimaryPGsDesc
+	ch <- o.BackfillingPGsDesc
+	ch <- o.BackfillWaitPGsDesc
+	ch <- o.DegradedPGsDesc
+	ch <- o.MisplacedPGsDesc
+	ch <- o.PoolPGsDesc
+	ch <- o.PoolPGsByStateDesc
+	ch <- o.RecoveryHeadroomDesc
+	ch <- o.OpReadLatencyDesc
+	ch <- o.OpWriteLatencyDesc
 	ch <- o.PGObjectsRecoveredDesc
+	ch <- o.PGPeeringDurationDesc
 }
 
 // Collect sends all the collected metrics to the provided Prometheus channel.
 // It requires the caller to handle synchronization.
-func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	o.maxBackfills, o.numInOSDs, o.numRecoveringPGs = -1, -1, -1
+	o.opComplaintTime = osdDefaultOpComplaintTime
+
 	// Reset daemon specific metrics; daemons can leave the cluster
 	o.CrushWeight.Reset()
+	o.CrushWeightSet.Reset()
 	o.Depth.Reset()
 	o.Reweight.Reset()
 	o.Bytes.Reset()
 	o.UsedBytes.Reset()
 	o.AvailBytes.Reset()
 	o.Utilization.Reset()
+	o.UsedRatio.Reset()
 	o.Variance.Reset()
 	o.Pgs.Reset()
 	o.CommitLatency.Reset()
 	o.ApplyLatency.Reset()
 	o.OSDIn.Reset()
 	o.OSDUp.Reset()
+	o.OSDPrimaryAffinity.Reset()
+	o.ClassNearFullCount.Reset()
 	o.OSDMetadata.Reset()
-	o.buildOSDLabelCache()
+	o.HostOSDCount.Reset()
+	o.OpsInProgress.Reset()
+	o.OpsInFlight.Reset()
+	o.SlowOps.Reset()
+	o.BluestoreAllocated.Reset()
+	o.BluestoreStored.Reset()
+	o.ConfigValue.Reset()
+	o.BlocklistExpiry.Reset()
+	errs := &collectErrors{}
+	if err := o.buildOSDLabelCache(); err != nil {
+		o.logger.WithError(err).Error("error refreshing OSD labels")
+		errs.add(err)
+	}
+	o.collectOSDLabelCacheAge(ch)
+	o.collectHostOSDCount()
 
 	localWg := &sync.WaitGroup{}
 
@@ -1212,6 +3114,7 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
 		defer localWg.Done()
 		if err := o.collectOSDPerf(); err != nil {
 			o.logger.WithError(err).Error("error collecting OSD perf metrics")
+			errs.add(err)
 		}
 	}()
 
@@ -1220,6 +3123,7 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
 		defer localWg.Done()
 		if err := o.collectOSDMetadata(); err != nil {
 			o.logger.WithError(err).Error("error collecting OSD metadata metrics")
+			errs.add(err)
 		}
 	}()
 
@@ -1228,6 +3132,7 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
 		defer localWg.Done()
 		if err := o.collectOSDDump(); err != nil {
 			o.logger.WithError(err).Error("error collecting OSD dump metrics")
+			errs.add(err)
 		}
 	}()
 
@@ -1236,6 +3141,16 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
 		defer localWg.Done()
 		if err := o.collectOSDDF(); err != nil {
 			o.logger.WithError(err).Error("error collecting OSD df metrics")
+			errs.add(err)
+		}
+	}()
+
+	localWg.Add(1)
+	go func() {
+		defer localWg.Done()
+		if err := o.collectCrushWeightSets(); err != nil {
+			o.logger.WithError(err).Error("error collecting OSD crush weight-set metrics")
+			errs.add(err)
 		}
 	}()
 
@@ -1244,20 +3159,76 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
 		defer localWg.Done()
 		if err := o.collectOSDTreeDown(ch); err != nil {
 			o.logger.WithError(err).Error("error collecting OSD tree down metrics")
+			errs.add(err)
+		}
+	}()
+
+	localWg.Add(1)
+	go func() {
+		defer localWg.Done()
+		pgDump, err := o.pgDump.get()
+		if err != nil {
+			o.logger.WithError(err).Error("error collecting OSD PG metrics")
+			errs.add(err)
+			return
+		}
+
+		o.OldestUnscrubbedPG.Set(pgDump.oldestUnscrubbedAge(time.Now()))
+		o.BackfillBytesRemaining.Set(pgDump.backfillBytesRemaining())
+
+		o.collectOSDScrubState(ch, pgDump)
+		o.collectScrubsCompleted(pgDump)
+		o.collectOSDIdle(ch, pgDump)
+		o.collectOSDPGCounts(ch, pgDump)
+		o.collectPoolPGCounts(ch, pgDump)
+
+		if o.pgQuery {
+			o.collectPGBackfills(ch, pgDump)
 		}
 	}()
 
+	o.collectPGPeeringDurations(ch)
+
+	// The commands sent to each OSD fail for OSDs that are down, these are
+	// logged but don't fail the scrape.
+	if o.opQueue || o.perfDump {
+		localWg.Add(1)
+		go func() {
+			defer localWg.Done()
+			o.collectOSDPerfDumps(ch)
+		}()
+	}
+
+	// The ops in flight are only counted as slow once the configured
+	// osd_op_complaint_time is known.
 	localWg.Add(1)
 	go func() {
 		defer localWg.Done()
-		if err := o.collectOSDScrubState(ch); err != nil {
-			o.logger.WithError(err).Error("error collecting OSD scrub metrics")
+		o.collectOSDConfig(version)
+
+		if o.opQueue {
+			o.collectOSDOpsInFlight()
+		}
+	}()
+
+	localWg.Add(1)
+	go func() {
+		defer localWg.Done()
+		if err := o.collectOSDBlocklist(version); err != nil {
+			o.logger.WithError(err).Error("error collecting OSD blocklist metrics")
+			errs.add(err)
 		}
 	}()
 
 	localWg.Wait()
 
+	if headroom, ok := recoveryHeadroom(o.maxBackfills, o.numInOSDs, o.numRecoveringPGs); ok {
+		ch <- prometheus.MustNewConstMetric(o.RecoveryHeadroomDesc, prometheus.GaugeValue, headroom)
+	}
+
 	for _, metric := range o.collectorList() {
 		metric.Collect(ch)
 	}
+
+	return errs.err()
 }
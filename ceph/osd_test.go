@@ -16,11 +16,14 @@ package ceph
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
@@ -341,8 +344,28 @@ const (
 `
 )
 
+const testPGDumpScrubOutput = `
+{
+	"pg_ready": true,
+	"pg_stats": [
+		{
+			"pgid": "81.1fff",
+			"last_scrub_stamp": "2023-03-29T20:25:57.000000+0000"
+		},
+		{
+			"pgid": "82.1fff",
+			"last_scrub_stamp": "2023-03-26T00:00:00.000000+0000"
+		},
+		{
+			"pgid": "83.1fff",
+			"last_scrub_stamp": "2023-03-30T11:30:00.000000+0000"
+		}
+	]
+}
+`
+
 func TestOSDLabelBuilder(t *testing.T) {
-	osds, err := buildOSDLabels([]byte(testOSDTreeOutput))
+	osds, err := buildOSDLabels([]byte(testOSDTreeOutput), nil)
 	require.NoError(t, err)
 
 	osd, ok := osds[0]
@@ -354,6 +377,136 @@ func TestOSDLabelBuilder(t *testing.T) {
 	require.Equalf(t, "hdd", osd.DeviceClass, "expect to be an HDD")
 }
 
+func TestOSDLabelBuilderRelabel(t *testing.T) {
+	osds, err := buildOSDLabels([]byte(testOSDTreeOutput), []OSDLabelRelabel{
+		{Label: "host", Regex: regexp.MustCompile(`^(.*)-block01$`), Replacement: "$1"},
+		{Label: "root", Regex: regexp.MustCompile(`^default$`), Replacement: "main"},
+		// not matching, the label is kept as is
+		{Label: "rack", Regex: regexp.MustCompile(`^B\d+R\d+$`), Replacement: "other"},
+	})
+	require.NoError(t, err)
+
+	osd, ok := osds[0]
+	require.Truef(t, ok, "expect to find node in map")
+	require.Equal(t, "prod-data01", osd.Host)
+	require.Equal(t, "A8R1", osd.Rack)
+	require.Equal(t, "main", osd.Root)
+}
+
+func TestPGOldestUnscrubbedAge(t *testing.T) {
+	now := time.Date(2023, 3, 30, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name   string
+		input  string
+		expect float64
+	}{
+		{
+			name:   "varying scrub ages",
+			input:  testPGDumpScrubOutput,
+			expect: (4*24*time.Hour + 12*time.Hour).Seconds(),
+		},
+		{
+			name:   "legacy stamp format",
+			input:  `{"pg_stats":[{"pgid":"1.0","last_scrub_stamp":"2023-03-30 11:00:00.000000"}]}`,
+			expect: time.Hour.Seconds(),
+		},
+		{
+			name:   "unparsable stamps are skipped",
+			input:  `{"pg_stats":[{"pgid":"1.0","last_scrub_stamp":"0.000000"},{"pgid":"1.1","last_scrub_stamp":"2023-03-30T11:59:00.000000+0000"}]}`,
+			expect: time.Minute.Seconds(),
+		},
+		{
+			name:   "no pgs",
+			input:  `{"pg_stats":[]}`,
+			expect: 0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pgDump := &cephPGDump{}
+			require.NoError(t, json.Unmarshal([]byte(tt.input), pgDump))
+			require.Equal(t, tt.expect, pgDump.oldestUnscrubbedAge(now))
+		})
+	}
+}
+
+func TestPGBackfillBytesRemaining(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		input  string
+		expect float64
+	}{
+		{
+			name: "backfilling pgs",
+			input: `
+{
+	"pg_stats": [
+		{
+			"pgid": "1.0",
+			"state": "active+remapped+backfilling",
+			"stat_sum": {"num_bytes": 4194304000, "num_objects": 1000, "num_objects_degraded": 0, "num_objects_misplaced": 250}
+		},
+		{
+			"pgid": "1.1",
+			"state": "active+undersized+degraded+remapped+backfill_wait",
+			"stat_sum": {"num_bytes": 2097152000, "num_objects": 500, "num_objects_degraded": 500, "num_objects_misplaced": 0}
+		},
+		{
+			"pgid": "1.2",
+			"state": "active+clean",
+			"stat_sum": {"num_bytes": 4194304000, "num_objects": 1000, "num_objects_degraded": 0, "num_objects_misplaced": 0}
+		}
+	]
+}`,
+			expect: 250*4194304 + 500*4194304,
+		},
+		{
+			name: "missing progress fields are skipped",
+			input: `
+{
+	"pg_stats": [
+		{
+			"pgid": "1.0",
+			"state": "active+remapped+backfilling",
+			"stat_sum": {"num_bytes": 4194304000, "num_objects": 1000}
+		},
+		{
+			"pgid": "1.1",
+			"state": "active+remapped+backfilling",
+			"stat_sum": {"num_bytes": 4194304000, "num_objects": 1000, "num_objects_misplaced": 10}
+		}
+	]
+}`,
+			expect: 10 * 4194304,
+		},
+		{
+			name: "empty backfilling pg",
+			input: `
+{
+	"pg_stats": [
+		{
+			"pgid": "1.0",
+			"state": "active+remapped+backfilling",
+			"stat_sum": {"num_bytes": 0, "num_objects": 0, "num_objects_degraded": 0, "num_objects_misplaced": 0}
+		}
+	]
+}`,
+			expect: 0,
+		},
+		{
+			name:   "no pgs",
+			input:  `{"pg_stats":[]}`,
+			expect: 0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pgDump := &cephPGDump{}
+			require.NoError(t, json.Unmarshal([]byte(tt.input), pgDump))
+			require.Equal(t, tt.expect, pgDump.backfillBytesRemaining())
+		})
+	}
+}
+
 func TestOSDCollector(t *testing.T) {
 	reMatch := []*regexp.Regexp{
 		regexp.MustCompile(`ceph_osd_crush_weight{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} 0.010391`),
@@ -391,6 +544,8 @@ func TestOSDCollector(t *testing.T) {
 		regexp.MustCompile(`ceph_osd_utilization{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.2",rack="A8R1",root="default"} 0.329246`),
 		regexp.MustCompile(`ceph_osd_utilization{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.3",rack="A8R1",root="default"} 0.329892`),
 		regexp.MustCompile(`ceph_osd_utilization{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.4",rack="A8R1",root="default"} 0`),
+		regexp.MustCompile(`ceph_osd_used_ratio{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} 0.00365657798397`),
+		regexp.MustCompile(`ceph_osd_used_ratio{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.1",rack="A8R1",root="default"} 0.00363326025917`),
 		regexp.MustCompile(`ceph_osd_variance{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} 1.053676`),
 		regexp.MustCompile(`ceph_osd_variance{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.1",rack="A8R1",root="default"} 1.046957`),
 		regexp.MustCompile(`ceph_osd_variance{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.2",rack="A8R1",root="default"} 0.948753`),
@@ -414,11 +569,11 @@ func TestOSDCollector(t *testing.T) {
 		regexp.MustCompile(`ceph_osd_in{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.2",rack="A8R1",root="default"} 1`),
 		regexp.MustCompile(`ceph_osd_in{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.3",rack="A8R1",root="default"} 1`),
 		regexp.MustCompile(`ceph_osd_in{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.4",rack="A8R1",root="default"} 0`),
-		regexp.MustCompile(`ceph_osd_metadata{ceph_version_when_created="ceph version 16.2.11-119-g6e981ce \(6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b\) pacific \(stable\)",cluster="ceph",created_at="2023-03-24T20:25:57.763728Z",objectstore="bluestore",osd="0"} 1`),
-		regexp.MustCompile(`ceph_osd_metadata{ceph_version_when_created="",cluster="ceph",created_at="",objectstore="filestore",osd="1"} 1`),
-		regexp.MustCompile(`ceph_osd_metadata{ceph_version_when_created="ceph version 16.2.11-119-g6e981ce \(6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b\) pacific \(stable\)",cluster="ceph",created_at="2023-03-24T20:25:57.763728Z",objectstore="bluestore",osd="2"} 1`),
-		regexp.MustCompile(`ceph_osd_metadata{ceph_version_when_created="",cluster="ceph",created_at="",objectstore="filestore",osd="3"} 1`),
-		regexp.MustCompile(`ceph_osd_metadata{ceph_version_when_created="",cluster="ceph",created_at="",objectstore="filestore",osd="4"} 1`),
+		regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="hdd",ceph_version="ceph version 16.2.11-119-g6e981ce \(6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b\) pacific \(stable\)",ceph_version_when_created="ceph version 16.2.11-119-g6e981ce \(6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b\) pacific \(stable\)",cluster="ceph",created_at="2023-03-24T20:25:57.763728Z",db_device="/dev/nvme0n1",device_class="hdd",objectstore="bluestore",osd="0",wal_device=""} 1`),
+		regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="",ceph_version="",ceph_version_when_created="",cluster="ceph",created_at="",db_device="",device_class="ssd",objectstore="filestore",osd="1",wal_device=""} 1`),
+		regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="ssd",ceph_version="ceph version 16.2.11-119-g6e981ce \(6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b\) pacific \(stable\)",ceph_version_when_created="ceph version 16.2.11-119-g6e981ce \(6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b\) pacific \(stable\)",cluster="ceph",created_at="2023-03-24T20:25:57.763728Z",db_device="",device_class="ssd",objectstore="bluestore",osd="2",wal_device=""} 1`),
+		regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="",ceph_version="",ceph_version_when_created="",cluster="ceph",created_at="",db_device="",device_class="ssd",objectstore="filestore",osd="3",wal_device=""} 1`),
+		regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="",ceph_version="",ceph_version_when_created="",cluster="ceph",created_at="",db_device="",device_class="ssd",objectstore="filestore",osd="4",wal_device=""} 1`),
 		regexp.MustCompile(`ceph_osd_up{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} 1`),
 		regexp.MustCompile(`ceph_osd_up{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.1",rack="A8R1",root="default"} 1`),
 		regexp.MustCompile(`ceph_osd_up{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.2",rack="A8R1",root="default"} 1`),
@@ -439,6 +594,24 @@ func TestOSDCollector(t *testing.T) {
 		regexp.MustCompile(`ceph_osd_backfill_full{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.2",rack="A8R1",root="default"} 0`),
 		regexp.MustCompile(`ceph_osd_backfill_full{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.3",rack="A8R1",root="default"} 1`),
 		regexp.MustCompile(`ceph_osd_backfill_full{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.4",rack="A8R1",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_class_nearfull_count{cluster="ceph",device_class="hdd"} 0`),
+		regexp.MustCompile(`ceph_osd_class_nearfull_count{cluster="ceph",device_class="ssd"} 3`),
+
+		regexp.MustCompile(`ceph_pg_oldest_unscrubbed_age_seconds{cluster="ceph"} [0-9.e+]+`),
+		regexp.MustCompile(`ceph_backfill_bytes_remaining{cluster="ceph"} 0`),
+		regexp.MustCompile(`ceph_osd_blocklist_entries{cluster="ceph"} 3`),
+		regexp.MustCompile(`ceph_osd_blocklist_expired_entries{cluster="ceph"} 1`),
+		regexp.MustCompile(`ceph_osd_blocklist_expiry_timestamp_seconds{addr="10.10.1.21:0/3710147553",cluster="ceph"} 9.466848e\+08`),
+		regexp.MustCompile(`ceph_osd_blocklist_expiry_timestamp_seconds{addr="10.10.1.23:6801/2984",cluster="ceph"} 3.24853218e\+10`),
+		regexp.MustCompile(`ceph_osd_blocklist_latest_expiry_timestamp_seconds{cluster="ceph"} 3.24853218e\+10`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_max_backfills"} 1`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_recovery_max_active"} 3`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_scrub_sleep"} 0.1`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_snap_trim_sleep"} 0`),
+		regexp.MustCompile(`ceph_osd_config_value{cluster="ceph",option="osd_op_complaint_time"} 30`),
+		regexp.MustCompile(`ceph_host_osd_count{cluster="ceph",device_class="hdd",host="prod-data01-block01"} 1`),
+		regexp.MustCompile(`ceph_host_osd_count{cluster="ceph",device_class="ssd",host="prod-data01-block01"} 14`),
+		regexp.MustCompile(`ceph_host_osd_count{cluster="ceph",device_class="ssd",host="prod-data02-block01"} 2`),
 
 		regexp.MustCompile(`ceph_osd_scrub_state{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.10",rack="A8R1",root="default"} 1`),
 		regexp.MustCompile(`ceph_osd_scrub_state{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.11",rack="A8R1",root="default"} 1`),
@@ -451,9 +624,9 @@ func TestOSDCollector(t *testing.T) {
 	}
 
 	for _, tt := range []struct {
-		test    string
-		version string
-		reMatch []*regexp.Regexp
+		test               string
+		version            string
+		reMatch, reUnmatch []*regexp.Regexp
 	}{
 		{
 			test:    "1",
@@ -461,6 +634,10 @@ func TestOSDCollector(t *testing.T) {
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="ssd",host="prod-data02-block01",osd="osd.524",rack="A8R2",root="default",status="destroyed"} 1`),
 			},
+			reUnmatch: []*regexp.Regexp{
+				// osd.4 reports no capacity
+				regexp.MustCompile(`ceph_osd_used_ratio{[^}]*osd="osd.4"`),
+			},
 		},
 		{
 			test:    "2",
@@ -488,6 +665,22 @@ func TestOSDCollector(t *testing.T) {
 			test:    "5",
 			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
 			reMatch: []*regexp.Regexp{},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down_reason`),
+			},
+		},
+		{
+			test:    "6",
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="ssd",host="prod-data02-block01",osd="osd.524",rack="A8R2",root="default",status="down"} 1`),
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="ssd",host="prod-data02-block01",osd="osd.525",rack="A8R2",root="default",status="down"} 1`),
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data02-block01",osd="osd.524"} 1`),
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data02-block01",osd="osd.525"} 1`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data01-block01"`),
+			},
 		},
 	} {
 		func() {
@@ -668,6 +861,66 @@ func TestOSDCollector(t *testing.T) {
 				"5": `
 {
   "nodes": []}}
+}`,
+				"6": `
+{
+	"nodes": [
+		{
+			"id": -1,
+			"name": "default",
+			"type": "root",
+			"type_id": 10,
+			"children": [
+				-15
+			]
+		},
+		{
+			"id": -15,
+			"name": "A8R2",
+			"type": "rack",
+			"type_id": 3,
+			"children": [
+				-3
+			]
+		},
+		{
+			"id": -3,
+			"name": "prod-data02-block01",
+			"type": "host",
+			"type_id": 1,
+			"children": [
+				525,
+				524
+			]
+		},
+		{
+			"id": 524,
+			"device_class": "ssd",
+			"name": "osd.524",
+			"type": "osd",
+			"type_id": 0,
+			"crush_weight": 3.481995,
+			"depth": 3,
+			"exists": 1,
+			"status": "down",
+			"reweight": 1.000000,
+			"primary_affinity": 1.000000
+		},
+		{
+			"id": 525,
+			"device_class": "ssd",
+			"name": "osd.525",
+			"type": "osd",
+			"type_id": 0,
+			"crush_weight": 3.481995,
+			"depth": 3,
+			"exists": 1,
+			"status": "down",
+			"reweight": 1.000000,
+			"primary_affinity": 1.000000
+		}
+	],
+	"stray": []
 }`,
 			}[tt.test]), "", nil)
 
@@ -683,7 +936,7 @@ func TestOSDCollector(t *testing.T) {
 
 				return cmp.Equal(v, map[string]interface{}{
 					"prefix":       "pg dump",
-					"dumpcontents": []interface{}{"pgs_brief"},
+					"dumpcontents": []interface{}{"pgs"},
 					"format":       "json",
 				})
 			})).Return([]byte(`
@@ -699,6 +952,7 @@ func TestOSDCollector(t *testing.T) {
 			],
 			"acting_primary": 1,
 			"pgid": "81.1fff",
+			"last_scrub_stamp": "2023-03-29T20:25:57.000000+0000",
 			"state": "active+clean"
 		},
 		{
@@ -710,6 +964,7 @@ func TestOSDCollector(t *testing.T) {
 			],
 			"acting_primary": 10,
 			"pgid": "82.1fff",
+			"last_scrub_stamp": "2023-03-26T00:00:00.000000+0000",
 			"state": "active+clean+scrubbing"
 			},
 		{
@@ -721,6 +976,7 @@ func TestOSDCollector(t *testing.T) {
 			],
 			"acting_primary": 20,
 			"pgid": "83.1fff",
+			"last_scrub_stamp": "2023-03-30T11:30:00.000000+0000",
 			"state": "active+clean+scrubbing+deep"
 		}
 	]
@@ -815,6 +1071,59 @@ func TestOSDCollector(t *testing.T) {
 	]
 }`), "", nil)
 
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				v := map[string]interface{}{}
+
+				err := json.Unmarshal(in.([]byte), &v)
+				require.NoError(t, err)
+
+				return cmp.Equal(v, map[string]interface{}{
+					"prefix": "osd blocklist ls",
+					"format": "json",
+				})
+			})).Return([]byte(`
+[
+	{"addr": "10.10.1.21:0/3710147553", "until": "2000-01-01T00:00:00.000000+0000"},
+	{"addr": "10.10.1.22:0/1873401987", "until": "2999-06-01T12:30:00.000000+0000"},
+	{"addr": "10.10.1.23:6801/2984", "until": "2999-06-02T12:30:00.000000+0000"}
+]`), "", nil)
+
+			// The cluster isn't balanced with weight-sets.
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				v := map[string]interface{}{}
+
+				err := json.Unmarshal(in.([]byte), &v)
+				require.NoError(t, err)
+
+				return cmp.Equal(v, map[string]interface{}{
+					"prefix": "osd crush dump",
+					"format": "json",
+				})
+			})).Return([]byte(`{"buckets": [], "choose_args": {}}`), "", nil)
+
+			for option, value := range map[string]string{
+				"osd_max_backfills":       `1`,
+				"osd_recovery_max_active": `"3"`,
+				"osd_scrub_sleep":         `{"osd_scrub_sleep":"0.100000"}`,
+				"osd_snap_trim_sleep":     `0.000000`,
+				"osd_op_complaint_time":   `"30.000000"`,
+			} {
+				option := option
+				conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+					v := map[string]interface{}{}
+
+					err := json.Unmarshal(in.([]byte), &v)
+					require.NoError(t, err)
+
+					return cmp.Equal(v, map[string]interface{}{
+						"prefix": "config get",
+						"who":    "osd",
+						"key":    option,
+						"format": "json",
+					})
+				})).Return([]byte(value), "", nil)
+			}
+
 			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
 				v := map[string]interface{}{}
 
@@ -830,8 +1139,14 @@ func TestOSDCollector(t *testing.T) {
 	{
 		"id": 0,
 		"osd_objectstore": "bluestore",
+		"ceph_version": "ceph version 16.2.11-119-g6e981ce (6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b) pacific (stable)",
 		"ceph_version_when_created": "ceph version 16.2.11-119-g6e981ce (6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b) pacific (stable)",
-		"created_at": "2023-03-24T20:25:57.763728Z"
+		"created_at": "2023-03-24T20:25:57.763728Z",
+		"bluestore_bdev_type": "hdd",
+		"bluefs_dedicated_db": "1",
+		"bluefs_db_dev_node": "/dev/nvme0n1",
+		"bluefs_dedicated_wal": "0",
+		"bluefs_wal_dev_node": "/dev/nvme0n1"
 	},
 	{
 		"id": 1,
@@ -842,8 +1157,12 @@ func TestOSDCollector(t *testing.T) {
 	{
 		"id": 2,
 		"osd_objectstore": "bluestore",
+		"ceph_version": "ceph version 16.2.11-119-g6e981ce (6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b) pacific (stable)",
 		"ceph_version_when_created": "ceph version 16.2.11-119-g6e981ce (6e981ceb1084ad7628ea32a6a0a23ce09bc5cf8b) pacific (stable)",
-		"created_at": "2023-03-24T20:25:57.763728Z"
+		"created_at": "2023-03-24T20:25:57.763728Z",
+		"bluestore_bdev_type": "ssd",
+		"bluefs_dedicated_db": "0",
+		"bluefs_dedicated_wal": "0"
 	},
 	{
 		"id": 3,
@@ -1021,7 +1340,7 @@ func TestOSDCollector(t *testing.T) {
     }
 }`), "", nil)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"osd": NewOSDCollector(e),
 			}
@@ -1042,6 +1361,1213 @@ func TestOSDCollector(t *testing.T) {
 			for _, re := range append(reMatch, tt.reMatch...) {
 				require.True(t, re.Match(buf))
 			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
 		}()
 	}
 }
+
+// isMonCommand returns a matcher for the mon commands that decode to cmd.
+func isMonCommand(cmd map[string]interface{}) func([]byte) bool {
+	return func(in []byte) bool {
+		v := map[string]interface{}{}
+		_ = json.Unmarshal(in, &v)
+		return cmp.Equal(v, cmd)
+	}
+}
+
+// isMgrCommand is isMonCommand for mgr and OSD commands, which are sent as a
+// single buffer.
+func isMgrCommand(cmd map[string]interface{}) func([][]byte) bool {
+	return func(in [][]byte) bool {
+		return len(in) == 1 && isMonCommand(cmd)(in[0])
+	}
+}
+
+// osdTestConn returns a connection to a Pacific cluster for the OSD collector
+// tests. It answers the osd tree with osdTree unless it is empty, then the
+// commands under test as set up by mocks, and fails any other mon or mgr
+// command.
+func osdTestConn(osdTree string, mocks func(conn *MockConn)) *MockConn {
+	conn := setupVersionMocks(`{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`, "{}")
+
+	if osdTree != "" {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd tree",
+			"format": "json",
+		}))).Return([]byte(osdTree), "", nil)
+	}
+
+	if mocks != nil {
+		mocks(conn)
+	}
+
+	conn.On("MonCommand", mock.Anything).Return([]byte(""), "", fmt.Errorf("not under test"))
+	conn.On("MgrCommand", mock.Anything).Return([]byte(""), "", fmt.Errorf("not under test"))
+	conn.On("OsdCommand", mock.Anything, mock.Anything).Return([]byte(""), "", fmt.Errorf("not under test"))
+
+	return conn
+}
+
+// serveOSDCollector serves the metrics of e, with o as its only collector,
+// until the test ends.
+func serveOSDCollector(t *testing.T, e *Exporter, o *OSDCollector) *httptest.Server {
+	e.cc = map[string]versionedCollector{
+		"osd": o,
+	}
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// requireScrape scrapes server and requires every regex of reMatch and none
+// of reUnmatch to match, the output is returned for any further checks.
+func requireScrape(t *testing.T, server *httptest.Server, reMatch, reUnmatch []*regexp.Regexp) []byte {
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, re := range reMatch {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+	for _, re := range reUnmatch {
+		require.False(t, re.Match(buf), "expected %s not to match", re.String())
+	}
+
+	return buf
+}
+
+func TestOSDCollectorOpsInProgress(t *testing.T) {
+	perfDump := mock.MatchedBy(isMgrCommand(map[string]interface{}{
+		"prefix": "perf dump",
+		"format": "json",
+	}))
+
+	conn := osdTestConn(testOSDTreeOutput, func(conn *MockConn) {
+		conn.On("OsdCommand", 0, perfDump).Return([]byte(`
+{
+	"osd": {
+		"op_wip": 7,
+		"op": 1893402,
+		"op_in_bytes": 1739145216,
+		"op_out_bytes": 2389451
+	}
+}`), "", nil)
+		conn.On("OsdCommand", mock.Anything, perfDump).Return([]byte(`{"osd": {"op_wip": 0}}`), "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{OSDOpQueue: true}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_ops_in_progress{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} 7`),
+		regexp.MustCompile(`ceph_osd_ops_in_progress{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.1",rack="A8R1",root="default"} 0`),
+	}, []*regexp.Regexp{
+		// destroyed OSDs are not queried
+		regexp.MustCompile(`ceph_osd_ops_in_progress{[^}]*osd="osd.524"`),
+	})
+
+	conn.AssertNotCalled(t, "OsdCommand", 524, mock.Anything)
+}
+
+func TestOSDCollectorConfigBeforeMimic(t *testing.T) {
+	configGet := mock.MatchedBy(func(in []byte) bool {
+		v := map[string]interface{}{}
+		_ = json.Unmarshal(in, &v)
+		return v["prefix"] == "config get"
+	})
+
+	conn := setupVersionMocks(`{"version":"ceph version 12.2.13 (584a20eb0237c657dc0567da126be145106aa47e) luminous (stable)"}`, "{}")
+	conn.On("MonCommand", mock.Anything).Return([]byte(""), "", fmt.Errorf("not under test"))
+	conn.On("MgrCommand", mock.Anything).Return([]byte(""), "", fmt.Errorf("not under test"))
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	// `config get` doesn't exist before Mimic, so it isn't sent.
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_exporter_collector_skipped{cluster="ceph",collector="osd/config",reason="version"} 1`),
+	}, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_config_value{`),
+	})
+
+	conn.AssertNotCalled(t, "MonCommand", configGet)
+}
+
+func TestOSDCollectorOpsInFlight(t *testing.T) {
+	dumpOpsInFlight := mock.MatchedBy(isMgrCommand(map[string]interface{}{
+		"prefix": "dump_ops_in_flight",
+		"format": "json",
+	}))
+
+	for _, tt := range []struct {
+		name          string
+		complaintTime string
+		slowOps       string
+	}{
+		{
+			// `config get` fails
+			name:    "default complaint time",
+			slowOps: "2",
+		},
+		{
+			name:          "configured complaint time",
+			complaintTime: `"40.000000"`,
+			slowOps:       "1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := osdTestConn(testOSDTreeOutput, func(conn *MockConn) {
+				if tt.complaintTime != "" {
+					conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+						"prefix": "config get",
+						"who":    "osd",
+						"key":    "osd_op_complaint_time",
+						"format": "json",
+					}))).Return([]byte(tt.complaintTime), "", nil)
+				}
+
+				conn.On("OsdCommand", 0, dumpOpsInFlight).Return([]byte(`
+{
+	"ops": [
+		{"description": "osd_op(client.4123.0:81 2.1f 2:f8a3b1c2:::rbd_data.10a2:head [write 0~4096] snapc 0=[] ondisk+write+known_if_redirected e512)", "initiated_at": "2023-01-10T10:00:00.000000+0000", "age": 45.3, "duration": 45.3},
+		{"description": "osd_op(client.4123.0:82 2.1f 2:f8a3b1c2:::rbd_data.10a2:head [write 4096~4096] snapc 0=[] ondisk+write+known_if_redirected e512)", "initiated_at": "2023-01-10T10:00:10.000000+0000", "age": 35.1, "duration": 35.1},
+		{"description": "osd_op(client.4123.0:90 2.3 2:c0a1b2d3:::rbd_data.10a2:head [read 0~4096] ondisk+read+known_if_redirected e512)", "initiated_at": "2023-01-10T10:00:44.000000+0000", "age": 1.2, "duration": 1.2}
+	],
+	"num_ops": 3
+}`), "", nil)
+				conn.On("OsdCommand", mock.Anything, dumpOpsInFlight).Return([]byte(`{"ops": [], "num_ops": 0}`), "", nil)
+			})
+
+			e := newExporter(conn, "ceph", ExporterOptions{OSDOpQueue: true}, logrus.New())
+			server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+			requireScrape(t, server, []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_ops_in_flight{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} 3`),
+				regexp.MustCompile(`ceph_osd_slow_ops{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="A8R1",root="default"} ` + tt.slowOps + `\n`),
+				regexp.MustCompile(`ceph_osd_ops_in_flight{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.1",rack="A8R1",root="default"} 0`),
+				regexp.MustCompile(`ceph_osd_slow_ops{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.1",rack="A8R1",root="default"} 0`),
+			}, nil)
+
+			conn.AssertNotCalled(t, "OsdCommand", 524, mock.Anything)
+		})
+	}
+}
+
+func TestOSDCollectorOpLatency(t *testing.T) {
+	var perfDump string
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("OsdCommand", 0, mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix": "perf dump",
+			"format": "json",
+		}))).Return(func(int, [][]byte) []byte {
+			return []byte(perfDump)
+		}, "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{OSDPerfDump: true}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	for _, tt := range []struct {
+		name      string
+		perfDump  string
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name: "bluestore",
+			perfDump: `
+{
+	"osd": {
+		"op_wip": 3,
+		"op_r_latency": {"avgcount": 1000, "sum": 2, "avgtime": 0.002},
+		"op_w_latency": {"avgcount": 500, "sum": 5, "avgtime": 0.01}
+	},
+	"bluestore": {
+		"bluestore_allocated": 1932735283200,
+		"bluestore_stored": 1288490188800
+	}
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_op_read_latency_seconds_sum{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 2\n`),
+				regexp.MustCompile(`ceph_osd_op_read_latency_seconds_count{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1000\n`),
+				regexp.MustCompile(`ceph_osd_op_write_latency_seconds_sum{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 5\n`),
+				regexp.MustCompile(`ceph_osd_op_write_latency_seconds_count{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 500\n`),
+				regexp.MustCompile(`ceph_osd_bluestore_allocated_bytes{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1.9327352832e\+12`),
+				regexp.MustCompile(`ceph_osd_bluestore_stored_bytes{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1.2884901888e\+12`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				// only with OSD_OP_QUEUE
+				regexp.MustCompile(`ceph_osd_ops_in_progress{`),
+			},
+		},
+		{
+			name: "filestore",
+			perfDump: `
+{
+	"osd": {
+		"op_wip": 3,
+		"op_r_latency": {"avgcount": 1100, "sum": 2.5, "avgtime": 0.00227},
+		"op_w_latency": {"avgcount": 500, "sum": 5, "avgtime": 0.01}
+	}
+}`,
+			reMatch: []*regexp.Regexp{
+				// the counters as is, not since the previous scrape
+				regexp.MustCompile(`ceph_osd_op_read_latency_seconds_sum{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 2.5\n`),
+				regexp.MustCompile(`ceph_osd_op_read_latency_seconds_count{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1100\n`),
+				regexp.MustCompile(`ceph_osd_op_write_latency_seconds_count{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 500\n`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				// no bluestore section, e.g. a FileStore OSD
+				regexp.MustCompile(`ceph_osd_bluestore_allocated_bytes{`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			perfDump = tt.perfDump
+			requireScrape(t, server, tt.reMatch, tt.reUnmatch)
+		})
+	}
+}
+
+func TestOSDCollectorIdle(t *testing.T) {
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [3, 2, 1, 0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 2, "device_class": "hdd", "name": "osd.2", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 3, "device_class": "hdd", "name": "osd.3", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 0, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix":       "pg dump",
+			"dumpcontents": []interface{}{"pgs"},
+			"format":       "json",
+		}))).Return([]byte(`
+{
+	"pg_stats": [
+		{"pgid": "1.0", "state": "active+clean", "acting": [0, 1, 2], "acting_primary": 0},
+		{"pgid": "1.1", "state": "active+clean", "acting": [1, 2, 0], "acting_primary": 1},
+		{"pgid": "1.2", "state": "active+clean", "acting": [0, 2, 1], "acting_primary": 0}
+	]
+}`), "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_idle{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 0`),
+		regexp.MustCompile(`ceph_osd_idle{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 0`),
+		regexp.MustCompile(`ceph_osd_idle{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",rack="",root="default"} 1`),
+	}, []*regexp.Regexp{
+		// out OSDs are not reported, they are expected to have no PGs
+		regexp.MustCompile(`ceph_osd_idle{[^}]*osd="osd.3"`),
+	})
+}
+
+func TestOSDCollectorCrushWeightSet(t *testing.T) {
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [1, 0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd crush dump",
+			"format": "json",
+		}))).Return([]byte(`
+{
+	"buckets": [
+		{"id": -1, "name": "default", "type_name": "root", "weight": 954204, "items": [{"id": -2, "weight": 954204, "pos": 0}]},
+		{"id": -2, "name": "prod-data01-block01", "type_name": "host", "weight": 954204, "items": [{"id": 1, "weight": 477102, "pos": 0}, {"id": 0, "weight": 477102, "pos": 1}]}
+	],
+	"choose_args": {
+		"-1": [
+			{"bucket_id": -1, "weight_set": [[14.56]]},
+			{"bucket_id": -2, "weight_set": [[7.1, 7.46]]}
+		],
+		"3": [
+			{"bucket_id": -2, "weight_set": [[7.3, 7.26]], "ids": [-5, -6]}
+		]
+	}
+}`), "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_crush_weight_set{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",weight_set="compat"} 7.46`),
+		regexp.MustCompile(`ceph_osd_crush_weight_set{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default",weight_set="compat"} 7.1`),
+		regexp.MustCompile(`ceph_osd_crush_weight_set{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",weight_set="3"} 7.26`),
+		regexp.MustCompile(`ceph_osd_crush_weight_set{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default",weight_set="3"} 7.3`),
+	}, []*regexp.Regexp{
+		// buckets have no weight-set series of their own
+		regexp.MustCompile(`ceph_osd_crush_weight_set{[^}]*osd="osd.-`),
+	})
+}
+
+func TestOSDCollectorPGCounts(t *testing.T) {
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [3, 2, 1, 0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 2, "device_class": "hdd", "name": "osd.2", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 3, "device_class": "hdd", "name": "osd.3", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 0, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix":       "pg dump",
+			"dumpcontents": []interface{}{"pgs"},
+			"format":       "json",
+		}))).Return([]byte(`
+{
+	"pg_stats": [
+		{"pgid": "1.0", "state": "active+clean", "acting": [0, 1, 2], "acting_primary": 0},
+		{"pgid": "1.1", "state": "active+remapped+backfilling", "up": [1, 2, 3], "acting": [1, 2, 0], "acting_primary": 1},
+		{"pgid": "1.2", "state": "active+undersized+degraded+remapped+backfill_wait", "up": [0, 2, 3], "acting": [0, 2, 1], "acting_primary": 0},
+		{"pgid": "1.3", "state": "active+clean+remapped", "up": [2, 0, 1], "acting": [2, 0], "acting_primary": 2},
+		{"pgid": "1.4", "state": "down", "acting": [3, 2147483647, 2147483647], "acting_primary": 3},
+		{"pgid": "1.5", "state": "incomplete", "acting": [3, 1], "acting_primary": 3},
+		{"pgid": "1.6", "state": "stale+active+clean", "acting": [1, 0, 3], "acting_primary": 1},
+		{"pgid": "1.7", "state": "peering+remapped+down", "acting": [3, 0], "acting_primary": 3},
+		{"pgid": "2.0", "state": "active+clean+snaptrim", "acting": [2, 1, 0], "acting_primary": 2},
+		{"pgid": "2.1", "state": "active+clean+snaptrim_wait", "acting": [2, 0, 3], "acting_primary": 2},
+		{"pgid": "2.2", "state": "stale+down", "acting": [], "acting_primary": -1}
+	]
+}`), "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_remapped_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 4`),
+		regexp.MustCompile(`ceph_osd_remapped_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_remapped_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",rack="",root="default"} 3`),
+		regexp.MustCompile(`ceph_osd_remapped_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_pgs_unavailable{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_pgs_unavailable{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_pgs_unavailable{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",rack="",root="default"} 0`),
+		regexp.MustCompile(`ceph_osd_pgs_unavailable{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 4`),
+		regexp.MustCompile(`ceph_osd_snaptrimming_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_snaptrimming_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_snaptrimming_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_snaptrimming_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 1`),
+		// stale PGs aren't counted as healthy
+		regexp.MustCompile(`ceph_osd_healthy_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 4`),
+		regexp.MustCompile(`ceph_osd_healthy_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_healthy_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",rack="",root="default"} 4`),
+		regexp.MustCompile(`ceph_osd_healthy_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_primary_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_primary_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_primary_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",rack="",root="default"} 3`),
+		regexp.MustCompile(`ceph_osd_primary_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 3`),
+		// backfills count towards the OSDs they backfill to only
+		regexp.MustCompile(`ceph_osd_backfilling_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 0`),
+		regexp.MustCompile(`ceph_osd_backfilling_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_backfill_wait_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 0`),
+		regexp.MustCompile(`ceph_osd_backfill_wait_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_degraded_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_degraded_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 0`),
+		// remapped but clean PGs have nothing left to move
+		regexp.MustCompile(`ceph_osd_misplaced_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 3`),
+		regexp.MustCompile(`ceph_osd_misplaced_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 2`),
+		regexp.MustCompile(`ceph_osd_misplaced_pgs{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.3",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_pool_pgs{cluster="ceph",pool_id="1"} 8`),
+		regexp.MustCompile(`ceph_pool_pgs{cluster="ceph",pool_id="2"} 3`),
+		regexp.MustCompile(`ceph_pool_pgs_by_state{cluster="ceph",pool_id="1",state="active\+clean"} 1`),
+		regexp.MustCompile(`ceph_pool_pgs_by_state{cluster="ceph",pool_id="1",state="down"} 1`),
+		regexp.MustCompile(`ceph_pool_pgs_by_state{cluster="ceph",pool_id="2",state="active\+clean\+snaptrim_wait"} 1`),
+	}, nil)
+}
+
+func TestOSDCollectorPGBackfills(t *testing.T) {
+	// The mocks are called from the server's goroutines.
+	var (
+		mu               sync.Mutex
+		pgState, pgQuery string
+	)
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [2, 1, 0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 2, "device_class": "hdd", "name": "osd.2", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix":       "pg dump",
+			"dumpcontents": []interface{}{"pgs"},
+			"format":       "json",
+		}))).Return(func([][]byte) []byte {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return []byte(`
+{
+	"pg_stats": [
+		{"pgid": "1.0", "state": "active+clean", "acting": [0, 1, 2], "acting_primary": 0},
+		{"pgid": "1.1", "state": "` + pgState + `", "acting": [0, 1], "acting_primary": 0}
+	]
+}`)
+		}, "", nil)
+
+		// Only the backfilling PG is queried, through its primary.
+		conn.On("OsdCommand", 0, mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix": "query",
+			"pgid":   "1.1",
+			"format": "json",
+		}))).Return(func(int, [][]byte) []byte {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return []byte(pgQuery)
+		}, "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{PGQuery: true}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	for _, tt := range []struct {
+		name      string
+		pgState   string
+		pgQuery   string
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:    "backfill started",
+			pgState: "active+remapped+backfilling",
+			pgQuery: `
+{
+	"state": "active+remapped+backfilling",
+	"info": {"stats": {"stat_sum": {"num_objects_recovered": 100}}},
+	"recovery_state": [
+		{"name": "Started/Primary/Active", "recovery_progress": {"backfill_targets": ["2"]}},
+		{"name": "Started"}
+	]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pg_objects_recovered{cluster="ceph",pgid="1.1"} 100`),
+				regexp.MustCompile(`ceph_osd_objects_backfilled{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",pgid="1.1",rack="",root="default"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pg_objects_recovered{cluster="ceph",pgid="1.0"}`),
+			},
+		},
+		{
+			name:    "backfill progressed",
+			pgState: "active+remapped+backfilling",
+			pgQuery: `
+{
+	"state": "active+remapped+backfilling",
+	"info": {"stats": {"stat_sum": {"num_objects_recovered": 250}}},
+	"recovery_state": [
+		{"name": "Started/Primary/Active", "recovery_progress": {"backfill_targets": ["2", "1(1)"]}},
+		{"name": "Started"}
+	]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pg_objects_recovered{cluster="ceph",pgid="1.1"} 250`),
+				regexp.MustCompile(`ceph_osd_objects_backfilled{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.2",pgid="1.1",rack="",root="default"} 150`),
+				// erasure coded shard
+				regexp.MustCompile(`ceph_osd_objects_backfilled{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",pgid="1.1",rack="",root="default"} 150`),
+			},
+		},
+		{
+			name:    "backfill done",
+			pgState: "active+clean",
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pg_objects_recovered{`),
+				regexp.MustCompile(`ceph_osd_objects_backfilled{`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			pgState, pgQuery = tt.pgState, tt.pgQuery
+			mu.Unlock()
+
+			requireScrape(t, server, tt.reMatch, tt.reUnmatch)
+		})
+	}
+}
+
+func TestRecoveryHeadroom(t *testing.T) {
+	for _, tt := range []struct {
+		name                                string
+		maxBackfills, inOSDs, recoveringPGs float64
+		expect                              float64
+		ok                                  bool
+	}{
+		{name: "idle", maxBackfills: 1, inOSDs: 10, recoveringPGs: 0, expect: 1, ok: true},
+		{name: "half used", maxBackfills: 2, inOSDs: 10, recoveringPGs: 10, expect: 0.5, ok: true},
+		{name: "saturated", maxBackfills: 1, inOSDs: 10, recoveringPGs: 25, expect: 0, ok: true},
+		{name: "no in OSDs", maxBackfills: 1, inOSDs: 0, recoveringPGs: 0, ok: false},
+		{name: "unknown config", maxBackfills: -1, inOSDs: 10, recoveringPGs: 0, ok: false},
+		{name: "unknown PGs", maxBackfills: 1, inOSDs: 10, recoveringPGs: -1, ok: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			headroom, ok := recoveryHeadroom(tt.maxBackfills, tt.inOSDs, tt.recoveringPGs)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expect, headroom)
+		})
+	}
+}
+
+func TestOSDCollectorPGPeeringDuration(t *testing.T) {
+	// The PG dumps are fed to updateInactivePGs directly rather than
+	// through the background loop.
+	conn := osdTestConn("", nil)
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	o := NewOSDCollector(e)
+	o.peeringPGTopN = 2
+
+	now := time.Date(2023, 3, 30, 12, 0, 0, 0, time.UTC)
+	for _, update := range []struct {
+		after   time.Duration
+		pgStats string
+	}{
+		{
+			after: 0,
+			pgStats: `
+				{"pgid": "1.0", "state": "peering"},
+				{"pgid": "1.1", "state": "peering"},
+				{"pgid": "1.2", "state": "remapped+peering"},
+				{"pgid": "1.3", "state": "active+clean"},
+				{"pgid": "1.4", "state": "down"}`,
+		},
+		{
+			after: 30 * time.Second,
+			pgStats: `
+				{"pgid": "1.0", "state": "peering"},
+				{"pgid": "1.1", "state": "peering"},
+				{"pgid": "1.2", "state": "remapped+peering"},
+				{"pgid": "1.3", "state": "active+clean"},
+				{"pgid": "1.4", "state": "down"},
+				{"pgid": "1.5", "state": "peering"}`,
+		},
+		{
+			after: 5 * time.Minute,
+			pgStats: `
+				{"pgid": "1.0", "state": "peering"},
+				{"pgid": "1.1", "state": "active+clean"},
+				{"pgid": "1.2", "state": "remapped+peering"},
+				{"pgid": "1.3", "state": "active+clean"},
+				{"pgid": "1.4", "state": "down"},
+				{"pgid": "1.5", "state": "peering"},
+				{"pgid": "1.6", "state": "peering"}`,
+		},
+	} {
+		pgDump := &cephPGDump{}
+		require.NoError(t, json.Unmarshal([]byte(`{"pg_stats": [`+update.pgStats+`]}`), pgDump))
+		o.updateInactivePGs(pgDump, now.Add(update.after))
+	}
+
+	server := serveOSDCollector(t, e, o)
+
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_pg_peering_duration_seconds{cluster="ceph",pgid="1.0"} 300\n`),
+		regexp.MustCompile(`ceph_pg_peering_duration_seconds{cluster="ceph",pgid="1.2"} 300\n`),
+		regexp.MustCompile(`ceph_pg_oldest_inactive{cluster="ceph"} 300\n`),
+	}, []*regexp.Regexp{
+		// active again
+		regexp.MustCompile(`ceph_pg_peering_duration_seconds{cluster="ceph",pgid="1.1"}`),
+		// beyond the top 2
+		regexp.MustCompile(`ceph_pg_peering_duration_seconds{cluster="ceph",pgid="1.5"}`),
+		// below the threshold
+		regexp.MustCompile(`ceph_pg_peering_duration_seconds{cluster="ceph",pgid="1.6"}`),
+		// not peering
+		regexp.MustCompile(`ceph_pg_peering_duration_seconds{cluster="ceph",pgid="1.4"}`),
+	})
+}
+
+func TestOSDCollectorLabelCacheAge(t *testing.T) {
+	var fail bool
+	conn := osdTestConn("", func(conn *MockConn) {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd tree",
+			"format": "json",
+		}))).Return([]byte(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}
+	],
+	"stray": []
+}`), "", func([]byte) error {
+			if fail {
+				return fmt.Errorf("timed out")
+			}
+			return nil
+		})
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	o := NewOSDCollector(e)
+
+	now := time.Unix(1700000000, 0)
+	o.now = func() time.Time { return now }
+
+	server := serveOSDCollector(t, e, o)
+
+	for _, tt := range []struct {
+		name    string
+		fail    bool
+		reMatch *regexp.Regexp
+	}{
+		{
+			name:    "fresh labels",
+			fail:    false,
+			reMatch: regexp.MustCompile(`ceph_exporter_osd_label_cache_age_seconds{cluster="ceph"} 0`),
+		},
+		{
+			name:    "failed refresh keeps the old labels",
+			fail:    true,
+			reMatch: regexp.MustCompile(`ceph_exporter_osd_label_cache_age_seconds{cluster="ceph"} 60`),
+		},
+		{
+			name:    "still failing",
+			fail:    true,
+			reMatch: regexp.MustCompile(`ceph_exporter_osd_label_cache_age_seconds{cluster="ceph"} 120`),
+		},
+		{
+			name:    "refresh resets the age",
+			fail:    false,
+			reMatch: regexp.MustCompile(`ceph_exporter_osd_label_cache_age_seconds{cluster="ceph"} 0`),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fail = tt.fail
+
+			requireScrape(t, server, []*regexp.Regexp{
+				tt.reMatch,
+				regexp.MustCompile(`ceph_host_osd_count{cluster="ceph",device_class="hdd",host="prod-data01-block01"} 1`),
+			}, nil)
+		})
+
+		now = now.Add(time.Minute)
+	}
+}
+
+func TestOSDCollectorMetadataCache(t *testing.T) {
+	var fail bool
+	conn := osdTestConn("", func(conn *MockConn) {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd metadata",
+			"format": "json",
+		}))).Return([]byte(`
+[
+	{"id": 0, "osd_objectstore": "bluestore", "ceph_version": "ceph version 16.2.11 (3cf40e2dca667f68c6ce3ff5cd94f01e711af894) pacific (stable)", "bluestore_bdev_type": "hdd", "bluefs_dedicated_db": "1", "bluefs_db_dev_node": "/dev/nvme0n1", "bluefs_dedicated_wal": "1", "bluefs_wal_dev_node": "/dev/nvme1n1"}
+]`), "", func([]byte) error {
+			if fail {
+				return fmt.Errorf("timed out")
+			}
+			return nil
+		})
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	re := regexp.MustCompile(`ceph_osd_metadata{bluestore_bdev_type="hdd",ceph_version="ceph version 16.2.11 \(3cf40e2dca667f68c6ce3ff5cd94f01e711af894\) pacific \(stable\)",ceph_version_when_created="",cluster="ceph",created_at="",db_device="/dev/nvme0n1",device_class="",objectstore="bluestore",osd="0",wal_device="/dev/nvme1n1"} 1`)
+
+	for _, tt := range []struct {
+		name string
+		fail bool
+	}{
+		{
+			name: "fresh metadata",
+			fail: false,
+		},
+		{
+			name: "failed refresh keeps the old metadata",
+			fail: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fail = tt.fail
+			requireScrape(t, server, []*regexp.Regexp{re}, nil)
+		})
+	}
+}
+
+func TestOSDCollectorScrubsCompleted(t *testing.T) {
+	var pgStats string
+	conn := osdTestConn("", func(conn *MockConn) {
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix":       "pg dump",
+			"dumpcontents": []interface{}{"pgs"},
+			"format":       "json",
+		}))).Return(func([][]byte) []byte {
+			return []byte(`{"pg_stats": [` + pgStats + `]}`)
+		}, "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	for _, tt := range []struct {
+		name     string
+		pgStats  string
+		expected string
+	}{
+		{
+			name: "scrubs started",
+			pgStats: `
+				{"pgid": "1.0", "state": "active+clean+scrubbing", "acting": [0, 1, 2], "acting_primary": 0},
+				{"pgid": "1.1", "state": "active+clean+scrubbing+deep", "acting": [1, 2, 0], "acting_primary": 1},
+				{"pgid": "2.0", "state": "active+clean+scrubbing", "acting": [2, 0, 1], "acting_primary": 2}`,
+			expected: "0",
+		},
+		{
+			name: "one scrub finished",
+			pgStats: `
+				{"pgid": "1.0", "state": "active+clean", "acting": [0, 1, 2], "acting_primary": 0},
+				{"pgid": "1.1", "state": "active+clean+scrubbing+deep", "acting": [1, 2, 0], "acting_primary": 1},
+				{"pgid": "2.0", "state": "active+clean+scrubbing", "acting": [2, 0, 1], "acting_primary": 2}`,
+			expected: "1",
+		},
+		{
+			name: "removed PGs are not counted",
+			pgStats: `
+				{"pgid": "1.0", "state": "active+clean", "acting": [0, 1, 2], "acting_primary": 0},
+				{"pgid": "1.1", "state": "active+clean", "acting": [1, 2, 0], "acting_primary": 1}`,
+			expected: "2",
+		},
+		{
+			name: "pruned PGs are not counted when they come back",
+			pgStats: `
+				{"pgid": "1.0", "state": "active+clean", "acting": [0, 1, 2], "acting_primary": 0},
+				{"pgid": "1.1", "state": "active+clean", "acting": [1, 2, 0], "acting_primary": 1},
+				{"pgid": "2.0", "state": "active+clean", "acting": [2, 0, 1], "acting_primary": 2}`,
+			expected: "2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pgStats = tt.pgStats
+
+			requireScrape(t, server, []*regexp.Regexp{
+				regexp.MustCompile(`ceph_scrubs_completed_total{cluster="ceph"} ` + tt.expected + `\n`),
+			}, nil)
+		})
+	}
+}
+
+func TestOSDCollectorDownRecovered(t *testing.T) {
+	var downNodes string
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [0]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd tree",
+			"states": []interface{}{"down"},
+			"format": "json",
+		}))).Return(func([]byte) []byte {
+			return []byte(`{"nodes": [` + downNodes + `], "stray": []}`)
+		}, "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	o := NewOSDCollector(e)
+
+	now := time.Unix(1700000000, 0)
+	o.now = func() time.Time { return now }
+
+	server := serveOSDCollector(t, e, o)
+
+	for _, tt := range []struct {
+		name      string
+		elapsed   time.Duration
+		downNodes string
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:      "no OSD down",
+			downNodes: ``,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{`),
+				regexp.MustCompile(`ceph_osd_down_reason{`),
+			},
+		},
+		{
+			name:      "OSD down",
+			downNodes: `{"id": 0, "name": "osd.0", "type": "osd", "type_id": 0, "exists": 1, "status": "down"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",status="down"} 1`),
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data01-block01",osd="osd.0"} 1`),
+			},
+		},
+		{
+			name:      "OSD back up",
+			downNodes: ``,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",status="down"} 0`),
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data01-block01",osd="osd.0"} 0`),
+			},
+		},
+		{
+			name:      "OSD destroyed",
+			elapsed:   10 * time.Minute,
+			downNodes: `{"id": 0, "name": "osd.0", "type": "osd", "type_id": 0, "exists": 1, "status": "destroyed"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",status="down"} 0`),
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",status="destroyed"} 1`),
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data01-block01",osd="osd.0"} 1`),
+			},
+		},
+		{
+			name:      "OSD back up again",
+			elapsed:   10 * time.Minute,
+			downNodes: ``,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default",status="destroyed"} 0`),
+				regexp.MustCompile(`ceph_osd_down_reason{cluster="ceph",host="prod-data01-block01",osd="osd.0"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				// Back up for longer than osdDownRecoveredRetention since
+				// it was last down as opposed to destroyed.
+				regexp.MustCompile(`ceph_osd_down{.*status="down"}`),
+			},
+		},
+		{
+			name:      "OSD back up past the retention",
+			elapsed:   10 * time.Minute,
+			downNodes: ``,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_down{`),
+				regexp.MustCompile(`ceph_osd_down_reason{`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.elapsed)
+			downNodes = tt.downNodes
+			requireScrape(t, server, tt.reMatch, tt.reUnmatch)
+		})
+	}
+}
+
+func TestOSDCollectorStateChanges(t *testing.T) {
+	var osds string
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [0, 1]},
+		{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 0.5}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd dump",
+			"format": "json",
+		}))).Return(func([]byte) []byte {
+			return []byte(`{"osds": [` + osds + `], "full_ratio": 0.95, "backfillfull_ratio": 0.9, "nearfull_ratio": 0.85}`)
+		}, "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	for _, tt := range []struct {
+		name      string
+		osds      string
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name: "first scrape",
+			osds: `{"osd": 0, "up": 1, "in": 1, "primary_affinity": 1, "state": ["exists", "up"]},
+				{"osd": 1, "up": 1, "in": 1, "primary_affinity": 0.5, "state": ["exists", "up"]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_primary_affinity{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1`),
+				regexp.MustCompile(`ceph_osd_primary_affinity{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"} 0.5`),
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.0"} 0`),
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.1"} 0`),
+			},
+		},
+		{
+			name: "OSD down",
+			osds: `{"osd": 0, "up": 0, "in": 1, "primary_affinity": 1, "state": ["exists"]},
+				{"osd": 1, "up": 1, "in": 1, "primary_affinity": 0.5, "state": ["exists", "up"]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.0"} 1`),
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.1"} 0`),
+			},
+		},
+		{
+			name: "OSD down and out",
+			osds: `{"osd": 0, "up": 0, "in": 0, "primary_affinity": 1, "state": ["exists"]},
+				{"osd": 1, "up": 1, "in": 1, "primary_affinity": 0.5, "state": ["exists", "up"]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.0"} 2`),
+			},
+		},
+		{
+			name: "OSD back up and in",
+			osds: `{"osd": 0, "up": 1, "in": 1, "primary_affinity": 1, "state": ["exists", "up"]},
+				{"osd": 1, "up": 1, "in": 1, "primary_affinity": 0.5, "state": ["exists", "up"]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.0"} 4`),
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.1"} 0`),
+			},
+		},
+		{
+			name: "OSD removed",
+			osds: `{"osd": 0, "up": 1, "in": 1, "primary_affinity": 1, "state": ["exists", "up"]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.0"} 4`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_state_changes_total{cluster="ceph",osd="osd.1"}`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			osds = tt.osds
+			requireScrape(t, server, tt.reMatch, tt.reUnmatch)
+		})
+	}
+}
+
+func TestOSDCollectorRemovedOSD(t *testing.T) {
+	var osdNodes, downNodes, pgStats string
+	conn := osdTestConn("", func(conn *MockConn) {
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd tree",
+			"format": "json",
+		}))).Return(func([]byte) []byte {
+			return []byte(`{"nodes": [` + osdNodes + `], "stray": []}`)
+		}, "", nil)
+
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd tree",
+			"states": []interface{}{"down"},
+			"format": "json",
+		}))).Return(func([]byte) []byte {
+			return []byte(`{"nodes": [` + downNodes + `], "stray": []}`)
+		}, "", nil)
+
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix":       "pg dump",
+			"dumpcontents": []interface{}{"pgs"},
+			"format":       "json",
+		}))).Return(func([][]byte) []byte {
+			return []byte(`{"pg_stats": [` + pgStats + `]}`)
+		}, "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	osd1 := []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_scrub_state{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default"}`),
+		regexp.MustCompile(`ceph_osd_down{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.1",rack="",root="default",status="down"}`),
+	}
+
+	for _, tt := range []struct {
+		name      string
+		osdNodes  string
+		downNodes string
+		pgStats   string
+		present   bool
+	}{
+		{
+			name: "OSD down while scrubbing",
+			osdNodes: `
+				{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+				{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [0, 1]},
+				{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+				{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "down", "reweight": 0, "primary_affinity": 1}`,
+			downNodes: `{"id": 1, "name": "osd.1", "type": "osd", "type_id": 0, "exists": 1, "status": "down"}`,
+			pgStats:   `{"pgid": "1.0", "state": "active+clean+scrubbing", "acting": [0, 1], "acting_primary": 0}`,
+			present:   true,
+		},
+		{
+			name: "OSD removed",
+			osdNodes: `
+				{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+				{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [0]},
+				{"id": 0, "device_class": "hdd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}`,
+			downNodes: ``,
+			pgStats:   `{"pgid": "1.0", "state": "active+clean", "acting": [0], "acting_primary": 0}`,
+			present:   false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			osdNodes, downNodes, pgStats = tt.osdNodes, tt.downNodes, tt.pgStats
+
+			reMatch := []*regexp.Regexp{
+				regexp.MustCompile(`ceph_osd_scrub_state{cluster="ceph",device_class="hdd",host="prod-data01-block01",osd="osd.0",rack="",root="default"}`),
+			}
+			var reUnmatch []*regexp.Regexp
+			if tt.present {
+				reMatch = append(reMatch, osd1...)
+			} else {
+				reUnmatch = osd1
+			}
+
+			requireScrape(t, server, reMatch, reUnmatch)
+		})
+	}
+}
+
+func TestUpdateInactivePGsRemovedPG(t *testing.T) {
+	// The PG dumps are fed to updateInactivePGs directly rather than
+	// through the background loop.
+	conn := osdTestConn("", nil)
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	o := NewOSDCollector(e)
+
+	now := time.Date(2023, 3, 30, 12, 0, 0, 0, time.UTC)
+	for _, update := range []struct {
+		after   time.Duration
+		pgStats string
+	}{
+		{after: 0, pgStats: `{"pgid": "2.0", "state": "down"}`},
+		// the pool was deleted
+		{after: time.Minute, pgStats: ``},
+		// and one with the same id created again
+		{after: 2 * time.Minute, pgStats: `{"pgid": "2.0", "state": "creating"}`},
+	} {
+		pgDump := &cephPGDump{}
+		require.NoError(t, json.Unmarshal([]byte(`{"pg_stats": [`+update.pgStats+`]}`), pgDump))
+		o.updateInactivePGs(pgDump, now.Add(update.after))
+	}
+
+	require.Equal(t, map[string]time.Time{"2.0": now.Add(2 * time.Minute)}, o.oldestInactivePGMap)
+}
+
+func TestOSDCollectorBackgroundStops(t *testing.T) {
+	conn := osdTestConn("", nil)
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	o := NewOSDCollector(e)
+
+	// Creating the collector doesn't start the background PG dumps.
+	conn.AssertNotCalled(t, "MgrCommand", mock.Anything)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		o.collectBackground(conn, stop)
+		close(done)
+	}()
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background collection didn't stop")
+	}
+}
+
+func TestOSDCollectorDeviceClassAllowlist(t *testing.T) {
+	conn := osdTestConn(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "type_id": 10, "children": [-2]},
+		{"id": -2, "name": "prod-data01-block01", "type": "host", "type_id": 1, "children": [1, 0]},
+		{"id": 0, "device_class": "ssd", "name": "osd.0", "type": "osd", "type_id": 0, "crush_weight": 1.75, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1},
+		{"id": 1, "device_class": "hdd", "name": "osd.1", "type": "osd", "type_id": 0, "crush_weight": 7.28, "depth": 2, "exists": 1, "status": "up", "reweight": 1, "primary_affinity": 1}
+	],
+	"stray": []
+}`, func(conn *MockConn) {
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix": "osd df",
+			"format": "json",
+		}))).Return([]byte(`
+{
+	"nodes": [
+		{"id": 0, "name": "osd.0", "type": "osd", "crush_weight": 1.75, "depth": 2, "reweight": 1, "kb": 1875000000, "kb_used": 375000000, "kb_avail": 1500000000, "utilization": 20, "var": 1, "pgs": 120},
+		{"id": 1, "name": "osd.1", "type": "osd", "crush_weight": 7.28, "depth": 2, "reweight": 1, "kb": 7810000000, "kb_used": 1562000000, "kb_avail": 6248000000, "utilization": 20, "var": 1, "pgs": 240}
+	],
+	"stray": [],
+	"summary": {"total_kb": 9685000000, "total_kb_used": 1937000000, "total_kb_avail": 7748000000, "average_utilization": 20}
+}`), "", nil)
+
+		conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+			"prefix": "osd perf",
+			"format": "json",
+		}))).Return([]byte(`
+{
+	"osdstats": {
+		"osd_perf_infos": [
+			{"id": 1, "perf_stats": {"commit_latency_ms": 12, "apply_latency_ms": 12}},
+			{"id": 0, "perf_stats": {"commit_latency_ms": 1, "apply_latency_ms": 1}}
+		]
+	}
+}`), "", nil)
+
+		conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+			"prefix": "osd dump",
+			"format": "json",
+		}))).Return([]byte(`
+{
+	"osds": [
+		{"osd": 0, "up": 1, "in": 1, "state": ["exists", "up"]},
+		{"osd": 1, "up": 1, "in": 1, "state": ["exists", "up"]}
+	],
+	"full_ratio": 0.95,
+	"backfillfull_ratio": 0.9,
+	"nearfull_ratio": 0.85
+}`), "", nil)
+	})
+
+	e := newExporter(conn, "ceph", ExporterOptions{OSDDeviceClassAllowlist: []string{"ssd"}}, logrus.New())
+	server := serveOSDCollector(t, e, NewOSDCollector(e))
+
+	requireScrape(t, server, []*regexp.Regexp{
+		regexp.MustCompile(`ceph_osd_bytes{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1.92e\+12`),
+		regexp.MustCompile(`ceph_osd_perf_commit_latency_seconds{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 0.001`),
+		regexp.MustCompile(`ceph_osd_up{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.0",rack="",root="default"} 1`),
+		regexp.MustCompile(`ceph_osd_total_bytes{cluster="ceph"} 9.91744e\+12`),
+	}, []*regexp.Regexp{
+		regexp.MustCompile(`device_class="hdd"`),
+	})
+}
@@ -0,0 +1,104 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scrapePGDump fetches `ceph pg dump pgs` at most once per scrape and shares
+// it between the collectors that need it, as the dump grows with the number
+// of PGs and is one of the most expensive commands sent to the mgr.
+type scrapePGDump struct {
+	conn   Conn
+	logger *logrus.Logger
+
+	mu      sync.Mutex
+	fetched bool
+	dump    *cephPGDump
+	err     error
+}
+
+func newScrapePGDump(conn Conn, logger *logrus.Logger) *scrapePGDump {
+	return &scrapePGDump{
+		conn:   conn,
+		logger: logger,
+	}
+}
+
+// reset makes the next get fetch a new dump, it is called at the start of
+// each scrape.
+func (d *scrapePGDump) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.fetched = false
+	d.dump = nil
+	d.err = nil
+}
+
+// get returns the dump of the current scrape, fetching it on first use. A
+// failed fetch is returned to every caller and only retried the next scrape.
+func (d *scrapePGDump) get() (*cephPGDump, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.fetched {
+		d.dump, d.err = d.fetch()
+		d.fetched = true
+	}
+
+	return d.dump, d.err
+}
+
+func (d *scrapePGDump) fetch() (*cephPGDump, error) {
+	args, err := d.command()
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := d.conn.MgrCommand(args)
+	if err != nil {
+		d.logger.WithError(err).WithField(
+			"args", string(bytes.Join(args, []byte(","))),
+		).Error("error executing mgr command")
+
+		return nil, err
+	}
+
+	pgDump := &cephPGDump{}
+	if err := json.Unmarshal(buf, pgDump); err != nil {
+		return nil, err
+	}
+
+	return pgDump, nil
+}
+
+func (d *scrapePGDump) command() ([][]byte, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix":       "pg dump",
+		"dumpcontents": []string{"pgs"},
+		"format":       jsonFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{cmd}, nil
+}
@@ -0,0 +1,65 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapePGDump(t *testing.T) {
+	var fail bool
+	conn := &MockConn{}
+	conn.On("MgrCommand", mock.MatchedBy(isMgrCommand(map[string]interface{}{
+		"prefix":       "pg dump",
+		"dumpcontents": []interface{}{"pgs"},
+		"format":       "json",
+	}))).Return([]byte(`{"pg_stats": [{"pgid": "1.0", "state": "active+clean", "last_scrub_stamp": "2023-03-29T20:25:57.000000+0000"}]}`), "", func([][]byte) error {
+		if fail {
+			return errors.New("timed out")
+		}
+		return nil
+	})
+
+	d := newScrapePGDump(conn, logrus.New())
+
+	// Every collector of a scrape gets the same dump.
+	for i := 0; i < 2; i++ {
+		pgDump, err := d.get()
+		require.NoError(t, err)
+		require.Len(t, pgDump.PGStats, 1)
+		require.Equal(t, "2023-03-29T20:25:57.000000+0000", pgDump.PGStats[0].LastScrubStamp)
+	}
+	conn.AssertNumberOfCalls(t, "MgrCommand", 1)
+
+	// A failed dump isn't retried until the next scrape.
+	d.reset()
+	fail = true
+	for i := 0; i < 2; i++ {
+		_, err := d.get()
+		require.Error(t, err)
+	}
+	conn.AssertNumberOfCalls(t, "MgrCommand", 2)
+
+	d.reset()
+	fail = false
+	_, err := d.get()
+	require.NoError(t, err)
+	conn.AssertNumberOfCalls(t, "MgrCommand", 3)
+}
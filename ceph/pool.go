@@ -47,6 +47,10 @@ type PoolInfoCollector struct {
 	// that need to be present for active I/O.
 	MinSize *prometheus.GaugeVec
 
+	// MinSizeRisk flags pools whose min_size lets them accept writes with
+	// no redundancy left, i.e. writes that can't survive another failure.
+	MinSizeRisk *prometheus.GaugeVec
+
 	// ActualSize shows total copies or chunks of an object that need to be
 	// present for a healthy cluster.
 	ActualSize *prometheus.GaugeVec
@@ -62,6 +66,40 @@ type PoolInfoCollector struct {
 
 	// ExpansionFactor Contains a float >= 1 that defines the EC or replication multiplier of a pool
 	ExpansionFactor *prometheus.GaugeVec
+
+	// ExpectedNumObjects is the no. of objects a pool was created to expect,
+	// which pre-splits its PG directories for that load.
+	ExpectedNumObjects *prometheus.GaugeVec
+
+	// CrushRule maps each pool to the id of the CRUSH rule it uses, so pools
+	// can be joined with the CRUSH rule they place data with.
+	CrushRule *prometheus.GaugeVec
+
+	// ECProfile describes each erasure code profile used by a pool, so the
+	// EC configuration can be audited from metrics.
+	ECProfile *prometheus.GaugeVec
+
+	// Info carries the replication settings of each pool as labels, i.e.
+	// size, min_size, CRUSH rule name and, for EC pools, the erasure code
+	// profile and its k and m.
+	Info *prometheus.GaugeVec
+
+	// TargetSizeRatio shows the share of the cluster's capacity a pool is
+	// expected to consume, as used by the PG autoscaler.
+	TargetSizeRatio *prometheus.GaugeVec
+
+	// ReadBalanceScore shows how evenly the primaries of a replicated pool
+	// are spread over its OSDs, as computed by the read balancer since Reef.
+	// 1 is optimal, higher values mean some OSDs serve more of the reads.
+	ReadBalanceScore *prometheus.GaugeVec
+
+	// TargetSizeRatioTotal sums the target_size_ratio of all pools, above 1
+	// the pools are overcommitted (POOL_TARGET_SIZE_RATIO_OVERCOMMITTED).
+	TargetSizeRatioTotal prometheus.Gauge
+
+	// PoolsPendingPGChange counts the pools whose pg_num hasn't reached
+	// pg_num_target yet, i.e. that are about to split or merge PGs.
+	PoolsPendingPGChange prometheus.Gauge
 }
 
 // NewPoolInfoCollector displays information about each pool in the cluster.
@@ -108,6 +146,16 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 			},
 			poolLabels,
 		),
+		MinSizeRisk: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   subSystem,
+				Name:        "min_size_risk",
+				Help:        "Whether a pool's min_size allows I/O with no redundancy left (replicated min_size 1, erasure coded min_size <= k)",
+				ConstLabels: labels,
+			},
+			poolLabels,
+		),
 		ActualSize: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   cephNamespace,
@@ -158,6 +206,81 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 			},
 			poolLabels,
 		),
+		ExpectedNumObjects: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   subSystem,
+				Name:        "expected_num_objects",
+				Help:        "Expected no. of objects the pool was pre-split for at creation",
+				ConstLabels: labels,
+			},
+			poolLabels,
+		),
+		CrushRule: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   subSystem,
+				Name:        "crush_rule",
+				Help:        "CRUSH rule used by a pool, the value is always 1",
+				ConstLabels: labels,
+			},
+			[]string{"pool", "rule_id"},
+		),
+		ECProfile: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "ec_profile",
+				Help:        "Erasure code profile used by a pool, the value is always 1",
+				ConstLabels: labels,
+			},
+			[]string{"name", "k", "m", "plugin", "technique"},
+		),
+		Info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   subSystem,
+				Name:        "info",
+				Help:        "Replication settings of a pool, the value is always 1",
+				ConstLabels: labels,
+			},
+			[]string{"pool", "size", "min_size", "crush_rule", "erasure_profile", "k", "m"},
+		),
+		TargetSizeRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   subSystem,
+				Name:        "target_size_ratio",
+				Help:        "Share of the cluster's capacity a pool is expected to consume, 0 if unset",
+				ConstLabels: labels,
+			},
+			poolLabels,
+		),
+		ReadBalanceScore: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   subSystem,
+				Name:        "read_balance_score",
+				Help:        "Read balance score of a replicated pool's primaries, 1 is optimal",
+				ConstLabels: labels,
+			},
+			poolLabels,
+		),
+		TargetSizeRatioTotal: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "cluster_target_size_ratio_total",
+				Help:        "Sum of the target_size_ratio of all pools, the pools are overcommitted above 1",
+				ConstLabels: labels,
+			},
+		),
+		PoolsPendingPGChange: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Name:        "pools_pending_pg_change",
+				Help:        "Number of pools whose pg_num differs from pg_num_target, i.e. with PG splits or merges pending",
+				ConstLabels: labels,
+			},
+		),
 	}
 }
 
@@ -166,26 +289,43 @@ func (p *PoolInfoCollector) collectorList() []prometheus.Collector {
 		p.PGNum,
 		p.PlacementPGNum,
 		p.MinSize,
+		p.MinSizeRisk,
 		p.ActualSize,
 		p.QuotaMaxBytes,
 		p.QuotaMaxObjects,
 		p.StripeWidth,
 		p.ExpansionFactor,
+		p.ExpectedNumObjects,
+		p.CrushRule,
+		p.ECProfile,
+		p.Info,
+		p.TargetSizeRatio,
+		p.ReadBalanceScore,
+		p.TargetSizeRatioTotal,
+		p.PoolsPendingPGChange,
 	}
 }
 
 type poolInfo struct {
-	Name            string  `json:"pool_name"`
-	ActualSize      float64 `json:"size"`
-	MinSize         float64 `json:"min_size"`
-	PGNum           float64 `json:"pg_num"`
-	PlacementPGNum  float64 `json:"pg_placement_num"`
-	QuotaMaxBytes   float64 `json:"quota_max_bytes"`
-	QuotaMaxObjects float64 `json:"quota_max_objects"`
-	Profile         string  `json:"erasure_code_profile"`
-	Type            int64   `json:"type"`
-	StripeWidth     float64 `json:"stripe_width"`
-	CrushRule       int64   `json:"crush_rule"`
+	Name            string   `json:"pool_name"`
+	ActualSize      float64  `json:"size"`
+	MinSize         float64  `json:"min_size"`
+	PGNum           float64  `json:"pg_num"`
+	PGNumTarget     *float64 `json:"pg_num_target"`
+	PlacementPGNum  float64  `json:"pg_placement_num"`
+	QuotaMaxBytes   float64  `json:"quota_max_bytes"`
+	QuotaMaxObjects float64  `json:"quota_max_objects"`
+	Profile         string   `json:"erasure_code_profile"`
+	Type            int64    `json:"type"`
+	StripeWidth     float64  `json:"stripe_width"`
+	CrushRule       int64    `json:"crush_rule"`
+	ExpectedObjects float64  `json:"expected_num_objects"`
+	Options         struct {
+		TargetSizeRatio float64 `json:"target_size_ratio"`
+	} `json:"options"`
+	ReadBalance *struct {
+		ScoreActing float64 `json:"score_acting"`
+	} `json:"read_balance"`
 }
 
 type cephPoolInfo struct {
@@ -195,7 +335,7 @@ type cephPoolInfo struct {
 func (p *PoolInfoCollector) collect() error {
 	var buf []byte
 	var err error
-	var ruleToRootMappings map[int64]string
+	var ruleToRootMappings, ruleNames map[int64]string
 	wg := &sync.WaitGroup{}
 
 	wg.Add(1)
@@ -214,7 +354,7 @@ func (p *PoolInfoCollector) collect() error {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		ruleToRootMappings = p.getCrushRuleToRootMappings()
+		ruleToRootMappings, ruleNames = p.getCrushRuleMappings()
 	}()
 
 	wg.Wait()
@@ -231,12 +371,25 @@ func (p *PoolInfoCollector) collect() error {
 	p.PGNum.Reset()
 	p.PlacementPGNum.Reset()
 	p.MinSize.Reset()
+	p.MinSizeRisk.Reset()
 	p.ActualSize.Reset()
 	p.QuotaMaxBytes.Reset()
 	p.QuotaMaxObjects.Reset()
 	p.StripeWidth.Reset()
 	p.ExpansionFactor.Reset()
-
+	p.ExpectedNumObjects.Reset()
+	p.CrushRule.Reset()
+	p.ECProfile.Reset()
+	p.Info.Reset()
+	p.TargetSizeRatio.Reset()
+	p.ReadBalanceScore.Reset()
+
+	// profiles caches the erasure code profiles looked up in this collection,
+	// several pools often share one.
+	profiles := make(map[string]*ecProfile)
+
+	targetSizeRatioTotal := 0.0
+	pendingPGChange := 0.0
 	for _, pool := range stats.Pools {
 		if pool.Type == poolReplicated {
 			pool.Profile = "replicated"
@@ -249,12 +402,63 @@ func (p *PoolInfoCollector) collect() error {
 		p.QuotaMaxBytes.WithLabelValues(labelValues...).Set(pool.QuotaMaxBytes)
 		p.QuotaMaxObjects.WithLabelValues(labelValues...).Set(pool.QuotaMaxObjects)
 		p.StripeWidth.WithLabelValues(labelValues...).Set(pool.StripeWidth)
-		p.ExpansionFactor.WithLabelValues(labelValues...).Set(p.getExpansionFactor(pool))
+		p.ExpansionFactor.WithLabelValues(labelValues...).Set(p.getExpansionFactor(pool, profiles))
+		p.ExpectedNumObjects.WithLabelValues(labelValues...).Set(pool.ExpectedObjects)
+		p.MinSizeRisk.WithLabelValues(labelValues...).Set(minSizeRisk(pool, profiles))
+		p.CrushRule.WithLabelValues(pool.Name, strconv.FormatInt(pool.CrushRule, 10)).Set(1)
+		p.Info.WithLabelValues(p.infoLabelValues(pool, ruleNames, profiles)...).Set(1)
+		p.TargetSizeRatio.WithLabelValues(labelValues...).Set(pool.Options.TargetSizeRatio)
+		targetSizeRatioTotal += pool.Options.TargetSizeRatio
+
+		// read_balance is only reported for replicated pools since Reef.
+		if pool.ReadBalance != nil {
+			p.ReadBalanceScore.WithLabelValues(labelValues...).Set(pool.ReadBalance.ScoreActing)
+		}
+
+		// pg_num_target is only reported since Nautilus, where pg_num
+		// is stepped towards it by the mgr.
+		if pool.PGNumTarget != nil && *pool.PGNumTarget != pool.PGNum {
+			pendingPGChange++
+		}
+	}
+	p.TargetSizeRatioTotal.Set(targetSizeRatioTotal)
+	p.PoolsPendingPGChange.Set(pendingPGChange)
+
+	for name, profile := range profiles {
+		p.ECProfile.WithLabelValues(name, profile.K, profile.M, profile.Plugin, profile.Technique).Set(1)
 	}
 
 	return nil
 }
 
+// infoLabelValues returns the label values of Info for the pool. Rules
+// missing from the CRUSH rule dump are named by their id, and the k and m of
+// an EC pool are left empty if its profile can't be looked up.
+func (p *PoolInfoCollector) infoLabelValues(pool poolInfo, ruleNames map[int64]string, profiles map[string]*ecProfile) []string {
+	rule, ok := ruleNames[pool.CrushRule]
+	if !ok {
+		rule = strconv.FormatInt(pool.CrushRule, 10)
+	}
+
+	var profileName, k, m string
+	if pool.Type == poolErasure {
+		profileName = pool.Profile
+		if profile, err := p.getECProfile(pool.Profile, profiles); err == nil {
+			k, m = profile.K, profile.M
+		}
+	}
+
+	return []string{
+		pool.Name,
+		strconv.FormatFloat(pool.ActualSize, 'f', -1, 64),
+		strconv.FormatFloat(pool.MinSize, 'f', -1, 64),
+		rule,
+		profileName,
+		k,
+		m,
+	}
+}
+
 func (p *PoolInfoCollector) cephInfoCommand() []byte {
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "osd pool ls",
@@ -277,69 +481,109 @@ func (p *PoolInfoCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect extracts the current values of all the metrics and sends them to the
 // prometheus channel.
-func (p *PoolInfoCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (p *PoolInfoCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
 	p.logger.Debug("collecting pool metrics")
 	if err := p.collect(); err != nil {
 		p.logger.WithError(err).Error("error collecting pool metrics")
-		return
+		return err
 	}
 
 	for _, metric := range p.collectorList() {
 		metric.Collect(ch)
 	}
+
+	return nil
 }
 
-func (p *PoolInfoCollector) getExpansionFactor(pool poolInfo) float64 {
-	ef, err := p.getECExpansionFactor(pool)
-	if err == nil {
-		return ef
-	} else {
+func (p *PoolInfoCollector) getExpansionFactor(pool poolInfo, profiles map[string]*ecProfile) float64 {
+	// Replicated pools have no erasure code profile to look up, and asking
+	// for one would only fail.
+	if pool.Type == poolReplicated {
+		return pool.ActualSize
+	}
+
+	profile, err := p.getECProfile(pool.Profile, profiles)
+	if err != nil {
 		// Non-EC pool (or unable to get profile info); assume that it's replicated.
 		logrus.WithError(err).Debug("failed to get ec expansion factor")
 		return pool.ActualSize
 	}
+
+	return profile.expansionFactor()
+}
+
+// minSizeRisk returns 1 if the pool keeps serving I/O with no redundancy
+// left: an erasure coded pool with min_size <= k (size - m), or a replicated
+// one with min_size 1. It relies on getExpansionFactor having looked up the
+// pool's erasure code profile.
+func minSizeRisk(pool poolInfo, profiles map[string]*ecProfile) float64 {
+	minSafe := 2.0
+	if profile, ok := profiles[pool.Profile]; ok && pool.Type != poolReplicated {
+		m, _ := strconv.ParseFloat(profile.M, 64)
+		minSafe = pool.ActualSize - m + 1
+	}
+
+	if pool.MinSize < minSafe {
+		return 1
+	}
+
+	return 0
 }
 
-func (p *PoolInfoCollector) getECExpansionFactor(pool poolInfo) (float64, error) {
+type ecProfile struct {
+	K         string `json:"k"`
+	M         string `json:"m"`
+	Plugin    string `json:"plugin"`
+	Technique string `json:"technique"`
+}
+
+func (e *ecProfile) expansionFactor() float64 {
+	k, _ := strconv.ParseFloat(e.K, 64)
+	m, _ := strconv.ParseFloat(e.M, 64)
+
+	expansionFactor := (k + m) / k
+	return math.Round(expansionFactor*100) / 100
+}
+
+// getECProfile looks up the named erasure code profile, unless it's already
+// in profiles, and adds it there.
+func (p *PoolInfoCollector) getECProfile(name string, profiles map[string]*ecProfile) (*ecProfile, error) {
+	if profile, ok := profiles[name]; ok {
+		return profile, nil
+	}
+
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "osd erasure-code-profile get",
-		"name":   pool.Profile,
+		"name":   name,
 		"format": "json",
 	})
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
 	buf, _, err := p.conn.MonCommand(cmd)
 	if err != nil {
-		return -1, err
-	}
-
-	type ecInfo struct {
-		K string `json:"k"`
-		M string `json:"m"`
+		return nil, err
 	}
 
-	ecStats := ecInfo{}
-	err = json.Unmarshal(buf, &ecStats)
-	if err != nil {
-		return -1, err
+	profile := &ecProfile{}
+	if err := json.Unmarshal(buf, profile); err != nil {
+		return nil, err
 	}
 
-	if ecStats.K == "" || ecStats.M == "" {
-		return -1, errors.New("missing stats")
+	if profile.K == "" || profile.M == "" {
+		return nil, errors.New("missing stats")
 	}
 
-	k, _ := strconv.ParseFloat(ecStats.K, 64)
-	m, _ := strconv.ParseFloat(ecStats.M, 64)
-
-	expansionFactor := (k + m) / k
-	roundedExpansion := math.Round(expansionFactor*100) / 100
-	return roundedExpansion, nil
+	profiles[name] = profile
+	return profile, nil
 }
 
-func (p *PoolInfoCollector) getCrushRuleToRootMappings() map[int64]string {
+// getCrushRuleMappings maps the CRUSH rule ids to the root each rule takes
+// and to the rule names.
+func (p *PoolInfoCollector) getCrushRuleMappings() (map[int64]string, map[int64]string) {
 	mappings := make(map[int64]string)
+	names := make(map[int64]string)
 
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "osd crush rule dump",
@@ -355,12 +599,13 @@ func (p *PoolInfoCollector) getCrushRuleToRootMappings() map[int64]string {
 			"args", string(cmd),
 		).Error("error executing mon command")
 
-		return mappings
+		return mappings, names
 	}
 
 	var rules []struct {
-		RuleID int64 `json:"rule_id"`
-		Steps  []struct {
+		RuleID   int64  `json:"rule_id"`
+		RuleName string `json:"rule_name"`
+		Steps    []struct {
 			ItemName string `json:"item_name"`
 			Op       string `json:"op"`
 		} `json:"steps"`
@@ -370,10 +615,11 @@ func (p *PoolInfoCollector) getCrushRuleToRootMappings() map[int64]string {
 	if err != nil {
 		p.logger.WithError(err).Error("error unmarshalling crush rules")
 
-		return mappings
+		return mappings, names
 	}
 
 	for _, rule := range rules {
+		names[rule.RuleID] = rule.RuleName
 		if len(rule.Steps) == 0 {
 			continue
 		}
@@ -388,5 +634,5 @@ func (p *PoolInfoCollector) getCrushRuleToRootMappings() map[int64]string {
 		}
 	}
 
-	return mappings
+	return mappings, names
 }
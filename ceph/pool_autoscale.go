@@ -0,0 +1,153 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// PoolAutoscaleCollector reports the view of the mgr pg_autoscaler module on
+// each pool, so that the PG counts it recommends can be compared with the
+// actual ones over time. The actual pg_num is reported by PoolInfoCollector.
+type PoolAutoscaleCollector struct {
+	conn   Conn
+	logger *logrus.Logger
+
+	pgNumTargetDesc      *prometheus.Desc
+	pgNumRecommendedDesc *prometheus.Desc
+	targetRatioDesc      *prometheus.Desc
+	targetBytesDesc      *prometheus.Desc
+	modeDesc             *prometheus.Desc
+}
+
+// NewPoolAutoscaleCollector creates a new PoolAutoscaleCollector instance
+func NewPoolAutoscaleCollector(exporter *Exporter) *PoolAutoscaleCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+
+	return &PoolAutoscaleCollector{
+		conn:   exporter.Conn,
+		logger: exporter.Logger,
+
+		pgNumTargetDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_pg_num_target", cephNamespace),
+			"PG count the pg_num of a pool is being stepped towards",
+			[]string{"pool"},
+			labels,
+		),
+		pgNumRecommendedDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_autoscale_pg_num_recommended", cephNamespace),
+			"PG count the autoscaler recommends for a pool",
+			[]string{"pool"},
+			labels,
+		),
+		targetRatioDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_autoscale_target_ratio", cephNamespace),
+			"Target size ratio of a pool normalized over the pools of its CRUSH root, as used by the autoscaler",
+			[]string{"pool"},
+			labels,
+		),
+		targetBytesDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_autoscale_target_bytes", cephNamespace),
+			"Bytes a pool is expected to store as set by target_size_bytes, 0 if unset",
+			[]string{"pool"},
+			labels,
+		),
+		modeDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_autoscale_mode", cephNamespace),
+			"Autoscale mode of a pool, the value is always 1",
+			[]string{"pool", "mode"},
+			labels,
+		),
+	}
+}
+
+// cephPoolAutoscaleStatus is the output of `ceph osd pool autoscale-status`.
+// pg_num_final is what the autoscaler would set pg_num to, and
+// effective_target_ratio is the pool's target_size_ratio normalized over the
+// pools of its CRUSH root, which ceph_pool_target_size_ratio reports as set.
+type cephPoolAutoscaleStatus []struct {
+	PoolName             string  `json:"pool_name"`
+	Mode                 string  `json:"pg_autoscale_mode"`
+	PGNumTarget          float64 `json:"pg_num_target"`
+	PGNumFinal           float64 `json:"pg_num_final"`
+	TargetBytes          float64 `json:"target_bytes"`
+	EffectiveTargetRatio float64 `json:"effective_target_ratio"`
+}
+
+func (p *PoolAutoscaleCollector) getAutoscaleStatus() (cephPoolAutoscaleStatus, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd pool autoscale-status",
+		"format": "json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := p.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		p.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mgr command")
+
+		return nil, err
+	}
+
+	var status cephPoolAutoscaleStatus
+	if err := json.Unmarshal(buf, &status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// Describe provides the metrics descriptions to Prometheus
+func (p *PoolAutoscaleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.pgNumTargetDesc
+	ch <- p.pgNumRecommendedDesc
+	ch <- p.targetRatioDesc
+	ch <- p.targetBytesDesc
+	ch <- p.modeDesc
+}
+
+// minVersion is Octopus, from which on the pg_autoscaler module is always on.
+// On Nautilus it's disabled by default, and `osd pool autoscale-status` fails
+// without it.
+func (p *PoolAutoscaleCollector) minVersion() *Version {
+	return Octopus
+}
+
+// Collect sends all the collected metrics Prometheus.
+func (p *PoolAutoscaleCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	status, err := p.getAutoscaleStatus()
+	if err != nil {
+		p.logger.WithError(err).Error("failed to run 'ceph osd pool autoscale-status'")
+		return err
+	}
+
+	for _, pool := range status {
+		ch <- prometheus.MustNewConstMetric(p.pgNumTargetDesc, prometheus.GaugeValue, pool.PGNumTarget, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(p.pgNumRecommendedDesc, prometheus.GaugeValue, pool.PGNumFinal, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(p.targetRatioDesc, prometheus.GaugeValue, pool.EffectiveTargetRatio, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(p.targetBytesDesc, prometheus.GaugeValue, pool.TargetBytes, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(p.modeDesc, prometheus.GaugeValue, 1, pool.PoolName, pool.Mode)
+	}
+
+	return nil
+}
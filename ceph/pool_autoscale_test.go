@@ -0,0 +1,106 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolAutoscaleCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		version            string
+		input              string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:    "pools scaling up and off",
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			input: `
+[
+	{"pool_id": 1, "pool_name": "rbd", "pg_autoscale_mode": "on", "pg_num_target": 128, "target_bytes": 0, "target_ratio": 0.6, "effective_target_ratio": 0.75, "pg_num_ideal": 400, "pg_num_final": 512, "would_adjust": true},
+	{"pool_id": 2, "pool_name": "scratch", "pg_autoscale_mode": "off", "pg_num_target": 32, "target_bytes": 1099511627776, "target_ratio": 0.0, "effective_target_ratio": 0.0, "pg_num_ideal": 12, "pg_num_final": 16, "would_adjust": false}
+]`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_pg_num_target{cluster="ceph",pool="rbd"} 128`),
+				regexp.MustCompile(`ceph_pool_autoscale_pg_num_recommended{cluster="ceph",pool="rbd"} 512`),
+				regexp.MustCompile(`ceph_pool_autoscale_target_ratio{cluster="ceph",pool="rbd"} 0.75`),
+				regexp.MustCompile(`ceph_pool_autoscale_target_bytes{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_autoscale_mode{cluster="ceph",mode="on",pool="rbd"} 1`),
+				// the recommendation is reported even if the autoscaler is off
+				regexp.MustCompile(`ceph_pool_autoscale_pg_num_recommended{cluster="ceph",pool="scratch"} 16`),
+				regexp.MustCompile(`ceph_pool_autoscale_target_bytes{cluster="ceph",pool="scratch"} 1.099511627776e\+12`),
+				regexp.MustCompile(`ceph_pool_autoscale_mode{cluster="ceph",mode="off",pool="scratch"} 1`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_autoscale_mode{cluster="ceph",mode="off",pool="rbd"}`),
+			},
+		},
+		{
+			// the pg_autoscaler module may be disabled on nautilus
+			name:    "before octopus",
+			version: `{"version":"ceph version 14.2.22 (ca74598065096e6fcbd8433c8779a2be0c889351) nautilus (stable)"}`,
+			input:   `[{"pool_id": 1, "pool_name": "rbd", "pg_autoscale_mode": "on", "pg_num_target": 128, "pg_num_final": 512}]`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_collector_skipped{cluster="ceph",collector="poolAutoscale",reason="version"} 1`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_autoscale_`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(tt.version, "{}")
+			conn.On("MgrCommand", mock.Anything).Return(
+				[]byte(tt.input), "", nil,
+			)
+
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+			e.cc = map[string]versionedCollector{
+				"poolAutoscale": NewPoolAutoscaleCollector(e),
+			}
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(e))
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), "expected %s to match", re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), "expected %s not to match", re.String())
+			}
+		})
+	}
+}
@@ -47,17 +47,49 @@ func TestPoolInfoCollector(t *testing.T) {
 				regexp.MustCompile(`pool_quota_max_objects{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 2048`),
 				regexp.MustCompile(`pool_stripe_width{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 4096`),
 				regexp.MustCompile(`pool_expansion_factor{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 1.5`),
+				regexp.MustCompile(`pool_expected_num_objects{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 5e\+08`),
 
-				regexp.MustCompile(`pool_size{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 3`),
-				regexp.MustCompile(`pool_min_size{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 2`),
-				regexp.MustCompile(`pool_pg_num{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 16384`),
-				regexp.MustCompile(`pool_pgp_num{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 16384`),
-				regexp.MustCompile(`pool_quota_max_bytes{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 512`),
-				regexp.MustCompile(`pool_quota_max_objects{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 1024`),
-				regexp.MustCompile(`pool_stripe_width{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 4096`),
-				regexp.MustCompile(`pool_expansion_factor{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 3`),
+				regexp.MustCompile(`pool_size{cluster="ceph",pool="rbd",profile="replicated",root="default"} 3`),
+				regexp.MustCompile(`pool_min_size{cluster="ceph",pool="rbd",profile="replicated",root="default"} 2`),
+				regexp.MustCompile(`pool_pg_num{cluster="ceph",pool="rbd",profile="replicated",root="default"} 16384`),
+				regexp.MustCompile(`pool_pgp_num{cluster="ceph",pool="rbd",profile="replicated",root="default"} 16384`),
+				regexp.MustCompile(`pool_quota_max_bytes{cluster="ceph",pool="rbd",profile="replicated",root="default"} 512`),
+				regexp.MustCompile(`pool_quota_max_objects{cluster="ceph",pool="rbd",profile="replicated",root="default"} 1024`),
+				regexp.MustCompile(`pool_stripe_width{cluster="ceph",pool="rbd",profile="replicated",root="default"} 4096`),
+				regexp.MustCompile(`pool_expansion_factor{cluster="ceph",pool="rbd",profile="replicated",root="default"} 3`),
+				regexp.MustCompile(`pool_expected_num_objects{cluster="ceph",pool="rbd",profile="replicated",root="default"} 0`),
+
+				regexp.MustCompile(`pool_crush_rule{cluster="ceph",pool="rbd",rule_id="0"} 1`),
+				regexp.MustCompile(`pool_crush_rule{cluster="ceph",pool="rbd",rule_id="1"} 1`),
+				regexp.MustCompile(`pool_crush_rule{cluster="ceph",pool="cephfs_data",rule_id="1"} 1`),
+
+				regexp.MustCompile(`ceph_ec_profile{cluster="ceph",k="4",m="2",name="ec-4-2",plugin="jerasure",technique="reed_sol_van"} 1`),
+
+				regexp.MustCompile(`ceph_pool_info{cluster="ceph",crush_rule="another-rule",erasure_profile="ec-4-2",k="4",m="2",min_size="4",pool="rbd",size="6"} 1`),
+				regexp.MustCompile(`ceph_pool_info{cluster="ceph",crush_rule="replicated_rule",erasure_profile="",k="",m="",min_size="1",pool="scratch",size="2"} 1`),
+
+				// min_size == k for ec-4-2, and a 2/1 replicated pool
+				regexp.MustCompile(`pool_min_size_risk{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 1`),
+				regexp.MustCompile(`pool_min_size_risk{cluster="ceph",pool="rbd",profile="replicated",root="default"} 0`),
+				regexp.MustCompile(`pool_min_size_risk{cluster="ceph",pool="cephfs_data",profile="replicated",root="non-default-root"} 0`),
+				regexp.MustCompile(`pool_min_size_risk{cluster="ceph",pool="scratch",profile="replicated",root="default"} 1`),
+
+				// 0.7 + 0.5 overcommits the cluster
+				regexp.MustCompile(`pool_target_size_ratio{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 0.7`),
+				regexp.MustCompile(`pool_target_size_ratio{cluster="ceph",pool="scratch",profile="replicated",root="default"} 0`),
+				regexp.MustCompile(`ceph_cluster_target_size_ratio_total{cluster="ceph"} 1.2`),
+
+				// cephfs_data is splitting, scratch is merging
+				regexp.MustCompile(`ceph_pools_pending_pg_change{cluster="ceph"} 2`),
+
+				regexp.MustCompile(`pool_read_balance_score{cluster="ceph",pool="scratch",profile="replicated",root="default"} 1.25`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				// only reported where the read balancer scored the pool
+				regexp.MustCompile(`pool_read_balance_score{cluster="ceph",pool="cephfs_data"`),
+				regexp.MustCompile(`pool_crush_rule{cluster="ceph",pool="cephfs_data",rule_id="0"}`),
+				regexp.MustCompile(`ceph_ec_profile{[^}]*name="replicated-ruleset"`),
 			},
-			reUnmatch: []*regexp.Regexp{},
 		},
 	} {
 		func() {
@@ -75,8 +107,10 @@ func TestPoolInfoCollector(t *testing.T) {
 				})
 			})).Return([]byte(`
 [
-	{"pool_name": "rbd", "crush_rule": 1, "size": 6, "min_size": 4, "pg_num": 8192, "pg_placement_num": 8192, "quota_max_bytes": 1024, "quota_max_objects": 2048, "erasure_code_profile": "ec-4-2", "stripe_width": 4096},
-	{"pool_name": "rbd", "crush_rule": 0, "size": 3, "min_size": 2, "pg_num": 16384, "pg_placement_num": 16384, "quota_max_bytes": 512, "quota_max_objects": 1024, "erasure_code_profile": "replicated-ruleset", "stripe_width": 4096}
+	{"pool_name": "rbd", "type": 3, "crush_rule": 1, "size": 6, "min_size": 4, "pg_num": 8192, "pg_num_target": 8192, "pg_placement_num": 8192, "quota_max_bytes": 1024, "quota_max_objects": 2048, "erasure_code_profile": "ec-4-2", "stripe_width": 4096, "expected_num_objects": 500000000, "options": {"target_size_ratio": 0.7}},
+	{"pool_name": "rbd", "type": 1, "crush_rule": 0, "size": 3, "min_size": 2, "pg_num": 16384, "pg_num_target": 16384, "pg_placement_num": 16384, "quota_max_bytes": 512, "quota_max_objects": 1024, "erasure_code_profile": "replicated-ruleset", "stripe_width": 4096, "expected_num_objects": 0, "options": {"target_size_ratio": 0.5, "pg_num_min": 16}},
+	{"pool_name": "cephfs_data", "type": 1, "crush_rule": 1, "size": 3, "min_size": 2, "pg_num": 1024, "pg_num_target": 2048, "pg_placement_num": 1024, "quota_max_bytes": 0, "quota_max_objects": 0, "erasure_code_profile": "replicated-ruleset", "stripe_width": 0},
+	{"pool_name": "scratch", "type": 1, "crush_rule": 0, "size": 2, "min_size": 1, "pg_num": 32, "pg_num_target": 16, "pg_placement_num": 32, "quota_max_bytes": 0, "quota_max_objects": 0, "erasure_code_profile": "replicated-ruleset", "stripe_width": 0, "read_balance": {"score_acting": 1.25, "score_stable": 1.25, "optimal_score": 1, "raw_score_acting": 1.25, "raw_score_stable": 1.25}}
 ]`,
 			), "", nil)
 
@@ -183,7 +217,7 @@ func TestPoolInfoCollector(t *testing.T) {
 				})
 			})).Return([]byte(""), "", fmt.Errorf("unknown erasure code profile"))
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"poolInfo": NewPoolInfoCollector(e),
 			}
@@ -210,3 +244,15 @@ func TestPoolInfoCollector(t *testing.T) {
 		}()
 	}
 }
+
+func TestPoolExpansionFactorReplicated(t *testing.T) {
+	conn := &MockConn{}
+	p := &PoolInfoCollector{conn: conn, logger: logrus.New()}
+
+	profiles := map[string]*ecProfile{}
+	pool := poolInfo{Name: "rbd", Type: poolReplicated, ActualSize: 3, Profile: "replicated-ruleset"}
+	require.Equal(t, 3.0, p.getExpansionFactor(pool, profiles))
+	require.Empty(t, profiles)
+
+	conn.AssertNotCalled(t, "MonCommand", mock.Anything)
+}
@@ -15,9 +15,12 @@
 package ceph
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -28,6 +31,9 @@ type PoolUsageCollector struct {
 	conn   Conn
 	logger *logrus.Logger
 
+	// pgDump is the PG dump of the scrape, shared with OSDCollector.
+	pgDump *scrapePGDump
+
 	// UsedBytes tracks the amount of bytes currently allocated for the pool. This
 	// does not factor in the overcommitment made for individual images.
 	UsedBytes *prometheus.Desc
@@ -65,8 +71,59 @@ type PoolUsageCollector struct {
 
 	// WriteBytes tracks the write throughput made for the images within each pool.
 	WriteBytes *prometheus.Desc
+
+	// DeepScrubErrors tracks the no. of errors found by deep scrubs within each
+	// pool. These are usually checksum mismatches and point at data corruption.
+	DeepScrubErrors *prometheus.Desc
+
+	// ShallowScrubErrors tracks the no. of errors found by shallow scrubs within
+	// each pool. These are metadata mismatches such as object sizes or attributes.
+	ShallowScrubErrors *prometheus.Desc
+
+	// MisplacedObjects shows the no. of RADOS objects within each pool that are
+	// not stored on the OSDs they should be on, and are waiting to be moved.
+	MisplacedObjects *prometheus.Desc
+
+	// DegradedObjects shows the no. of RADOS objects within each pool that
+	// have fewer copies than they should, and DegradedRatio puts that in
+	// relation to all the object copies of the pool.
+	DegradedObjects *prometheus.Desc
+	DegradedRatio   *prometheus.Desc
+
+	// NumObjectsUnfound shows the no. of RADOS objects within each pool that
+	// the PG stats know no up to date copy of. Unlike UnfoundObjects, it
+	// doesn't need librados, so it is also reported by the fixture and report
+	// backends.
+	NumObjectsUnfound *prometheus.Desc
+
+	// HitSetArchiveObjects and HitSetArchiveBytes show the no. of objects and
+	// bytes held by the hit set archives of a cache-tier pool, which track the
+	// object accesses used to decide what gets promoted.
+	HitSetArchiveObjects *prometheus.Desc
+	HitSetArchiveBytes   *prometheus.Desc
+
+	// OmapObjects shows the no. of objects within each pool that have omap
+	// data, e.g. the bucket index objects of an RGW index pool.
+	OmapObjects *prometheus.Desc
+
+	// QuotaExceeded flags pools that have reached their max bytes or max objects
+	// quota, which is what raises the POOL_FULL warning.
+	QuotaExceeded *prometheus.Desc
+
+	// RecoveryWriteAmplification is the ratio of recovery bytes to client write
+	// bytes for pools that are currently recovering, showing how much extra
+	// write load a rebalance is putting on the pool.
+	RecoveryWriteAmplification *prometheus.Desc
+
+	// PGState shows the no. of PGs within each pool that are in one of
+	// poolPGStates, which makes the progress of pool creation visible.
+	PGState *prometheus.Desc
 }
 
+// poolPGStates are the PG states broken down per pool. PGs pass through them
+// while a pool is being created or its pg_num is changed.
+var poolPGStates = []string{"creating", "activating"}
+
 // NewPoolUsageCollector creates a new instance of PoolUsageCollector and returns
 // its reference.
 func NewPoolUsageCollector(exporter *Exporter) *PoolUsageCollector {
@@ -81,6 +138,7 @@ func NewPoolUsageCollector(exporter *Exporter) *PoolUsageCollector {
 	return &PoolUsageCollector{
 		conn:   exporter.Conn,
 		logger: exporter.Logger,
+		pgDump: exporter.pgDump,
 
 		UsedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_used_bytes", cephNamespace, subSystem), "Capacity of the pool that is currently under use",
 			poolLabel, labels,
@@ -115,6 +173,42 @@ func NewPoolUsageCollector(exporter *Exporter) *PoolUsageCollector {
 		WriteBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_write_bytes_total", cephNamespace, subSystem), "Total write throughput for the pool",
 			poolLabel, labels,
 		),
+		DeepScrubErrors: prometheus.NewDesc(fmt.Sprintf("%s_%s_deep_scrub_errors", cephNamespace, subSystem), "No. of errors found by deep scrubs in the pool",
+			poolLabel, labels,
+		),
+		ShallowScrubErrors: prometheus.NewDesc(fmt.Sprintf("%s_%s_shallow_scrub_errors", cephNamespace, subSystem), "No. of errors found by shallow scrubs in the pool",
+			poolLabel, labels,
+		),
+		MisplacedObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_misplaced_objects", cephNamespace, subSystem), "No. of misplaced objects in the pool, includes replicas",
+			poolLabel, labels,
+		),
+		DegradedObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_num_objects_degraded", cephNamespace, subSystem), "No. of degraded objects in the pool, includes replicas",
+			poolLabel, labels,
+		),
+		DegradedRatio: prometheus.NewDesc(fmt.Sprintf("%s_%s_degraded_ratio", cephNamespace, subSystem), "Ratio of degraded object copies to total object copies in the pool",
+			poolLabel, labels,
+		),
+		NumObjectsUnfound: prometheus.NewDesc(fmt.Sprintf("%s_%s_num_objects_unfound", cephNamespace, subSystem), "No. of unfound objects in the pool from the PG stats",
+			poolLabel, labels,
+		),
+		HitSetArchiveObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_num_objects_hit_set_archive", cephNamespace, subSystem), "No. of objects in the hit set archives of a cache-tier pool",
+			poolLabel, labels,
+		),
+		HitSetArchiveBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_num_bytes_hit_set_archive", cephNamespace, subSystem), "Bytes used by the hit set archives of a cache-tier pool",
+			poolLabel, labels,
+		),
+		OmapObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_num_objects_omap", cephNamespace, subSystem), "No. of objects with omap data in the pool",
+			poolLabel, labels,
+		),
+		RecoveryWriteAmplification: prometheus.NewDesc(fmt.Sprintf("%s_%s_recovery_write_amplification_ratio", cephNamespace, subSystem), "Ratio of recovery bytes to client write bytes for a recovering pool",
+			poolLabel, labels,
+		),
+		QuotaExceeded: prometheus.NewDesc(fmt.Sprintf("%s_%s_quota_exceeded", cephNamespace, subSystem), "Whether the pool has reached its max bytes or max objects quota",
+			poolLabel, labels,
+		),
+		PGState: prometheus.NewDesc(fmt.Sprintf("%s_%s_pg_state", cephNamespace, subSystem), "No. of PGs in the pool in the given state",
+			[]string{"pool", "state"}, labels,
+		),
 	}
 }
 
@@ -134,10 +228,41 @@ type cephPoolStats struct {
 			ReadBytes    float64 `json:"rd_bytes"`
 			WriteIO      float64 `json:"wr"`
 			WriteBytes   float64 `json:"wr_bytes"`
+			QuotaBytes   float64 `json:"quota_bytes"`
+			QuotaObjects float64 `json:"quota_objects"`
 		} `json:"stats"`
 	} `json:"pools"`
 }
 
+type cephOSDPoolStats []struct {
+	PoolName     string `json:"pool_name"`
+	RecoveryRate struct {
+		RecoveringBytesPerSec float64 `json:"recovering_bytes_per_sec"`
+	} `json:"recovery_rate"`
+	ClientIORate struct {
+		WriteBytesPerSec float64 `json:"write_bytes_sec"`
+	} `json:"client_io_rate"`
+}
+
+type cephPGPoolStats struct {
+	PoolStats []struct {
+		PoolID  int `json:"poolid"`
+		StatSum struct {
+			ShallowScrubErrors float64 `json:"num_shallow_scrub_errors"`
+			DeepScrubErrors    float64 `json:"num_deep_scrub_errors"`
+			ObjectsMisplaced   float64 `json:"num_objects_misplaced"`
+			ObjectsDegraded    float64 `json:"num_objects_degraded"`
+			ObjectsUnfound     float64 `json:"num_objects_unfound"`
+			ObjectCopies       float64 `json:"num_object_copies"`
+			ObjectsOmap        float64 `json:"num_objects_omap"`
+
+			// Only reported for pools that keep hit sets, i.e. cache tiers.
+			ObjectsHitSetArchive *float64 `json:"num_objects_hit_set_archive"`
+			BytesHitSetArchive   *float64 `json:"num_bytes_hit_set_archive"`
+		} `json:"stat_sum"`
+	} `json:"pool_stats"`
+}
+
 func (p *PoolUsageCollector) collect(ch chan<- prometheus.Metric) error {
 	cmd := p.cephUsageCommand()
 	buf, _, err := p.conn.MonCommand(cmd)
@@ -154,18 +279,33 @@ func (p *PoolUsageCollector) collect(ch chan<- prometheus.Metric) error {
 		return err
 	}
 
+	poolNames := make(map[int]string)
 	for _, pool := range stats.Pools {
+		poolNames[pool.ID] = pool.Name
+
 		ch <- prometheus.MustNewConstMetric(p.UsedBytes, prometheus.GaugeValue, pool.Stats.Stored, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.RawUsedBytes, prometheus.GaugeValue, math.Max(pool.Stats.StoredRaw, pool.Stats.BytesUsed), pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.MaxAvail, prometheus.GaugeValue, pool.Stats.MaxAvail, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.PercentUsed, prometheus.GaugeValue, pool.Stats.PercentUsed, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.Objects, prometheus.GaugeValue, pool.Stats.Objects, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.DirtyObjects, prometheus.GaugeValue, pool.Stats.DirtyObjects, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.ReadIO, prometheus.GaugeValue, pool.Stats.ReadIO, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.ReadBytes, prometheus.GaugeValue, pool.Stats.ReadBytes, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.WriteIO, prometheus.GaugeValue, pool.Stats.WriteIO, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.WriteBytes, prometheus.GaugeValue, pool.Stats.WriteBytes, pool.Name)
+		// The I/O stats are the pool's lifetime counters, not rates.
+		ch <- prometheus.MustNewConstMetric(p.ReadIO, prometheus.CounterValue, pool.Stats.ReadIO, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.ReadBytes, prometheus.CounterValue, pool.Stats.ReadBytes, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.WriteIO, prometheus.CounterValue, pool.Stats.WriteIO, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.WriteBytes, prometheus.CounterValue, pool.Stats.WriteBytes, pool.Name)
 
+		// A quota of 0 means the pool is unlimited.
+		quotaExceeded := 0.0
+		if (pool.Stats.QuotaBytes > 0 && pool.Stats.Stored >= pool.Stats.QuotaBytes) ||
+			(pool.Stats.QuotaObjects > 0 && pool.Stats.Objects >= pool.Stats.QuotaObjects) {
+			quotaExceeded = 1
+		}
+		ch <- prometheus.MustNewConstMetric(p.QuotaExceeded, prometheus.GaugeValue, quotaExceeded, pool.Name)
+
+		// A pool deleted since `ceph df` can't be opened anymore, and the
+		// fixture backend has no librados stats, this only drops the
+		// metric of that pool and doesn't fail the scrape.
 		st, err := p.conn.GetPoolStats(pool.Name)
 		if err != nil {
 			p.logger.WithError(err).WithField(
@@ -178,9 +318,161 @@ func (p *PoolUsageCollector) collect(ch chan<- prometheus.Metric) error {
 		ch <- prometheus.MustNewConstMetric(p.UnfoundObjects, prometheus.GaugeValue, float64(st.ObjectsUnfound), pool.Name)
 	}
 
+	errs := &collectErrors{}
+	if err := p.collectPGPoolStats(ch, poolNames); err != nil {
+		p.logger.WithError(err).Error("error collecting pool pg stats")
+		errs.add(err)
+	}
+
+	if err := p.collectRecoveryWriteAmplification(ch); err != nil {
+		p.logger.WithError(err).Error("error collecting pool recovery write amplification")
+		errs.add(err)
+	}
+
+	if err := p.collectPGStates(ch, poolNames); err != nil {
+		p.logger.WithError(err).Error("error collecting pool pg states")
+		errs.add(err)
+	}
+
+	return errs.err()
+}
+
+// collectPGPoolStats extracts the per-pool PG stat sums from `ceph pg dump pools`.
+// These are keyed by pool id, so poolNames is used to map them back to the pool
+// names reported by `ceph df detail`.
+func (p *PoolUsageCollector) collectPGPoolStats(ch chan<- prometheus.Metric, poolNames map[int]string) error {
+	args := p.cephPGDumpPoolsCommand()
+	buf, _, err := p.conn.MgrCommand(args)
+	if err != nil {
+		p.logger.WithError(err).WithField(
+			"args", string(bytes.Join(args, []byte(","))),
+		).Error("error executing mgr command")
+
+		return err
+	}
+
+	stats := &cephPGPoolStats{}
+	if err := json.Unmarshal(buf, stats); err != nil {
+		return err
+	}
+
+	for _, pool := range stats.PoolStats {
+		name, ok := poolNames[pool.PoolID]
+		if !ok {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.DeepScrubErrors, prometheus.GaugeValue, pool.StatSum.DeepScrubErrors, name)
+		ch <- prometheus.MustNewConstMetric(p.ShallowScrubErrors, prometheus.GaugeValue, pool.StatSum.ShallowScrubErrors, name)
+		ch <- prometheus.MustNewConstMetric(p.MisplacedObjects, prometheus.GaugeValue, pool.StatSum.ObjectsMisplaced, name)
+		ch <- prometheus.MustNewConstMetric(p.DegradedObjects, prometheus.GaugeValue, pool.StatSum.ObjectsDegraded, name)
+
+		degradedRatio := 0.0
+		if pool.StatSum.ObjectCopies > 0 {
+			degradedRatio = pool.StatSum.ObjectsDegraded / pool.StatSum.ObjectCopies
+		}
+		ch <- prometheus.MustNewConstMetric(p.DegradedRatio, prometheus.GaugeValue, degradedRatio, name)
+		ch <- prometheus.MustNewConstMetric(p.NumObjectsUnfound, prometheus.GaugeValue, pool.StatSum.ObjectsUnfound, name)
+		ch <- prometheus.MustNewConstMetric(p.OmapObjects, prometheus.GaugeValue, pool.StatSum.ObjectsOmap, name)
+
+		if pool.StatSum.ObjectsHitSetArchive != nil {
+			ch <- prometheus.MustNewConstMetric(p.HitSetArchiveObjects, prometheus.GaugeValue, *pool.StatSum.ObjectsHitSetArchive, name)
+		}
+		if pool.StatSum.BytesHitSetArchive != nil {
+			ch <- prometheus.MustNewConstMetric(p.HitSetArchiveBytes, prometheus.GaugeValue, *pool.StatSum.BytesHitSetArchive, name)
+		}
+	}
+
+	return nil
+}
+
+// collectRecoveryWriteAmplification compares the recovery and client write
+// rates from `ceph osd pool stats`. Pools that aren't recovering, or have no
+// client writes to compare against, are skipped.
+func (p *PoolUsageCollector) collectRecoveryWriteAmplification(ch chan<- prometheus.Metric) error {
+	cmd := p.cephOSDPoolStatsCommand()
+	buf, _, err := p.conn.MonCommand(cmd)
+	if err != nil {
+		p.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	stats := cephOSDPoolStats{}
+	if err := json.Unmarshal(buf, &stats); err != nil {
+		return err
+	}
+
+	for _, pool := range stats {
+		recovering := pool.RecoveryRate.RecoveringBytesPerSec
+		written := pool.ClientIORate.WriteBytesPerSec
+		if recovering <= 0 || written <= 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.RecoveryWriteAmplification, prometheus.GaugeValue, recovering/written, pool.PoolName)
+	}
+
+	return nil
+}
+
+// collectPGStates counts the PGs of each pool that are in one of poolPGStates,
+// using the PG dump of the scrape. PG ids are of the form <pool id>.<pg>, so
+// poolNames is used to map them back to the pool names.
+func (p *PoolUsageCollector) collectPGStates(ch chan<- prometheus.Metric, poolNames map[int]string) error {
+	pgDump, err := p.pgDump.get()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[int]map[string]float64, len(poolNames))
+	for id := range poolNames {
+		counts[id] = make(map[string]float64, len(poolPGStates))
+	}
+
+	for _, pg := range pgDump.PGStats {
+		poolID, _, found := strings.Cut(pg.PGID, ".")
+		if !found {
+			continue
+		}
+
+		id, err := strconv.Atoi(poolID)
+		if err != nil {
+			continue
+		}
+
+		poolCounts, ok := counts[id]
+		if !ok {
+			continue
+		}
+
+		for _, state := range strings.Split(pg.State, "+") {
+			poolCounts[state]++
+		}
+	}
+
+	for id, name := range poolNames {
+		for _, state := range poolPGStates {
+			ch <- prometheus.MustNewConstMetric(p.PGState, prometheus.GaugeValue, counts[id][state], name, state)
+		}
+	}
+
 	return nil
 }
 
+func (p *PoolUsageCollector) cephOSDPoolStatsCommand() []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd pool stats",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		p.logger.WithError(err).Panic("error marshalling ceph osd pool stats")
+	}
+	return cmd
+}
+
 func (p *PoolUsageCollector) cephUsageCommand() []byte {
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "df",
@@ -193,6 +485,18 @@ func (p *PoolUsageCollector) cephUsageCommand() []byte {
 	return cmd
 }
 
+func (p *PoolUsageCollector) cephPGDumpPoolsCommand() [][]byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix":       "pg dump",
+		"dumpcontents": []string{"pools"},
+		"format":       jsonFormat,
+	})
+	if err != nil {
+		p.logger.WithError(err).Panic("error marshalling ceph pg dump pools")
+	}
+	return [][]byte{cmd}
+}
+
 // Describe fulfills the prometheus.Collector's interface and sends the descriptors
 // of pool's metrics to the given channel.
 func (p *PoolUsageCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -207,14 +511,28 @@ func (p *PoolUsageCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- p.ReadBytes
 	ch <- p.WriteIO
 	ch <- p.WriteBytes
+	ch <- p.DeepScrubErrors
+	ch <- p.ShallowScrubErrors
+	ch <- p.MisplacedObjects
+	ch <- p.DegradedObjects
+	ch <- p.DegradedRatio
+	ch <- p.NumObjectsUnfound
+	ch <- p.HitSetArchiveObjects
+	ch <- p.HitSetArchiveBytes
+	ch <- p.OmapObjects
+	ch <- p.QuotaExceeded
+	ch <- p.RecoveryWriteAmplification
+	ch <- p.PGState
 }
 
 // Collect extracts the current values of all the metrics and sends them to the
 // prometheus channel.
-func (p *PoolUsageCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (p *PoolUsageCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
 	p.logger.Debug("collecting pool usage metrics")
 	if err := p.collect(ch); err != nil {
 		p.logger.WithError(err).Error("error collecting pool usage metrics")
-		return
+		return err
 	}
+
+	return nil
 }
@@ -15,6 +15,7 @@
 package ceph
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -22,6 +23,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
@@ -32,6 +34,8 @@ import (
 func TestPoolUsageCollector(t *testing.T) {
 	for _, tt := range []struct {
 		input              string
+		pgDump             string
+		poolStats          string
 		version            string
 		reMatch, reUnmatch []*regexp.Regexp
 	}{
@@ -46,6 +50,10 @@ func TestPoolUsageCollector(t *testing.T) {
 				regexp.MustCompile(`pool_objects_total{cluster="ceph",pool="rbd"} 5`),
 				regexp.MustCompile(`pool_read_total{cluster="ceph",pool="rbd"} 4`),
 				regexp.MustCompile(`pool_write_total{cluster="ceph",pool="rbd"} 6`),
+				regexp.MustCompile(`# TYPE ceph_pool_read_total counter`),
+				regexp.MustCompile(`# TYPE ceph_pool_read_bytes_total counter`),
+				regexp.MustCompile(`# TYPE ceph_pool_write_total counter`),
+				regexp.MustCompile(`# TYPE ceph_pool_write_bytes_total counter`),
 			},
 			reUnmatch: []*regexp.Regexp{},
 		},
@@ -186,19 +194,239 @@ func TestPoolUsageCollector(t *testing.T) {
 				regexp.MustCompile(`ceph_pool_write_total{cluster="ceph",pool="cinder_ssd"} 26721`),
 			},
 		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5, "rd": 4, "wr": 6}},
+	{"name": "rgw", "id": 12, "stats": {"stored": 50, "objects": 20, "rd": 10, "wr": 30}}
+]}`,
+			pgDump: `
+{
+	"pg_ready": true,
+	"pool_stats": [
+		{"poolid": 11, "num_pg": 32, "stat_sum": {"num_objects": 5, "num_scrub_errors": 4, "num_shallow_scrub_errors": 1, "num_deep_scrub_errors": 3}},
+		{"poolid": 12, "num_pg": 32, "stat_sum": {"num_objects": 20, "num_scrub_errors": 2, "num_shallow_scrub_errors": 2, "num_deep_scrub_errors": 0}},
+		{"poolid": 13, "num_pg": 32, "stat_sum": {"num_objects": 0, "num_scrub_errors": 7, "num_shallow_scrub_errors": 0, "num_deep_scrub_errors": 7}}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_deep_scrub_errors{cluster="ceph",pool="rbd"} 3`),
+				regexp.MustCompile(`ceph_pool_shallow_scrub_errors{cluster="ceph",pool="rbd"} 1`),
+				regexp.MustCompile(`ceph_pool_deep_scrub_errors{cluster="ceph",pool="rgw"} 0`),
+				regexp.MustCompile(`ceph_pool_shallow_scrub_errors{cluster="ceph",pool="rgw"} 2`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_deep_scrub_errors{cluster="ceph",pool="rbd"} 4`),
+				regexp.MustCompile(`ceph_pool_deep_scrub_errors{cluster="ceph",pool=""}`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5, "rd": 4, "wr": 6}},
+	{"name": "rgw", "id": 12, "stats": {"stored": 50, "objects": 20, "rd": 10, "wr": 30}}
+]}`,
+			pgDump: `
+{
+	"pg_ready": true,
+	"pool_stats": [
+		{"poolid": 11, "num_pg": 32, "stat_sum": {"num_objects": 5, "num_object_copies": 15, "num_objects_misplaced": 0}},
+		{"poolid": 12, "num_pg": 32, "stat_sum": {"num_objects": 20, "num_object_copies": 60, "num_objects_misplaced": 42}}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_misplaced_objects{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_misplaced_objects{cluster="ceph",pool="rgw"} 42`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5, "rd": 4, "wr": 6}},
+	{"name": "rbd-cache", "id": 12, "stats": {"stored": 50, "objects": 20, "rd": 10, "wr": 30}}
+]}`,
+			pgDump: `
+{
+	"pg_ready": true,
+	"pool_stats": [
+		{"poolid": 11, "num_pg": 32, "stat_sum": {"num_objects": 5}},
+		{"poolid": 12, "num_pg": 32, "stat_sum": {"num_objects": 20, "num_objects_hit_set_archive": 8, "num_bytes_hit_set_archive": 65536}}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_num_objects_hit_set_archive{cluster="ceph",pool="rbd-cache"} 8`),
+				regexp.MustCompile(`ceph_pool_num_bytes_hit_set_archive{cluster="ceph",pool="rbd-cache"} 65536`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_num_objects_hit_set_archive{cluster="ceph",pool="rbd"}`),
+				regexp.MustCompile(`ceph_pool_num_bytes_hit_set_archive{cluster="ceph",pool="rbd"}`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5}},
+	{"name": "rgw", "id": 12, "stats": {"stored": 20, "objects": 20}},
+	{"name": "empty", "id": 13, "stats": {"stored": 0, "objects": 0}}
+]}`,
+			pgDump: `
+{
+	"pg_ready": true,
+	"pool_stats": [
+		{"poolid": 11, "num_pg": 32, "stat_sum": {"num_objects": 5, "num_object_copies": 15, "num_objects_degraded": 0}},
+		{"poolid": 12, "num_pg": 32, "stat_sum": {"num_objects": 20, "num_object_copies": 60, "num_objects_degraded": 15}},
+		{"poolid": 13, "num_pg": 32, "stat_sum": {"num_objects": 0, "num_object_copies": 0, "num_objects_degraded": 0}}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_num_objects_degraded{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_num_objects_degraded{cluster="ceph",pool="rgw"} 15`),
+				regexp.MustCompile(`ceph_pool_degraded_ratio{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_degraded_ratio{cluster="ceph",pool="rgw"} 0.25`),
+				regexp.MustCompile(`ceph_pool_degraded_ratio{cluster="ceph",pool="empty"} 0`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5}},
+	{"name": "default.rgw.buckets.index", "id": 12, "stats": {"stored": 0, "objects": 211}}
+]}`,
+			pgDump: `
+{
+	"pg_ready": true,
+	"pool_stats": [
+		{"poolid": 11, "num_pg": 32, "stat_sum": {"num_objects": 5, "num_objects_omap": 0}},
+		{"poolid": 12, "num_pg": 32, "stat_sum": {"num_objects": 211, "num_objects_omap": 209}}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_num_objects_omap{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_num_objects_omap{cluster="ceph",pool="default.rgw.buckets.index"} 209`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5, "quota_bytes": 0, "quota_objects": 0}},
+	{"name": "rgw", "id": 12, "stats": {"stored": 20, "objects": 1000, "quota_bytes": 0, "quota_objects": 1000}},
+	{"name": "cephfs", "id": 13, "stats": {"stored": 2048, "objects": 10, "quota_bytes": 1024, "quota_objects": 0}},
+	{"name": "scratch", "id": 14, "stats": {"stored": 512, "objects": 10, "quota_bytes": 1024, "quota_objects": 100}}
+]}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_quota_exceeded{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_quota_exceeded{cluster="ceph",pool="rgw"} 1`),
+				regexp.MustCompile(`ceph_pool_quota_exceeded{cluster="ceph",pool="cephfs"} 1`),
+				regexp.MustCompile(`ceph_pool_quota_exceeded{cluster="ceph",pool="scratch"} 0`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5}},
+	{"name": "rgw", "id": 12, "stats": {"stored": 20, "objects": 5}},
+	{"name": "cephfs", "id": 13, "stats": {"stored": 20, "objects": 5}}
+]}`,
+			poolStats: `
+[
+	{
+		"pool_name": "rbd",
+		"pool_id": 11,
+		"recovery": {"degraded_objects": 120, "degraded_total": 6000, "degraded_ratio": 0.02},
+		"recovery_rate": {"recovering_objects_per_sec": 12, "recovering_bytes_per_sec": 50331648, "recovering_keys_per_sec": 0},
+		"client_io_rate": {"read_bytes_sec": 4194304, "write_bytes_sec": 16777216, "read_op_per_sec": 100, "write_op_per_sec": 200}
+	},
+	{
+		"pool_name": "rgw",
+		"pool_id": 12,
+		"recovery": {},
+		"recovery_rate": {},
+		"client_io_rate": {"write_bytes_sec": 1048576, "write_op_per_sec": 20}
+	},
+	{
+		"pool_name": "cephfs",
+		"pool_id": 13,
+		"recovery": {},
+		"recovery_rate": {"recovering_bytes_per_sec": 1048576},
+		"client_io_rate": {}
+	}
+]`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_recovery_write_amplification_ratio{cluster="ceph",pool="rbd"} 3`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_recovery_write_amplification_ratio{cluster="ceph",pool="rgw"}`),
+				regexp.MustCompile(`ceph_pool_recovery_write_amplification_ratio{cluster="ceph",pool="cephfs"}`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5}},
+	{"name": "new", "id": 14, "stats": {"stored": 0, "objects": 0}}
+]}`,
+			pgDump: `
+{
+	"pg_stats": [
+		{"pgid": "11.0", "state": "active+clean", "up": [1, 2, 3], "acting": [1, 2, 3], "acting_primary": 1},
+		{"pgid": "11.1", "state": "active+clean", "up": [2, 3, 1], "acting": [2, 3, 1], "acting_primary": 2},
+		{"pgid": "14.0", "state": "creating", "up": [1, 2, 3], "acting": [1, 2, 3], "acting_primary": 1},
+		{"pgid": "14.1", "state": "creating+peering", "up": [2, 3, 1], "acting": [2, 3, 1], "acting_primary": 2},
+		{"pgid": "14.2", "state": "creating+activating", "up": [3, 1, 2], "acting": [3, 1, 2], "acting_primary": 3},
+		{"pgid": "14.3", "state": "activating", "up": [1, 3, 2], "acting": [1, 3, 2], "acting_primary": 1},
+		{"pgid": "15.0", "state": "creating", "up": [1, 2, 3], "acting": [1, 2, 3], "acting_primary": 1}
+	]
+}`,
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_pg_state{cluster="ceph",pool="new",state="creating"} 3`),
+				regexp.MustCompile(`ceph_pool_pg_state{cluster="ceph",pool="new",state="activating"} 2`),
+				regexp.MustCompile(`ceph_pool_pg_state{cluster="ceph",pool="rbd",state="creating"} 0`),
+				regexp.MustCompile(`ceph_pool_pg_state{cluster="ceph",pool="rbd",state="activating"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_pg_state{cluster="ceph",pool="rbd",state="active"}`),
+				regexp.MustCompile(`ceph_pool_pg_state{cluster="ceph",pool="",`),
+			},
+		},
 	} {
 		func() {
 			conn := setupVersionMocks(tt.version, "{}")
 
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				v := map[string]interface{}{}
+
+				err := json.Unmarshal(in.([]byte), &v)
+				require.NoError(t, err)
+
+				return cmp.Equal(v, map[string]interface{}{
+					"prefix": "osd pool stats",
+					"format": "json",
+				})
+			})).Return(
+				[]byte(tt.poolStats), "", nil,
+			)
+
 			conn.On("MonCommand", mock.Anything).Return(
 				[]byte(tt.input), "", nil,
 			)
 
+			conn.On("MgrCommand", mock.Anything).Return(
+				[]byte(tt.pgDump), "", nil,
+			)
+
 			conn.On("GetPoolStats", mock.Anything).Return(
 				nil, fmt.Errorf("not implemented"),
 			)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"poolUsage": NewPoolUsageCollector(e),
 			}
@@ -225,3 +453,39 @@ func TestPoolUsageCollector(t *testing.T) {
 		}()
 	}
 }
+
+func TestPoolUsageCollectorPGDumpError(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(isMonCommand(map[string]interface{}{
+		"prefix": "osd pool stats",
+		"format": "json",
+	}))).Return([]byte(`[]`), "", nil)
+	conn.On("MonCommand", mock.Anything).Return([]byte(`
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20, "objects": 5}}
+]}`), "", nil)
+	conn.On("MgrCommand", mock.Anything).Return([]byte(""), "", fmt.Errorf("timed out"))
+	conn.On("GetPoolStats", mock.Anything).Return(nil, fmt.Errorf("not implemented"))
+
+	e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+	p := NewPoolUsageCollector(e)
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	metrics := 0
+	go func() {
+		defer close(done)
+		for range ch {
+			metrics++
+		}
+	}()
+
+	// The usage from `ceph df` is still reported, but the scrape fails as
+	// the PG stats are missing.
+	err := p.Collect(ch, Pacific)
+	close(ch)
+	<-done
+
+	require.ErrorContains(t, err, "timed out")
+	require.NotZero(t, metrics)
+}
@@ -0,0 +1,114 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// ProgressCollector reports the long running operations tracked by the mgr
+// progress module, e.g. recovery or draining an OSD, so that dashboards can
+// show how far along they are.
+type ProgressCollector struct {
+	conn   Conn
+	logger *logrus.Logger
+
+	eventDesc *prometheus.Desc
+}
+
+// NewProgressCollector creates a new ProgressCollector instance
+func NewProgressCollector(exporter *Exporter) *ProgressCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+
+	return &ProgressCollector{
+		conn:   exporter.Conn,
+		logger: exporter.Logger,
+
+		eventDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_progress_event", cephNamespace),
+			"Fraction complete of an ongoing operation tracked by the progress module",
+			[]string{"id", "message"},
+			labels,
+		),
+	}
+}
+
+// cephProgress only holds the ongoing events, the completed ones are left out.
+// There are none while tracking is turned off with `ceph progress off`, the
+// module itself is always on since Nautilus so the command doesn't fail.
+type cephProgress struct {
+	Events []struct {
+		ID       string  `json:"id"`
+		Message  string  `json:"message"`
+		Progress float64 `json:"progress"`
+	} `json:"events"`
+}
+
+func (p *ProgressCollector) getProgress() (*cephProgress, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "progress json",
+		"format": "json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := p.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		p.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mgr command")
+
+		return nil, err
+	}
+
+	progress := &cephProgress{}
+	if err := json.Unmarshal(buf, progress); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+// Describe provides the metrics descriptions to Prometheus
+func (p *ProgressCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.eventDesc
+}
+
+// minVersion is Nautilus, before which the progress module has to be
+// enabled first.
+func (p *ProgressCollector) minVersion() *Version {
+	return Nautilus
+}
+
+// Collect sends all the collected metrics Prometheus.
+func (p *ProgressCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	progress, err := p.getProgress()
+	if err != nil {
+		p.logger.WithError(err).Error("failed to run 'ceph progress json'")
+		return err
+	}
+
+	for _, event := range progress.Events {
+		ch <- prometheus.MustNewConstMetric(p.eventDesc, prometheus.GaugeValue, event.Progress, event.ID, event.Message)
+	}
+
+	return nil
+}
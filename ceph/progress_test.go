@@ -0,0 +1,108 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		version            string
+		input              string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:    "ongoing and completed events",
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			input: `
+{
+	"events": [
+		{"message": "Rebalancing after osd.3 marked out", "id": "b3f0a1c2-5d6e-4f70-8a9b-0c1d2e3f4a5b", "refs": [], "started_at": 1700000000.0, "progress": 0.25, "add_to_ceph_s": true},
+		{"message": "Global Recovery Event", "id": "c4e1b2d3-6e7f-4a81-9bac-1d2e3f4a5b6c", "refs": [], "started_at": 1700000100.0, "progress": 0.0, "add_to_ceph_s": true}
+	],
+	"completed": [
+		{"message": "Rebalancing after osd.1 marked in", "id": "d5f2c3e4-7f80-4b92-acbd-2e3f4a5b6c7d", "refs": [], "started_at": 1699990000.0, "finished_at": 1699990600.0, "progress": 1.0, "add_to_ceph_s": true}
+	]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_progress_event{cluster="ceph",id="b3f0a1c2-5d6e-4f70-8a9b-0c1d2e3f4a5b",message="Rebalancing after osd.3 marked out"} 0.25`),
+				regexp.MustCompile(`ceph_progress_event{cluster="ceph",id="c4e1b2d3-6e7f-4a81-9bac-1d2e3f4a5b6c",message="Global Recovery Event"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_progress_event{[^}]*id="d5f2c3e4-7f80-4b92-acbd-2e3f4a5b6c7d"`),
+			},
+		},
+		{
+			name:    "progress turned off",
+			version: `{"version":"ceph version 16.2.11-22-wasd (1984a8c33225d70559cdf27dbab81e3ce153f6ac) pacific (stable)"}`,
+			input:   `{"events": [], "completed": []}`,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_progress_event`),
+			},
+		},
+		{
+			name:    "before nautilus",
+			version: `{"version":"ceph version 12.2.13 (584a20eb0237c657dc0567da126be145106aa47e) luminous (stable)"}`,
+			input:   `{"events": [{"message": "Rebalancing after osd.3 marked out", "id": "b3f0a1c2-5d6e-4f70-8a9b-0c1d2e3f4a5b", "progress": 0.25}]}`,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_progress_event`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := setupVersionMocks(tt.version, "{}")
+			conn.On("MgrCommand", mock.Anything).Return(
+				[]byte(tt.input), "", nil,
+			)
+
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
+			e.cc = map[string]versionedCollector{
+				"progress": NewProgressCollector(e),
+			}
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(e))
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), "expected %s to match", re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), "expected %s not to match", re.String())
+			}
+		})
+	}
+}
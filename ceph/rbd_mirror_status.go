@@ -48,10 +48,11 @@ type rbdMirrorPoolStatus struct {
 
 // RbdMirrorStatusCollector displays statistics about each pool in the Ceph cluster.
 type RbdMirrorStatusCollector struct {
-	config  string
-	user    string
-	logger  *logrus.Logger
-	version *Version
+	config string
+	user   string
+	logger *logrus.Logger
+
+	versionGates
 
 	getRbdMirrorStatus func(config string, user string) ([]byte, error)
 
@@ -80,10 +81,9 @@ func NewRbdMirrorStatusCollector(exporter *Exporter) *RbdMirrorStatusCollector {
 	labels["cluster"] = exporter.Cluster
 
 	collector := &RbdMirrorStatusCollector{
-		config:  exporter.Config,
-		user:    exporter.User,
-		logger:  exporter.Logger,
-		version: exporter.Version,
+		config: exporter.Config,
+		user:   exporter.User,
+		logger: exporter.Logger,
 
 		getRbdMirrorStatus: rbdMirrorStatus,
 
@@ -118,9 +118,10 @@ func NewRbdMirrorStatusCollector(exporter *Exporter) *RbdMirrorStatusCollector {
 	return collector
 }
 
-func (c *RbdMirrorStatusCollector) metricsList() []prometheus.Metric {
-
-	if c.version.IsAtLeast(Pacific) {
+// metricsList returns the metrics to report, the daemon and image status are
+// only reported from Pacific on.
+func (c *RbdMirrorStatusCollector) metricsList(daemonStatus bool) []prometheus.Metric {
+	if daemonStatus {
 		return []prometheus.Metric{
 			c.RbdMirrorStatus,
 			c.RbdMirrorDaemonStatus,
@@ -149,31 +150,38 @@ func (c *RbdMirrorStatusCollector) mirrorStatusStringToInt(status string) float6
 
 // Describe provides the metrics descriptions to Prometheus
 func (c *RbdMirrorStatusCollector) Describe(ch chan<- *prometheus.Desc) {
-	for _, metric := range c.metricsList() {
+	for _, metric := range c.metricsList(true) {
 		ch <- metric.Desc()
 	}
 }
 
 // Collect sends all the collected metrics Prometheus.
-func (c *RbdMirrorStatusCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
-	status, err := rbdMirrorStatus(c.config, c.user)
-	if err != nil {
-		c.logger.WithError(err).Error("failed to run 'rbd mirror pool status'")
+func (c *RbdMirrorStatusCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	status, cmdErr := rbdMirrorStatus(c.config, c.user)
+	if cmdErr != nil {
+		c.logger.WithError(cmdErr).Error("failed to run 'rbd mirror pool status'")
 	}
 	var rbdStatus rbdMirrorPoolStatus
-	if err = json.Unmarshal(status, &rbdStatus); err != nil {
+	err := json.Unmarshal(status, &rbdStatus)
+	if err != nil {
 		c.logger.WithError(err).Error("failed to Unmarshal rbd mirror pool status output")
 	}
 
 	c.RbdMirrorStatus.Set(c.mirrorStatusStringToInt(rbdStatus.Summary.Health))
-	c.version = version
 
-	if c.version.IsAtLeast(Pacific) {
+	daemonStatus := c.versionAtLeast(version, Pacific, "daemon_image_status")
+	if daemonStatus {
 		c.RbdMirrorDaemonStatus.Set(c.mirrorStatusStringToInt(rbdStatus.Summary.DaemonHealth))
 		c.RbdMirrorImageStatus.Set(c.mirrorStatusStringToInt(rbdStatus.Summary.ImageHealth))
 	}
-	for _, metric := range c.metricsList() {
+	for _, metric := range c.metricsList(daemonStatus) {
 		ch <- metric
 	}
 
+	// The output can't be parsed when the command failed, only report why
+	// it failed then.
+	if cmdErr != nil {
+		return cmdErr
+	}
+	return err
 }
@@ -134,7 +134,7 @@ func TestRbdMirrorStatusCollector(t *testing.T) {
 		func() {
 			conn := setupVersionMocks(tt.version, tt.versions)
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			// We do not create the rbdCollector since it will
 			// be automatically initiated from the output of `ceph versions`
 			// if the rbd-mirror key is present
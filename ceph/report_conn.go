@@ -0,0 +1,350 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ReportConn is a Conn that answers commands from the output of a single
+// `ceph report`, so that the metrics of a cluster can be looked at after the
+// fact, e.g. for post-incident analysis of a report saved at the time.
+//
+// A report only holds a snapshot of the cluster maps and health, so only the
+// commands whose responses can be derived from it are answered, everything
+// else fails as if the command had timed out.
+type ReportConn struct {
+	path   string
+	report cephReport
+}
+
+// cephReport holds the sections of `ceph report` that commands are mapped to.
+type cephReport struct {
+	Version     string          `json:"version"`
+	Commit      string          `json:"commit"`
+	Health      json.RawMessage `json:"health"`
+	Quorum      json.RawMessage `json:"quorum"`
+	MonMap      json.RawMessage `json:"monmap"`
+	OSDMap      json.RawMessage `json:"osdmap"`
+	OSDMetadata json.RawMessage `json:"osd_metadata"`
+	FSMap       json.RawMessage `json:"fsmap"`
+	CrushMap    json.RawMessage `json:"crushmap"`
+	PGMap       struct {
+		OSDStatsSum struct {
+			KB      float64 `json:"kb"`
+			KBUsed  float64 `json:"kb_used"`
+			KBAvail float64 `json:"kb_avail"`
+		} `json:"osd_stats_sum"`
+		PoolStats json.RawMessage `json:"pool_stats"`
+	} `json:"pgmap"`
+}
+
+// reportPoolStats are the fields of a pool's pgmap stats that `df` and the
+// pool stats are built from.
+type reportPoolStats struct {
+	PoolID  int `json:"poolid"`
+	StatSum struct {
+		NumBytes          float64 `json:"num_bytes"`
+		NumObjects        float64 `json:"num_objects"`
+		NumObjectsUnfound uint64  `json:"num_objects_unfound"`
+		NumRead           float64 `json:"num_read"`
+		NumReadKB         float64 `json:"num_read_kb"`
+		NumWrite          float64 `json:"num_write"`
+		NumWriteKB        float64 `json:"num_write_kb"`
+	} `json:"stat_sum"`
+}
+
+// reportPool is a pool of the osdmap of the report.
+type reportPool struct {
+	ID              int     `json:"pool"`
+	Name            string  `json:"pool_name"`
+	QuotaMaxBytes   float64 `json:"quota_max_bytes"`
+	QuotaMaxObjects float64 `json:"quota_max_objects"`
+}
+
+// quorumStatus builds the output of `ceph quorum_status`, naming the Mons in
+// quorum by their rank in the monmap.
+func (r *cephReport) quorumStatus() (interface{}, error) {
+	var quorum []int
+	if err := json.Unmarshal(r.Quorum, &quorum); err != nil {
+		return nil, err
+	}
+
+	monMap := struct {
+		Mons []struct {
+			Rank int    `json:"rank"`
+			Name string `json:"name"`
+		} `json:"mons"`
+	}{}
+	if err := json.Unmarshal(r.MonMap, &monMap); err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string, len(monMap.Mons))
+	for _, mon := range monMap.Mons {
+		names[mon.Rank] = mon.Name
+	}
+
+	quorumNames := make([]string, 0, len(quorum))
+	for _, rank := range quorum {
+		quorumNames = append(quorumNames, names[rank])
+	}
+
+	return map[string]interface{}{
+		"quorum":       r.Quorum,
+		"quorum_names": quorumNames,
+		"monmap":       r.MonMap,
+	}, nil
+}
+
+// df builds the output of `ceph df detail` from the pgmap. The space
+// available to each pool depends on the CRUSH rule and the fullest OSD, which
+// the report doesn't tell, so it's left out.
+func (r *cephReport) df() (interface{}, error) {
+	osdMap := struct {
+		Pools []reportPool `json:"pools"`
+	}{}
+	if err := json.Unmarshal(r.OSDMap, &osdMap); err != nil {
+		return nil, err
+	}
+
+	var poolStats []reportPoolStats
+	if err := json.Unmarshal(r.PGMap.PoolStats, &poolStats); err != nil {
+		return nil, err
+	}
+
+	statsByID := make(map[int]reportPoolStats, len(poolStats))
+	for _, s := range poolStats {
+		statsByID[s.PoolID] = s
+	}
+
+	pools := make([]map[string]interface{}, 0, len(osdMap.Pools))
+	for _, pool := range osdMap.Pools {
+		sum := statsByID[pool.ID].StatSum
+		pools = append(pools, map[string]interface{}{
+			"name": pool.Name,
+			"id":   pool.ID,
+			"stats": map[string]float64{
+				"stored":        sum.NumBytes,
+				"objects":       sum.NumObjects,
+				"rd":            sum.NumRead,
+				"rd_bytes":      sum.NumReadKB * 1024,
+				"wr":            sum.NumWrite,
+				"wr_bytes":      sum.NumWriteKB * 1024,
+				"quota_bytes":   pool.QuotaMaxBytes,
+				"quota_objects": pool.QuotaMaxObjects,
+			},
+		})
+	}
+
+	osdSum := r.PGMap.OSDStatsSum
+	return map[string]interface{}{
+		"stats": map[string]float64{
+			"total_bytes":       osdSum.KB * 1024,
+			"total_used_bytes":  osdSum.KBUsed * 1024,
+			"total_avail_bytes": osdSum.KBAvail * 1024,
+		},
+		"pools": pools,
+	}, nil
+}
+
+// poolStat builds the librados stats of the named pool from its pgmap stats,
+// librados sums the same per-PG stats.
+func (r *cephReport) poolStat(name string) (*PoolStat, error) {
+	osdMap := struct {
+		Pools []reportPool `json:"pools"`
+	}{}
+	if err := json.Unmarshal(r.OSDMap, &osdMap); err != nil {
+		return nil, err
+	}
+
+	var poolStats []reportPoolStats
+	if err := json.Unmarshal(r.PGMap.PoolStats, &poolStats); err != nil {
+		return nil, err
+	}
+
+	for _, pool := range osdMap.Pools {
+		if pool.Name != name {
+			continue
+		}
+
+		for _, s := range poolStats {
+			if s.PoolID == pool.ID {
+				return &PoolStat{ObjectsUnfound: s.StatSum.NumObjectsUnfound}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no pgmap stats of pool %s", name)
+}
+
+// NewReportConn returns a ReportConn answering from the `ceph report` output
+// saved at path.
+func NewReportConn(path string) (*ReportConn, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ReportConn{path: path}
+	if err := json.Unmarshal(buf, &r.report); err != nil {
+		return nil, fmt.Errorf("error parsing ceph report %s: %s", path, err)
+	}
+
+	return r, nil
+}
+
+// reportResponses builds the response to a command, keyed by the fixture
+// name of the command (see FixtureConn), from the report.
+var reportResponses = map[string]func(r *cephReport) (interface{}, error){
+	"version": func(r *cephReport) (interface{}, error) {
+		version := fmt.Sprintf("ceph version %s (%s)", r.Version, r.Commit)
+
+		v, err := ParseCephVersion(version)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]string{
+			"version": fmt.Sprintf("%s %s (stable)", version, v.Release()),
+		}, nil
+	},
+	"versions": func(r *cephReport) (interface{}, error) {
+		// Only the OSDs' versions are part of the report.
+		var metadata []struct {
+			CephVersion string `json:"ceph_version"`
+		}
+		if err := json.Unmarshal(r.OSDMetadata, &metadata); err != nil {
+			return nil, err
+		}
+
+		osds := make(map[string]int)
+		for _, m := range metadata {
+			osds[m.CephVersion]++
+		}
+
+		return map[string]map[string]int{
+			"osd":     osds,
+			"overall": osds,
+		}, nil
+	},
+	"status": func(r *cephReport) (interface{}, error) {
+		return map[string]json.RawMessage{
+			"health": r.Health,
+			"quorum": r.Quorum,
+			"monmap": r.MonMap,
+		}, nil
+	},
+	"quorum_status": (*cephReport).quorumStatus,
+	// Neither clock skews nor connected clients' features are part of the
+	// report, answer them as empty so that the rest of the monitor metrics
+	// are still exported.
+	"time-sync-status": func(r *cephReport) (interface{}, error) {
+		return struct{}{}, nil
+	},
+	"features": func(r *cephReport) (interface{}, error) {
+		return struct{}{}, nil
+	},
+	"df": (*cephReport).df,
+	"osd_dump": func(r *cephReport) (interface{}, error) {
+		return r.OSDMap, nil
+	},
+	"osd_pool_ls_detail": func(r *cephReport) (interface{}, error) {
+		osdMap := struct {
+			Pools json.RawMessage `json:"pools"`
+		}{}
+		if err := json.Unmarshal(r.OSDMap, &osdMap); err != nil {
+			return nil, err
+		}
+
+		return osdMap.Pools, nil
+	},
+	"osd_metadata": func(r *cephReport) (interface{}, error) {
+		return r.OSDMetadata, nil
+	},
+	"osd_crush_rule_dump": func(r *cephReport) (interface{}, error) {
+		crushMap := struct {
+			Rules json.RawMessage `json:"rules"`
+		}{}
+		if err := json.Unmarshal(r.CrushMap, &crushMap); err != nil {
+			return nil, err
+		}
+
+		return crushMap.Rules, nil
+	},
+	"osd_crush_dump": func(r *cephReport) (interface{}, error) {
+		return r.CrushMap, nil
+	},
+	"fs_dump": func(r *cephReport) (interface{}, error) {
+		return r.FSMap, nil
+	},
+	"pg_dump_pools": func(r *cephReport) (interface{}, error) {
+		return map[string]json.RawMessage{
+			"pool_stats": r.PGMap.PoolStats,
+		}, nil
+	},
+}
+
+// MonCommand answers the command from the report.
+func (r *ReportConn) MonCommand(args []byte) ([]byte, string, error) {
+	return r.respond(fixtureNames(args))
+}
+
+// MgrCommand answers the command from the report.
+func (r *ReportConn) MgrCommand(args [][]byte) ([]byte, string, error) {
+	return r.respond(fixtureNames(firstArg(args)))
+}
+
+// OsdCommand always fails, OSD daemons' state isn't part of the report.
+func (r *ReportConn) OsdCommand(osd int, args [][]byte) ([]byte, string, error) {
+	return nil, "", fmt.Errorf("OSD commands can't be answered from ceph report %s", r.path)
+}
+
+// GetPoolStats returns the stats of the pool from its pgmap stats.
+func (r *ReportConn) GetPoolStats(pool string) (*PoolStat, error) {
+	st, err := r.report.poolStat(pool)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pool stats from ceph report %s: %s", r.path, err)
+	}
+
+	return st, nil
+}
+
+// respond builds the response for the first of the named commands that can
+// be answered from the report.
+func (r *ReportConn) respond(names []string) ([]byte, string, error) {
+	for _, name := range names {
+		response, ok := reportResponses[name]
+		if !ok {
+			continue
+		}
+
+		v, err := response(&r.report)
+		if err != nil {
+			return nil, "", err
+		}
+
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return buf, "", nil
+	}
+
+	return nil, "", fmt.Errorf("%s can't be answered from ceph report %s", names[len(names)-1], r.path)
+}
@@ -0,0 +1,96 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportConn(t *testing.T) {
+	conn, err := NewReportConn("testdata/report.json")
+	require.NoError(t, err)
+
+	buf, _, err := conn.MonCommand([]byte(`{"prefix":"version","format":"json"}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"version":"ceph version 16.2.11 (3cf40e2dca667f68c6ce3ff5cd94f01e711af894) pacific (stable)"}`, string(buf))
+
+	buf, _, err = conn.MgrCommand([][]byte{[]byte(`{"prefix":"pg dump","dumpcontents":["pools"],"format":"json"}`)})
+	require.NoError(t, err)
+	require.Contains(t, string(buf), `"pool_stats":[{"poolid":1`)
+
+	st, err := conn.GetPoolStats("rbd")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), st.ObjectsUnfound)
+
+	_, err = conn.GetPoolStats("missing")
+	require.Error(t, err)
+
+	_, _, err = conn.MonCommand([]byte(`{"prefix":"osd tree","format":"json"}`))
+	require.EqualError(t, err, "osd_tree can't be answered from ceph report testdata/report.json")
+
+	_, err = NewReportConn("testdata/missing.json")
+	require.Error(t, err)
+}
+
+func TestExporterReportBackend(t *testing.T) {
+	conn, err := NewReportConn("testdata/report.json")
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	e, err := NewExporter(conn, "ceph", ExporterOptions{User: "admin"}, logger)
+	require.NoError(t, err)
+	defer e.Close()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(e))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`ceph_health_status{cluster="ceph"} 1`),
+		regexp.MustCompile(`ceph_monitor_quorum_count{cluster="ceph"} 3`),
+		regexp.MustCompile(`ceph_mon_in_quorum{cluster="ceph",monitor="c"} 1`),
+		regexp.MustCompile(`ceph_mons_total{cluster="ceph"} 3`),
+		regexp.MustCompile(`ceph_pool_pg_num{cluster="ceph",pool="rbd",profile="replicated",root="default"} 32`),
+		regexp.MustCompile(`ceph_pool_num_objects_degraded{cluster="ceph",pool="rbd"} 1200`),
+		regexp.MustCompile(`ceph_osd_up{[^}]*osd="osd.1"[^}]*} 0`),
+		regexp.MustCompile(`ceph_mds_standby_count{cluster="ceph",fs="cephfs"} 1`),
+		regexp.MustCompile(`ceph_cluster_capacity_bytes{cluster="ceph"} 5.997204946944e\+12`),
+		regexp.MustCompile(`ceph_pool_used_bytes{cluster="ceph",pool="rbd"} 5.0331648e\+09`),
+		regexp.MustCompile(`ceph_pool_unfound_objects_total{cluster="ceph",pool="rbd"} 3`),
+		regexp.MustCompile(`ceph_osd_version{cluster="ceph",version="16.2.11"} 3`),
+	} {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+}
@@ -140,12 +140,6 @@ func NewRGWCollector(exporter *Exporter, background bool) *RGWCollector {
 		),
 	}
 
-	if rgw.background {
-		// rgw stats need to be collected in the background as this can take a while
-		// if we have a large backlog
-		go rgw.backgroundCollect()
-	}
-
 	return rgw
 }
 
@@ -158,14 +152,32 @@ func (r *RGWCollector) collectorList() []prometheus.Collector {
 	}
 }
 
-func (r *RGWCollector) backgroundCollect() error {
+// collectBackground collects the stats between scrapes in background mode,
+// through radosgw-admin rather than conn.
+func (r *RGWCollector) collectBackground(conn Conn, stop <-chan struct{}) {
+	if r.background {
+		// rgw stats need to be collected in the background as this can take a while
+		// if we have a large backlog
+		r.backgroundCollect(stop)
+	}
+}
+
+func (r *RGWCollector) backgroundCollect(stop <-chan struct{}) {
+	ticker := time.NewTicker(backgroundCollectInterval)
+	defer ticker.Stop()
+
 	for {
 		r.logger.WithField("background", r.background).Debug("collecting RGW GC stats")
 		err := r.collect()
 		if err != nil {
 			r.logger.WithField("background", r.background).WithError(err).Error("error collecting RGW GC stats")
 		}
-		time.Sleep(backgroundCollectInterval)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -217,10 +229,13 @@ func (r *RGWCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect sends all the collected metrics to the provided prometheus channel.
 // It requires the caller to handle synchronization.
-func (r *RGWCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
+func (r *RGWCollector) Collect(ch chan<- prometheus.Metric, version *Version) error {
+	// In background mode the stats are collected by collectBackground, its
+	// failures don't fail the scrape.
+	var err error
 	if !r.background {
 		r.logger.WithField("background", r.background).Debug("collecting RGW GC stats")
-		err := r.collect()
+		err = r.collect()
 		if err != nil {
 			r.logger.WithField("background", r.background).WithError(err).Error("error collecting RGW GC stats")
 		}
@@ -229,4 +244,6 @@ func (r *RGWCollector) Collect(ch chan<- prometheus.Metric, version *Version) {
 	for _, metric := range r.collectorList() {
 		metric.Collect(ch)
 	}
+
+	return err
 }
@@ -138,7 +138,7 @@ func TestRGWCollector(t *testing.T) {
 		func() {
 			conn := setupVersionMocks(tt.version, "{}")
 
-			e := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()}
+			e := newExporter(conn, "ceph", ExporterOptions{}, logrus.New())
 			e.cc = map[string]versionedCollector{
 				"rgw": NewRGWCollector(e, false),
 			}
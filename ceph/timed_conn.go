@@ -0,0 +1,149 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TimedConn wraps a Conn and records how long each mon, mgr and osd command
+// takes, labelled by the daemon type and the command's prefix. Durations go
+// into a histogram when enabled, and otherwise into a gauge that only holds
+// the duration of the last command, which is much cheaper to store.
+type TimedConn struct {
+	Conn
+
+	// since is time.Since, swapped out in tests.
+	since func(time.Time) time.Duration
+
+	// CommandDuration is only set when histograms are enabled.
+	CommandDuration *prometheus.HistogramVec
+
+	// CommandLastDuration is only set when histograms are disabled.
+	CommandLastDuration *prometheus.GaugeVec
+}
+
+// NewTimedConn wraps conn to time its commands. If histogram is set the
+// durations are observed into buckets, which default to
+// prometheus.DefBuckets when none are given.
+func NewTimedConn(conn Conn, cluster string, histogram bool, buckets []float64) *TimedConn {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = cluster
+
+	t := &TimedConn{
+		Conn:  conn,
+		since: time.Since,
+	}
+
+	if histogram {
+		if len(buckets) == 0 {
+			buckets = prometheus.DefBuckets
+		}
+
+		t.CommandDuration = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   "exporter",
+				Name:        "command_duration_seconds",
+				Help:        "Time taken by commands sent to the cluster",
+				ConstLabels: labels,
+				Buckets:     buckets,
+			},
+			[]string{"daemon", "command"},
+		)
+	} else {
+		t.CommandLastDuration = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   cephNamespace,
+				Subsystem:   "exporter",
+				Name:        "command_last_duration_seconds",
+				Help:        "Time taken by the last command of its kind sent to the cluster",
+				ConstLabels: labels,
+			},
+			[]string{"daemon", "command"},
+		)
+	}
+
+	return t
+}
+
+// MonCommand times the command and passes it on to the wrapped Conn.
+func (t *TimedConn) MonCommand(args []byte) ([]byte, string, error) {
+	defer t.observe("mon", args, time.Now())
+	return t.Conn.MonCommand(args)
+}
+
+// MgrCommand times the command and passes it on to the wrapped Conn.
+func (t *TimedConn) MgrCommand(args [][]byte) ([]byte, string, error) {
+	defer t.observe("mgr", firstArg(args), time.Now())
+	return t.Conn.MgrCommand(args)
+}
+
+// OsdCommand times the command and passes it on to the wrapped Conn.
+func (t *TimedConn) OsdCommand(osd int, args [][]byte) ([]byte, string, error) {
+	defer t.observe("osd", firstArg(args), time.Now())
+	return t.Conn.OsdCommand(osd, args)
+}
+
+func (t *TimedConn) observe(daemon string, args []byte, start time.Time) {
+	seconds := t.since(start).Seconds()
+	command := commandPrefix(args)
+
+	if t.CommandDuration != nil {
+		t.CommandDuration.WithLabelValues(daemon, command).Observe(seconds)
+	} else {
+		t.CommandLastDuration.WithLabelValues(daemon, command).Set(seconds)
+	}
+}
+
+func firstArg(args [][]byte) []byte {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
+
+// commandPrefix returns the prefix of a JSON encoded command, e.g. "osd tree",
+// which keeps the command label bounded unlike the full arguments.
+func commandPrefix(args []byte) string {
+	cmd := struct {
+		Prefix string `json:"prefix"`
+	}{}
+	if err := json.Unmarshal(args, &cmd); err != nil || cmd.Prefix == "" {
+		return "unknown"
+	}
+	return cmd.Prefix
+}
+
+func (t *TimedConn) collector() prometheus.Collector {
+	if t.CommandDuration != nil {
+		return t.CommandDuration
+	}
+	return t.CommandLastDuration
+}
+
+// Describe fulfills the prometheus.Collector's interface and sends the
+// descriptor of the command duration metric to the given channel.
+func (t *TimedConn) Describe(ch chan<- *prometheus.Desc) {
+	t.collector().Describe(ch)
+}
+
+// Collect sends the command durations recorded so far to the given channel.
+func (t *TimedConn) Collect(ch chan<- prometheus.Metric) {
+	t.collector().Collect(ch)
+}
@@ -0,0 +1,114 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimedConn(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		histogram bool
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:      "histogram",
+			histogram: true,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_bucket{cluster="ceph",command="osd tree",daemon="mon",le="0.1"} 1`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_bucket{cluster="ceph",command="osd tree",daemon="mon",le="0.5"} 3`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_bucket{cluster="ceph",command="osd tree",daemon="mon",le="1"} 3`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_bucket{cluster="ceph",command="osd tree",daemon="mon",le="5"} 4`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_bucket{cluster="ceph",command="osd tree",daemon="mon",le="\+Inf"} 4`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_count{cluster="ceph",command="osd tree",daemon="mon"} 4`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_sum{cluster="ceph",command="osd tree",daemon="mon"} 3.45`),
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds_count{cluster="ceph",command="pg dump",daemon="mgr"} 1`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_command_last_duration_seconds`),
+			},
+		},
+		{
+			name:      "last duration",
+			histogram: false,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_command_last_duration_seconds{cluster="ceph",command="osd tree",daemon="mon"} 3`),
+				regexp.MustCompile(`ceph_exporter_command_last_duration_seconds{cluster="ceph",command="pg dump",daemon="mgr"} 0.3`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_command_duration_seconds`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.Anything).Return([]byte("{}"), "", nil)
+			conn.On("MgrCommand", mock.Anything).Return([]byte("{}"), "", nil)
+
+			durations := []time.Duration{
+				50 * time.Millisecond,
+				200 * time.Millisecond,
+				200 * time.Millisecond,
+				3 * time.Second,
+				300 * time.Millisecond,
+			}
+			timed := NewTimedConn(conn, "ceph", tt.histogram, []float64{0.1, 0.5, 1, 5})
+			timed.since = func(time.Time) time.Duration {
+				d := durations[0]
+				durations = durations[1:]
+				return d
+			}
+
+			for i := 0; i < 4; i++ {
+				_, _, err := timed.MonCommand([]byte(`{"prefix":"osd tree","format":"json"}`))
+				require.NoError(t, err)
+			}
+			_, _, err := timed.MgrCommand([][]byte{[]byte(`{"prefix":"pg dump","dumpcontents":["pgs_brief"],"format":"json"}`)})
+			require.NoError(t, err)
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(timed))
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), "expected %s to match", re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), "expected %s not to match", re.String())
+			}
+		})
+	}
+}
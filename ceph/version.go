@@ -35,6 +35,12 @@ var (
 	// ErrInvalidVersion indicates that the given version string was invalid
 	ErrInvalidVersion = errors.New("invalid version")
 
+	// Luminous is the *Version at which Ceph luminous was released
+	Luminous = &Version{Major: 12, Minor: 2, Patch: 0, Revision: 0, Commit: ""}
+
+	// Mimic is the *Version at which Ceph mimic was released
+	Mimic = &Version{Major: 13, Minor: 2, Patch: 0, Revision: 0, Commit: ""}
+
 	// Nautilus is the *Version at which Ceph nautilus was released
 	Nautilus = &Version{Major: 14, Minor: 2, Patch: 0, Revision: 0, Commit: ""}
 
@@ -76,6 +82,28 @@ func (version *Version) IsAtLeast(constraint *Version) bool {
 	return true
 }
 
+// releaseNames maps major versions to the release codenames Ceph uses.
+var releaseNames = map[int]string{
+	12: "luminous",
+	13: "mimic",
+	14: "nautilus",
+	15: "octopus",
+	16: "pacific",
+	17: "quincy",
+	18: "reef",
+	19: "squid",
+}
+
+// Release returns the release codename of the version, e.g. "pacific", or
+// "unknown" for major versions it doesn't know about.
+func (version *Version) Release() string {
+	if name, ok := releaseNames[version.Major]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
 func (version *Version) String() string {
 	str := fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
 	if version.Revision != 0 || version.Commit != "" {
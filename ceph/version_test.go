@@ -149,3 +149,24 @@ func TestVersion_IsAtLeast(t *testing.T) {
 		})
 	}
 }
+
+func TestVersion_Release(t *testing.T) {
+	tests := []struct {
+		name    string
+		version *Version
+		want    string
+	}{
+		{name: "nautilus", version: Nautilus, want: "nautilus"},
+		{name: "octopus", version: Octopus, want: "octopus"},
+		{name: "pacific", version: &Version{Major: 16, Minor: 2, Patch: 11, Revision: 22, Commit: "wasd"}, want: "pacific"},
+		{name: "reef", version: &Version{Major: 18, Minor: 2, Patch: 0}, want: "reef"},
+		{name: "unknown", version: &Version{Major: 99}, want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.version.Release(); got != tt.want {
+				t.Errorf("Release() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
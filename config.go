@@ -15,21 +15,153 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v2"
+
+	"github.com/digitalocean/ceph_exporter/ceph"
 )
 
 type ClusterConfig struct {
 	ClusterLabel string `yaml:"cluster_label"`
 	User         string `yaml:"user"`
 	ConfigFile   string `yaml:"config_file"`
+	KeyFile      string `yaml:"key_file"`
+
+	// OSDDeviceClassAllowlist limits the OSD metrics to OSDs of these device
+	// classes, e.g. only ssd. It defaults to OSD_DEVICE_CLASS_ALLOWLIST.
+	OSDDeviceClassAllowlist []string `yaml:"osd_device_class_allowlist"`
+
+	// HealthCheckSeverity overrides the criticality health_status_interp
+	// gives to health checks, e.g. OSD_DOWN: 2. A check set to 0 is ignored.
+	HealthCheckSeverity map[string]int `yaml:"health_check_severity"`
+
+	// OSDLabelRelabel rewrites the host, rack or root labels of the OSD
+	// metrics taken from the CRUSH map, in order, e.g. to strip the domain
+	// off host names so that they match node_exporter's instance label.
+	OSDLabelRelabel []RelabelConfig `yaml:"osd_label_relabel"`
+}
+
+// RelabelConfig replaces the value of an OSD label that Regex matches in full
+// with Replacement, like Prometheus relabelling does. Replacement may refer to
+// the capture groups of Regex and defaults to $1.
+type RelabelConfig struct {
+	Label       string `yaml:"label"`
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+// osdLabelRelabels compiles the OSD label relabelling of the cluster.
+func (c *ClusterConfig) osdLabelRelabels() ([]ceph.OSDLabelRelabel, error) {
+	var relabels []ceph.OSDLabelRelabel
+	for _, r := range c.OSDLabelRelabel {
+		switch r.Label {
+		case "host", "rack", "root":
+		default:
+			return nil, fmt.Errorf("invalid osd label %q, must be one of host, rack or root", r.Label)
+		}
+
+		re, err := regexp.Compile("^(?:" + r.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for osd label %q: %s", r.Label, err)
+		}
+
+		replacement := r.Replacement
+		if replacement == "" {
+			replacement = "$1"
+		}
+
+		relabels = append(relabels, ceph.OSDLabelRelabel{
+			Label:       r.Label,
+			Regex:       re,
+			Replacement: replacement,
+		})
+	}
+
+	return relabels, nil
+}
+
+// Validate checks that the files the cluster config points at exist and are
+// readable, so a bad path is reported clearly rather than as a rados error.
+func (c *ClusterConfig) Validate() error {
+	if err := checkReadable(c.ConfigFile); err != nil {
+		return fmt.Errorf("ceph config file is not readable: %s", err)
+	}
+
+	if c.KeyFile != "" {
+		if err := checkReadable(c.KeyFile); err != nil {
+			return fmt.Errorf("ceph key file is not readable: %s", err)
+		}
+	}
+
+	return nil
 }
 
 // Config is the top-level configuration for Metastord.
 type Config struct {
 	Cluster []*ClusterConfig
+
+	// ResponseHeaders are static headers set on every response of the
+	// metrics endpoint, e.g. Cache-Control for a caching proxy in front.
+	ResponseHeaders map[string]string `yaml:"response_headers"`
+
+	// MetricAllowlist and MetricDenylist are glob patterns matched against
+	// the full name of every metric, e.g. ceph_osd_*. If the allowlist is
+	// set only matching metrics are exported, and denied metrics never are.
+	MetricAllowlist []string `yaml:"metric_allowlist"`
+	MetricDenylist  []string `yaml:"metric_denylist"`
+}
+
+// Validate checks that the response headers are valid HTTP header fields,
+// that the metric allow and deny lists are valid glob patterns and that the
+// OSD label relabelling of each cluster compiles.
+func (c *Config) Validate() error {
+	for _, cluster := range c.Cluster {
+		if _, err := cluster.osdLabelRelabels(); err != nil {
+			return fmt.Errorf("cluster %s: %s", cluster.ClusterLabel, err)
+		}
+	}
+
+	for _, pattern := range append(append([]string(nil), c.MetricAllowlist...), c.MetricDenylist...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid metric pattern %q: %s", pattern, err)
+		}
+	}
+
+	for name, value := range c.ResponseHeaders {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid response header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for response header %q", name)
+		}
+	}
+
+	return nil
+}
+
+// validHeaderName returns true if name is a non-empty HTTP token (RFC 7230).
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+
+	return true
 }
 
 // fileExists returns true if the path exists and is a file.
@@ -38,6 +170,42 @@ func fileExists(path string) bool {
 	return !os.IsNotExist(err) && !stat.IsDir()
 }
 
+// clusterLabelFromConfigFile derives the cluster name from the name of the
+// Ceph config file, the same way the ceph CLI does (/etc/ceph/prod.conf is
+// cluster "prod"). It falls back to the default label for other file names.
+func clusterLabelFromConfigFile(path string) string {
+	name := filepath.Base(path)
+	if !strings.HasSuffix(name, ".conf") {
+		return defaultCephClusterLabel
+	}
+
+	if name = strings.TrimSuffix(name, ".conf"); name == "" {
+		return defaultCephClusterLabel
+	}
+
+	return name
+}
+
+// checkReadable returns an error if the file at path can't be opened for
+// reading or is a directory.
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	return nil
+}
+
 func ParseConfig(p string) (*Config, error) {
 	cfgData, err := ioutil.ReadFile(p)
 	if err != nil {
@@ -50,5 +218,9 @@ func ParseConfig(p string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
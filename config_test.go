@@ -0,0 +1,230 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterLabelFromConfigFile(t *testing.T) {
+	for _, tt := range []struct {
+		path   string
+		expect string
+	}{
+		{path: "/etc/ceph/ceph.conf", expect: "ceph"},
+		{path: "/etc/ceph/prod.conf", expect: "prod"},
+		{path: "staging-east.conf", expect: "staging-east"},
+		{path: "/etc/ceph/.conf", expect: "ceph"},
+		{path: "/etc/ceph/prod.cfg", expect: "ceph"},
+		{path: "", expect: "ceph"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.expect, clusterLabelFromConfigFile(tt.path))
+		})
+	}
+}
+
+func TestClusterConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+
+	confPath := filepath.Join(dir, "ceph.conf")
+	require.NoError(t, ioutil.WriteFile(confPath, []byte("[global]\n"), 0600))
+
+	keyPath := filepath.Join(dir, "ceph.key")
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte("AQBkey==\n"), 0600))
+
+	for _, tt := range []struct {
+		name    string
+		cluster ClusterConfig
+		err     string
+	}{
+		{
+			name:    "readable",
+			cluster: ClusterConfig{ConfigFile: confPath},
+		},
+		{
+			name:    "readable with key file",
+			cluster: ClusterConfig{ConfigFile: confPath, KeyFile: keyPath},
+		},
+		{
+			name:    "missing ceph.conf",
+			cluster: ClusterConfig{ConfigFile: filepath.Join(dir, "missing.conf")},
+			err:     "ceph config file is not readable",
+		},
+		{
+			name:    "ceph.conf is a directory",
+			cluster: ClusterConfig{ConfigFile: dir},
+			err:     "ceph config file is not readable",
+		},
+		{
+			name:    "missing key file",
+			cluster: ClusterConfig{ConfigFile: confPath, KeyFile: filepath.Join(dir, "missing.key")},
+			err:     "ceph key file is not readable",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cluster.Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfigValidateResponseHeaders(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		headers map[string]string
+		err     string
+	}{
+		{
+			name: "valid",
+			headers: map[string]string{
+				"Cache-Control":             "no-store",
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+				"X-Custom_Header":           "",
+			},
+		},
+		{
+			name:    "space in name",
+			headers: map[string]string{"Cache Control": "no-store"},
+			err:     `invalid response header name "Cache Control"`,
+		},
+		{
+			name:    "colon in name",
+			headers: map[string]string{"X-Foo:": "bar"},
+			err:     `invalid response header name "X-Foo:"`,
+		},
+		{
+			name:    "empty name",
+			headers: map[string]string{"": "bar"},
+			err:     `invalid response header name ""`,
+		},
+		{
+			name:    "newline in value",
+			headers: map[string]string{"X-Foo": "bar\r\nSet-Cookie: x"},
+			err:     `invalid value for response header "X-Foo"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Config{ResponseHeaders: tt.headers}).Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseConfigResponseHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+cluster:
+  - cluster_label: ceph
+    user: admin
+    config_file: /etc/ceph/ceph.conf
+response_headers:
+  Cache-Control: no-store
+`), 0600))
+
+	cfg, err := ParseConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"Cache-Control": "no-store"}, cfg.ResponseHeaders)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+response_headers:
+  "Cache Control": no-store
+`), 0600))
+
+	_, err = ParseConfig(path)
+	require.EqualError(t, err, `invalid response header name "Cache Control"`)
+}
+
+func TestParseConfigMetricLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+metric_allowlist:
+  - ceph_health_*
+metric_denylist:
+  - ceph_health_status_interp
+`), 0600))
+
+	cfg, err := ParseConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ceph_health_*"}, cfg.MetricAllowlist)
+	require.Equal(t, []string{"ceph_health_status_interp"}, cfg.MetricDenylist)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+metric_denylist:
+  - ceph_osd_[
+`), 0600))
+
+	_, err = ParseConfig(path)
+	require.EqualError(t, err, `invalid metric pattern "ceph_osd_[": syntax error in pattern`)
+}
+
+func TestParseConfigOSDLabelRelabel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+cluster:
+  - cluster_label: ceph
+    osd_label_relabel:
+      - label: host
+        regex: (.*)\.example\.com
+`), 0600))
+
+	cfg, err := ParseConfig(path)
+	require.NoError(t, err)
+
+	relabels, err := cfg.Cluster[0].osdLabelRelabels()
+	require.NoError(t, err)
+	require.Len(t, relabels, 1)
+	require.Equal(t, "host", relabels[0].Label)
+	require.Equal(t, "$1", relabels[0].Replacement)
+	require.Equal(t, "ceph-node01", relabels[0].Regex.ReplaceAllString("ceph-node01.example.com", relabels[0].Replacement))
+	// the regex has to match the whole label
+	require.False(t, relabels[0].Regex.MatchString("ceph-node01.example.com.au"))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+cluster:
+  - cluster_label: ceph
+    osd_label_relabel:
+      - label: osd
+        regex: osd\.(.*)
+`), 0600))
+
+	_, err = ParseConfig(path)
+	require.EqualError(t, err, `cluster ceph: invalid osd label "osd", must be one of host, rack or root`)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+cluster:
+  - cluster_label: ceph
+    osd_label_relabel:
+      - label: rack
+        regex: (.*
+`), 0600))
+
+	_, err = ParseConfig(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `cluster ceph: invalid regex for osd label "rack"`)
+}
@@ -17,15 +17,25 @@ package main
 
 import (
 	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ianschenck/envflag"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/sirupsen/logrus"
 
 	"github.com/digitalocean/ceph_exporter/ceph"
@@ -37,6 +47,11 @@ const (
 	defaultCephConfigPath   = "/etc/ceph/ceph.conf"
 	defaultCephUser         = "admin"
 	defaultRadosOpTimeout   = 30 * time.Second
+	defaultConnectTimeout   = 30 * time.Second
+
+	backendRados   = "rados"
+	backendFixture = "fixture"
+	backendReport  = "report"
 )
 
 // This horrible thing is a copy of tcpKeepAliveListener, tweaked to
@@ -68,25 +83,243 @@ func (ln emfileAwareTcpListener) Accept() (c net.Conn, err error) {
 // Verify that the exporter implements the interface correctly.
 var _ prometheus.Collector = &ceph.Exporter{}
 
+// newRegistry returns the registry that the exporters are registered with.
+// The Go runtime and process collectors that the default registry ships with
+// are only included if goMetrics is set.
+func newRegistry(goMetrics bool) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	if goMetrics {
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	return registry
+}
+
+// newConfigInfo returns a gauge that is always 1 and carries the exporter's
+// effective configuration as labels, so it can be checked without shell access
+// to the host the exporter runs on.
+func newConfigInfo(opts ceph.ExporterOptions, tls bool, numClusters int) prometheus.Gauge {
+	info := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ceph_exporter_config_info",
+		Help: "Effective configuration of the exporter, the value is always 1",
+		ConstLabels: prometheus.Labels{
+			"rgw_mode":      strconv.Itoa(opts.RgwMode),
+			"osd_op_queue":  strconv.FormatBool(opts.OSDOpQueue),
+			"osd_perf_dump": strconv.FormatBool(opts.OSDPerfDump),
+			"device_health": strconv.FormatBool(opts.DeviceHealth),
+			"auth_metrics":  strconv.FormatBool(opts.AuthMetrics),
+			"pg_query":      strconv.FormatBool(opts.PGQuery),
+			"tls":           strconv.FormatBool(tls),
+			"num_clusters":  strconv.Itoa(numClusters),
+		},
+	})
+	info.Set(1)
+
+	return info
+}
+
+// newStartTime returns gauges of when the exporter started and for how long it
+// has been running, so that gaps in the metrics can be matched with exporter
+// restarts, also without GO_METRICS.
+func newStartTime(start time.Time) []prometheus.Collector {
+	startTime := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ceph_exporter_start_time_seconds",
+		Help: "Unix timestamp at which the exporter started",
+	})
+	startTime.Set(float64(start.UnixNano()) / 1e9)
+
+	uptime := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ceph_exporter_uptime_seconds",
+		Help: "Seconds since the exporter started",
+	}, func() float64 {
+		return time.Since(start).Seconds()
+	})
+
+	return []prometheus.Collector{startTime, uptime}
+}
+
+// parseBuckets parses a comma separated list of histogram bucket upper bounds,
+// in seconds. An empty list returns nil so the default buckets are used.
+func parseBuckets(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var buckets []float64
+	for _, field := range strings.Split(s, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %w", field, err)
+		}
+		buckets = append(buckets, b)
+	}
+	sort.Float64s(buckets)
+
+	return buckets, nil
+}
+
+// parseList parses a comma separated list, ignoring empty entries.
+func parseList(s string) []string {
+	var list []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			list = append(list, field)
+		}
+	}
+
+	return list
+}
+
+// withHeaders sets the given static headers on every response of h.
+func withHeaders(h http.Handler, headers map[string]string) http.Handler {
+	if len(headers) == 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// metricFilter is a Gatherer that drops the metric families whose name isn't
+// allowed, so that only the metrics a user cares about end up in their TSDB.
+type metricFilter struct {
+	gatherer  prometheus.Gatherer
+	allowlist []string
+	denylist  []string
+}
+
+// newMetricFilter returns g filtered by the glob patterns of the allow and
+// deny lists, or g itself if both are empty.
+func newMetricFilter(g prometheus.Gatherer, allowlist, denylist []string) prometheus.Gatherer {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return g
+	}
+
+	return &metricFilter{gatherer: g, allowlist: allowlist, denylist: denylist}
+}
+
+// Gather implements prometheus.Gatherer.
+func (f *metricFilter) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := f.gatherer.Gather()
+
+	filtered := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		if f.allowed(mf.GetName()) {
+			filtered = append(filtered, mf)
+		}
+	}
+
+	return filtered, err
+}
+
+// allowed returns true if name matches the allowlist, if any, and doesn't
+// match the denylist.
+func (f *metricFilter) allowed(name string) bool {
+	if len(f.allowlist) > 0 && !matchAny(f.allowlist, name) {
+		return false
+	}
+
+	return !matchAny(f.denylist, name)
+}
+
+// matchAny returns true if name matches any of the glob patterns. Patterns
+// are checked when the config is parsed, so errors are ignored here.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lastScrapeErrorMetric is set by every cluster's exporter when one of its
+// collectors failed.
+const lastScrapeErrorMetric = "ceph_exporter_last_scrape_error_timestamp_seconds"
+
+// scrapeOnce gathers the metrics of the registry once and writes those
+// allowed by the allow and deny lists to w in the Prometheus text format. It
+// reports whether any collector ran into an error while doing so, whether or
+// not the lists let the last scrape error metric through.
+func scrapeOnce(g prometheus.Gatherer, allowlist, denylist []string, w io.Writer) (bool, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return false, err
+	}
+
+	filter := &metricFilter{allowlist: allowlist, denylist: denylist}
+
+	failed := false
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, mf := range mfs {
+		if mf.GetName() == lastScrapeErrorMetric {
+			for _, m := range mf.GetMetric() {
+				if m.GetGauge().GetValue() > 0 {
+					failed = true
+				}
+			}
+		}
+
+		if !filter.allowed(mf.GetName()) {
+			continue
+		}
+		if err := enc.Encode(mf); err != nil {
+			return false, err
+		}
+	}
+
+	return failed, nil
+}
+
 func main() {
+	start := time.Now()
+
 	var (
 		metricsAddr    = envflag.String("TELEMETRY_ADDR", ":9128", "Host:Port for ceph_exporter's metrics endpoint")
 		metricsPath    = envflag.String("TELEMETRY_PATH", "/metrics", "URL path for surfacing metrics to Prometheus")
 		exporterConfig = envflag.String("EXPORTER_CONFIG", "/etc/ceph/exporter.yml", "Path to ceph_exporter config")
 		rgwMode        = envflag.Int("RGW_MODE", 0, "Enable collection of stats from RGW (0:disabled 1:enabled 2:background)")
+		goMetrics      = envflag.Bool("GO_METRICS", true, "Expose the exporter's own Go runtime and process metrics")
+		osdOpQueue     = envflag.Bool("OSD_OP_QUEUE", false, "Query each OSD daemon for the number of ops in progress, in flight and slow (two commands per OSD per scrape, the perf dump shared with OSD_PERF_DUMP)")
+		osdPerfDump    = envflag.Bool("OSD_PERF_DUMP", false, "Query each OSD daemon for its op read and write latencies (one command per OSD per scrape, shared with OSD_OP_QUEUE)")
+		deviceHealth   = envflag.Bool("DEVICE_HEALTH_METRICS", false, "Collect device life expectancy and SMART wear level from the devicehealth mgr module (one command per device per scrape)")
+		authMetrics    = envflag.Bool("AUTH_METRICS", false, "Count the entities of the auth database (lists the whole database with `ceph auth ls` every scrape)")
+		pgQuery        = envflag.Bool("PG_QUERY", false, "Query each backfilling PG for the objects it backfilled to each OSD (one command per backfilling PG per scrape)")
+
+		osdDeviceClasses = envflag.String("OSD_DEVICE_CLASS_ALLOWLIST", "", "Comma separated OSD device classes to report OSD metrics for, e.g. ssd (defaults to all)")
+
+		commandHistogram = envflag.Bool("COMMAND_DURATION_HISTOGRAM", false, "Record Ceph command durations in a histogram instead of a last duration gauge")
+		commandBuckets   = envflag.String("COMMAND_DURATION_BUCKETS", "", "Comma separated histogram buckets in seconds for Ceph command durations (defaults to the Prometheus default buckets)")
 
 		logLevel = envflag.String("LOG_LEVEL", "info", "Logging level. One of: [trace, debug, info, warn, error, fatal, panic]")
 
-		cephCluster        = envflag.String("CEPH_CLUSTER", defaultCephClusterLabel, "Ceph cluster name")
+		cephCluster        = envflag.String("CEPH_CLUSTER", "", "Ceph cluster name (derived from the CEPH_CONFIG file name if unset)")
 		cephConfig         = envflag.String("CEPH_CONFIG", defaultCephConfigPath, "Path to Ceph config file")
 		cephUser           = envflag.String("CEPH_USER", defaultCephUser, "Ceph user to connect to cluster")
+		cephKeyFile        = envflag.String("CEPH_KEY_FILE", "", "Path to a file containing the Ceph user's key, re-read when it changes")
 		cephRadosOpTimeout = envflag.Duration("CEPH_RADOS_OP_TIMEOUT", defaultRadosOpTimeout, "Ceph rados_osd_op_timeout and rados_mon_op_timeout used to contact cluster (0s means no limit)")
+		cephConnectTimeout = envflag.Duration("CEPH_CONNECT_TIMEOUT", defaultConnectTimeout, "Timeout for connecting to the cluster, at startup and when reconnecting (0s means no limit)")
+
+		cephBackend    = envflag.String("CEPH_BACKEND", backendRados, "Backend used to talk to the cluster. One of: [rados, fixture, report]")
+		cephFixtureDir = envflag.String("CEPH_FIXTURE_DIR", "", "Directory of recorded command responses read by the fixture backend")
+		cephReportFile = envflag.String("CEPH_REPORT_FILE", "", "Path to the saved output of `ceph report` read by the report backend")
 
 		tlsCertPath = envflag.String("TLS_CERT_FILE_PATH", "", "Path to certificate file for TLS")
 		tlsKeyPath  = envflag.String("TLS_KEY_FILE_PATH", "", "Path to key file for TLS")
 	)
 
+	once := flag.Bool("once", false, "Scrape the clusters once, write the metrics to stdout and exit, non-zero if any collector failed")
+
 	envflag.Parse()
+	flag.Parse()
 
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
@@ -99,7 +332,17 @@ func main() {
 		logger.SetLevel(v)
 	}
 
+	registry := newRegistry(*goMetrics)
+	registry.MustRegister(newStartTime(start)...)
+
+	buckets, err := parseBuckets(*commandBuckets)
+	if err != nil {
+		logger.WithError(err).Fatal("error parsing COMMAND_DURATION_BUCKETS")
+	}
+
 	clusterConfigs := ([]*ClusterConfig)(nil)
+	responseHeaders := map[string]string(nil)
+	metricAllowlist, metricDenylist := []string(nil), []string(nil)
 
 	if fileExists(*exporterConfig) {
 		cfg, err := ParseConfig(*exporterConfig)
@@ -109,39 +352,143 @@ func main() {
 			).Fatal("error parsing ceph_exporter config file")
 		}
 		clusterConfigs = cfg.Cluster
+		responseHeaders = cfg.ResponseHeaders
+		metricAllowlist, metricDenylist = cfg.MetricAllowlist, cfg.MetricDenylist
 	} else {
+		if *cephCluster == "" {
+			*cephCluster = clusterLabelFromConfigFile(*cephConfig)
+		}
+
 		clusterConfigs = []*ClusterConfig{
 			{
 				ClusterLabel: *cephCluster,
 				User:         *cephUser,
 				ConfigFile:   *cephConfig,
+				KeyFile:      *cephKeyFile,
 			},
 		}
 	}
 
+	configReadable := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ceph_exporter_config_readable",
+			Help: "Whether the cluster's Ceph config and key files were readable at startup",
+		},
+		[]string{"cluster"},
+	)
+	registry.MustRegister(configReadable)
+
+	if *cephBackend != backendRados && *cephBackend != backendFixture && *cephBackend != backendReport {
+		logger.WithField("backend", *cephBackend).Fatal("unknown CEPH_BACKEND")
+	}
+
+	if *cephBackend == backendRados {
+		registry.MustRegister(rados.ConnectTimeouts)
+	}
+
+	// options are shared by all clusters, the per-cluster settings are filled
+	// in below.
+	options := ceph.ExporterOptions{
+		RgwMode:      *rgwMode,
+		OSDOpQueue:   *osdOpQueue,
+		OSDPerfDump:  *osdPerfDump,
+		DeviceHealth: *deviceHealth,
+		AuthMetrics:  *authMetrics,
+		PGQuery:      *pgQuery,
+	}
+
+	exported := 0
 	for _, cluster := range clusterConfigs {
-		conn, err := rados.NewRadosConn(
-			cluster.User,
-			cluster.ConfigFile,
-			*cephRadosOpTimeout,
-			logger)
+		if *cephBackend == backendRados {
+			if err := cluster.Validate(); err != nil {
+				configReadable.WithLabelValues(cluster.ClusterLabel).Set(0)
+				logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Warn("skipping cluster with unreadable config")
+				continue
+			}
+		}
+		configReadable.WithLabelValues(cluster.ClusterLabel).Set(1)
+
+		var conn ceph.Conn
+		switch *cephBackend {
+		case backendFixture:
+			conn = ceph.NewFixtureConn(*cephFixtureDir)
+		case backendReport:
+			conn, err = ceph.NewReportConn(*cephReportFile)
+			if err != nil {
+				logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Fatal("unable to read ceph report for cluster")
+			}
+		default:
+			conn, err = rados.NewRadosConn(
+				cluster.ClusterLabel,
+				cluster.User,
+				cluster.ConfigFile,
+				cluster.KeyFile,
+				*cephRadosOpTimeout,
+				*cephConnectTimeout,
+				logger)
+
+			if err != nil {
+				logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Fatal("unable to create rados connection for cluster")
+			}
+		}
+
+		deviceClasses := cluster.OSDDeviceClassAllowlist
+		if len(deviceClasses) == 0 {
+			deviceClasses = parseList(*osdDeviceClasses)
+		}
+
+		relabels, err := cluster.osdLabelRelabels()
+		if err != nil {
+			logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Fatal("invalid osd_label_relabel for cluster")
+		}
+
+		timedConn := ceph.NewTimedConn(conn, cluster.ClusterLabel, *commandHistogram, buckets)
+		registry.MustRegister(timedConn)
+
+		opts := options
+		opts.Config = cluster.ConfigFile
+		opts.User = cluster.User
+		opts.OSDDeviceClassAllowlist = deviceClasses
+		opts.HealthCheckSeverity = cluster.HealthCheckSeverity
+		opts.OSDLabelRelabels = relabels
 
+		exporter, err := ceph.NewExporter(timedConn, cluster.ClusterLabel, opts, logger)
 		if err != nil {
-			logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Fatal("unable to create rados connection for cluster")
+			logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Fatal("unable to create exporter for cluster")
 		}
 
-		prometheus.MustRegister(ceph.NewExporter(
-			conn,
-			cluster.ClusterLabel,
-			cluster.ConfigFile,
-			cluster.User,
-			*rgwMode,
-			logger))
+		// Register rather than MustRegister, so that metrics conflicting with
+		// another cluster's are reported instead of panicking.
+		if err := registry.Register(exporter); err != nil {
+			logger.WithError(err).WithField("cluster", cluster.ClusterLabel).Fatal("unable to register exporter for cluster")
+		}
 
 		logger.WithField("cluster", cluster.ClusterLabel).Info("exporting cluster")
+		exported++
+	}
+
+	if exported == 0 {
+		logger.Fatal("no clusters to export, check that the Ceph config files are readable")
+	}
+
+	useTLS := len(*tlsCertPath) != 0 && len(*tlsKeyPath) != 0
+	registry.MustRegister(newConfigInfo(options, useTLS, exported))
+
+	if *once {
+		failed, err := scrapeOnce(registry, metricAllowlist, metricDenylist, os.Stdout)
+		if err != nil {
+			logger.WithError(err).Fatal("error gathering metrics")
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
 	}
 
-	http.Handle(*metricsPath, promhttp.Handler())
+	gatherer := newMetricFilter(registry, metricAllowlist, metricDenylist)
+	http.Handle(*metricsPath, withHeaders(promhttp.InstrumentMetricHandler(
+		registry, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+	), responseHeaders))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>Ceph Exporter</title></head>
@@ -161,7 +508,7 @@ func main() {
 		logrus.WithError(err).Fatal("error creating listener")
 	}
 
-	if len(*tlsCertPath) != 0 && len(*tlsKeyPath) != 0 {
+	if useTLS {
 		server := &http.Server{
 			TLSConfig: &tls.Config{
 				GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
@@ -0,0 +1,342 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/digitalocean/ceph_exporter/ceph"
+)
+
+func TestNewRegistryGoMetrics(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		goMetrics bool
+		present   bool
+	}{
+		{
+			name:      "enabled",
+			goMetrics: true,
+			present:   true,
+		},
+		{
+			name:      "disabled",
+			goMetrics: false,
+			present:   false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newRegistry(tt.goMetrics)
+
+			server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.present, regexp.MustCompile(`(?m)^go_goroutines `).Match(buf))
+			require.Equal(t, tt.present, regexp.MustCompile(`(?m)^process_start_time_seconds `).Match(buf))
+		})
+	}
+}
+
+func TestNewConfigInfo(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newConfigInfo(ceph.ExporterOptions{RgwMode: 1, OSDPerfDump: true}, true, 2))
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	re := regexp.MustCompile(`ceph_exporter_config_info{auth_metrics="false",device_health="false",num_clusters="2",osd_op_queue="false",osd_perf_dump="true",pg_query="false",rgw_mode="1",tls="true"} 1`)
+	require.True(t, re.Match(buf), "got:\n%s", buf)
+}
+
+func TestNewStartTime(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newStartTime(start)...)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		values[family.GetName()] = family.GetMetric()[0].GetGauge().GetValue()
+	}
+
+	require.InDelta(t, float64(start.Unix()), values["ceph_exporter_start_time_seconds"], 1)
+	require.GreaterOrEqual(t, values["ceph_exporter_uptime_seconds"], 60.0)
+	require.Less(t, values["ceph_exporter_uptime_seconds"], 120.0)
+}
+
+func TestParseBuckets(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		input   string
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:  "sorted",
+			input: "0.5, 0.05,1,10",
+			want:  []float64{0.05, 0.5, 1, 10},
+		},
+		{
+			name:    "invalid",
+			input:   "0.1,fast",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBuckets(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	require.Nil(t, parseList(""))
+	require.Equal(t, []string{"ssd"}, parseList("ssd"))
+	require.Equal(t, []string{"ssd", "nvme"}, parseList(" ssd,, nvme ,"))
+}
+
+// versionOnlyFixture returns a fixture directory that has the recorded
+// response to `ceph version` and nothing else.
+func versionOnlyFixture(t *testing.T) string {
+	buf, err := ioutil.ReadFile("ceph/testdata/fixture/version.json")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "version.json"), buf, 0644))
+
+	return dir
+}
+
+func TestScrapeOnce(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		dir     string
+		failed  bool
+		reMatch []*regexp.Regexp
+	}{
+		{
+			name:   "all commands answered",
+			dir:    "ceph/testdata/fixture",
+			failed: false,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`(?m)^# TYPE ceph_health_status gauge$`),
+				regexp.MustCompile(`(?m)^ceph_health_status{cluster="ceph"} 0$`),
+				regexp.MustCompile(`(?m)^ceph_monitor_quorum_count{cluster="ceph"} 3$`),
+				regexp.MustCompile(`(?m)^ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} 0$`),
+			},
+		},
+		{
+			// only the version is answered, enough to create the exporter
+			name:   "commands failed",
+			dir:    versionOnlyFixture(t),
+			failed: true,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`(?m)^ceph_exporter_last_scrape_error_timestamp_seconds{cluster="ceph"} [1-9]`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetOutput(ioutil.Discard)
+
+			exporter, err := ceph.NewExporter(ceph.NewFixtureConn(tt.dir), "ceph", ceph.ExporterOptions{User: "admin"}, logger)
+			require.NoError(t, err)
+			defer exporter.Close()
+
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(exporter)
+
+			var stdout bytes.Buffer
+			failed, err := scrapeOnce(registry, nil, nil, &stdout)
+			require.NoError(t, err)
+			require.Equal(t, tt.failed, failed)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(stdout.Bytes()), "expected %s to match", re.String())
+			}
+		})
+	}
+}
+
+func TestWithHeaders(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler := withHeaders(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), map[string]string{
+		"Cache-Control":             "no-store",
+		"Strict-Transport-Security": "max-age=31536000",
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	require.Equal(t, "max-age=31536000", resp.Header.Get("Strict-Transport-Security"))
+}
+
+func TestHealthCheckSeverity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+cluster:
+  - cluster_label: default
+    user: admin
+    config_file: /etc/ceph/ceph.conf
+  - cluster_label: override
+    user: admin
+    config_file: /etc/ceph/ceph.conf
+    health_check_severity:
+      OSD_DOWN: 2
+`), 0600))
+
+	cfg, err := ParseConfig(path)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	// The report has a single failing health check, OSD_DOWN.
+	registry := prometheus.NewRegistry()
+	for _, cluster := range cfg.Cluster {
+		conn, err := ceph.NewReportConn("ceph/testdata/report.json")
+		require.NoError(t, err)
+
+		exporter, err := ceph.NewExporter(conn, cluster.ClusterLabel, ceph.ExporterOptions{User: "admin", HealthCheckSeverity: cluster.HealthCheckSeverity}, logger)
+		require.NoError(t, err)
+		defer exporter.Close()
+		registry.MustRegister(exporter)
+	}
+
+	var stdout bytes.Buffer
+	_, err = scrapeOnce(registry, nil, nil, &stdout)
+	require.NoError(t, err)
+
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^ceph_health_status_interp{cluster="default"} 1$`),
+		regexp.MustCompile(`(?m)^ceph_health_status_interp{cluster="override"} 2$`),
+	} {
+		require.True(t, re.Match(stdout.Bytes()), "expected %s to match", re.String())
+	}
+}
+
+func TestMetricFilter(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	exporter, err := ceph.NewExporter(ceph.NewFixtureConn("ceph/testdata/fixture"), "ceph", ceph.ExporterOptions{User: "admin"}, logger)
+	require.NoError(t, err)
+	defer exporter.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter)
+
+	gatherer := newMetricFilter(registry, []string{"ceph_health_*", "ceph_monitor_*"}, []string{"ceph_health_status_interp"})
+
+	server := httptest.NewServer(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^ceph_health_status{cluster="ceph"} 0$`),
+		regexp.MustCompile(`(?m)^ceph_monitor_quorum_count{cluster="ceph"} 3$`),
+	} {
+		require.True(t, re.Match(buf), "expected %s to match", re.String())
+	}
+
+	for _, re := range []*regexp.Regexp{
+		// denied
+		regexp.MustCompile(`(?m)^ceph_health_status_interp`),
+		// not allowed
+		regexp.MustCompile(`(?m)^ceph_osd_up`),
+		regexp.MustCompile(`(?m)^ceph_exporter_last_scrape_error_timestamp_seconds`),
+	} {
+		require.False(t, re.Match(buf), "expected %s not to match", re.String())
+	}
+}
+
+func TestScrapeOnceFiltered(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	// Only the version is answered, so the collectors fail.
+	exporter, err := ceph.NewExporter(ceph.NewFixtureConn(versionOnlyFixture(t)), "ceph", ceph.ExporterOptions{User: "admin"}, logger)
+	require.NoError(t, err)
+	defer exporter.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter)
+
+	// The allowlist leaves out the last scrape error, which still fails the
+	// scrape.
+	var stdout bytes.Buffer
+	failed, err := scrapeOnce(registry, []string{"ceph_exporter_collectors"}, nil, &stdout)
+	require.NoError(t, err)
+	require.True(t, failed)
+
+	require.Regexp(t, `(?m)^ceph_exporter_collectors{`, stdout.String())
+	require.NotRegexp(t, lastScrapeErrorMetric, stdout.String())
+}
+
+func TestNewMetricFilterEmpty(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.Equal(t, prometheus.Gatherer(registry), newMetricFilter(registry, nil, nil))
+}
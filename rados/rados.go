@@ -16,11 +16,16 @@ package rados
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ceph/go-ceph/rados"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
 	"github.com/digitalocean/ceph_exporter/ceph"
@@ -29,11 +34,153 @@ import (
 // RadosConn implements the Conn interface with the underlying *rados.Conn
 // that talks to a real Ceph cluster.
 type RadosConn struct {
+	cluster    string
 	user       string
 	conn       *rados.Conn
 	configFile string
+	keyFile    *keyFile
 	timeout    time.Duration
 	logger     *logrus.Logger
+
+	// connectTimeout bounds connecting to the cluster, which isn't covered
+	// by the op timeouts.
+	connectTimeout time.Duration
+
+	// mu guards conn, which is replaced when the key file is rotated.
+	mu sync.RWMutex
+}
+
+// keyFileRetryInterval is how long a key that failed to connect is kept from
+// being tried again. Reconnecting can take up to the connect timeout, which
+// every command would otherwise wait for while the new key is unusable.
+const keyFileRetryInterval = time.Minute
+
+// keyFile tracks a file whose contents are a cephx key, such as a Kubernetes
+// projected secret, so that a rotated key can be noticed without a restart.
+// The file is only read again once its modification time changes.
+type keyFile struct {
+	path string
+
+	// now is time.Now, swapped out in tests.
+	now func() time.Time
+
+	// key is the key in use and modTime the modification time of the file
+	// it was read from. failedAt is when connecting with a newer key last
+	// failed, zero if it didn't.
+	mu       sync.Mutex
+	key      string
+	modTime  time.Time
+	failedAt time.Time
+}
+
+func newKeyFile(path string) *keyFile {
+	return &keyFile{
+		path: path,
+		now:  time.Now,
+	}
+}
+
+// modified reports whether the file was modified since the key in use was
+// read from it. After a failed reload it reports false until
+// keyFileRetryInterval has passed, the current connection is used meanwhile.
+func (k *keyFile) modified() (bool, error) {
+	fi, err := os.Stat(k.path)
+	if err != nil {
+		return false, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.failedAt.IsZero() && k.now().Sub(k.failedAt) < keyFileRetryInterval {
+		return false, nil
+	}
+
+	return !fi.ModTime().Equal(k.modTime), nil
+}
+
+// reload reads the key from disk and, if it differs from the key in use,
+// passes it to connect. The key only becomes the key in use once connect
+// succeeded, so that a failed connect is retried by a later reload.
+func (k *keyFile) reload(connect func(key string) error) error {
+	fi, err := os.Stat(k.path)
+	if err != nil {
+		return fmt.Errorf("error reading key file: %s", err)
+	}
+
+	buf, err := ioutil.ReadFile(k.path)
+	if err != nil {
+		return fmt.Errorf("error reading key file: %s", err)
+	}
+	key := string(bytes.TrimSpace(buf))
+
+	k.mu.Lock()
+	unchanged := key == k.key
+	k.mu.Unlock()
+
+	if !unchanged {
+		if err := connect(key); err != nil {
+			k.mu.Lock()
+			k.failedAt = k.now()
+			k.mu.Unlock()
+
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.key = key
+	k.modTime = fi.ModTime()
+	k.failedAt = time.Time{}
+
+	return nil
+}
+
+// ConnectTimeouts counts the connection attempts that were given up on because
+// the cluster couldn't be reached within the connect timeout, e.g. because the
+// mons are unreachable.
+var ConnectTimeouts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ceph_exporter_connect_timeouts_total",
+		Help: "Number of connection attempts to the cluster that timed out",
+	},
+	[]string{"cluster"},
+)
+
+// errConnectTimeout is returned when connecting took longer than the connect
+// timeout.
+var errConnectTimeout = errors.New("timeout")
+
+// connectWithTimeout runs connect, giving up on it after timeout, where 0
+// means no limit. Ceph may retry the connection up to 10 times internally,
+// which essentially makes client_mount_timeout 10x longer, so connect runs in
+// a goroutine that is left behind if it doesn't return in time. Once it does
+// return, abandon is called to release what the attempt holds on to.
+func connectWithTimeout(connect func() error, abandon func(), timeout time.Duration) error {
+	if timeout == 0 {
+		return connect()
+	}
+
+	ch := make(chan error)
+	gaveUp := make(chan struct{})
+	go func() {
+		err := connect()
+		select {
+		case ch <- err:
+		case <-gaveUp:
+			abandon()
+		}
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(timeout):
+		close(gaveUp)
+		return errConnectTimeout
+	}
 }
 
 // *RadosConn must implement the Conn.
@@ -42,26 +189,46 @@ var _ ceph.Conn = &RadosConn{}
 // NewRadosConn returns a new RadosConn. Unlike the native rados.Conn, there
 // is no need to manage the connection before/after talking to the rados; it
 // is the responsibility of this *RadosConn to manage the connection.
-func NewRadosConn(user, configFile string, timeout time.Duration, logger *logrus.Logger) (*RadosConn, error) {
+//
+// If keyFilePath is set, the cephx key is read from that file instead of the
+// keyring, and the connection is re-established whenever its contents change.
+//
+// Connecting, initially and on reconnects, gives up after connectTimeout,
+// commands after timeout. Either being 0 means no limit. Connection attempts
+// that time out are counted by ConnectTimeouts under the cluster label.
+func NewRadosConn(cluster, user, configFile, keyFilePath string, timeout, connectTimeout time.Duration, logger *logrus.Logger) (*RadosConn, error) {
 	rc := &RadosConn{
-		user:       user,
-		configFile: configFile,
-		timeout:    timeout,
-		logger:     logger,
+		cluster:        cluster,
+		user:           user,
+		configFile:     configFile,
+		timeout:        timeout,
+		connectTimeout: connectTimeout,
+		logger:         logger,
 	}
 
-	if err := rc.establishConn(); err != nil {
+	if keyFilePath != "" {
+		rc.keyFile = newKeyFile(keyFilePath)
+		if err := rc.keyFile.reload(rc.establishConn); err != nil {
+			return nil, err
+		}
+
+		return rc, nil
+	}
+
+	if err := rc.establishConn(""); err != nil {
 		return nil, err
 	}
 
 	return rc, nil
 }
 
-// newRadosConn creates an established rados connection to the Ceph cluster
-// using the provided Ceph user and configFile. Ceph parameters
+// establishConn creates an established rados connection to the Ceph cluster
+// using the provided Ceph user and configFile, and the cephx key if it isn't
+// empty, and replaces the current connection with it. Ceph parameters
 // rados_osd_op_timeout and rados_mon_op_timeout are specified by the timeout
-// value, where 0 means no limit.
-func (c *RadosConn) establishConn() error {
+// value and client_mount_timeout by the connect timeout, where 0 means no
+// limit.
+func (c *RadosConn) establishConn(key string) error {
 	conn, err := rados.NewConnWithUser(c.user)
 	if err != nil {
 		return fmt.Errorf("error creating rados connection: %s", err)
@@ -72,6 +239,13 @@ func (c *RadosConn) establishConn() error {
 		return fmt.Errorf("error reading config file: %s", err)
 	}
 
+	if key != "" {
+		err = conn.SetConfigOption("key", key)
+		if err != nil {
+			return fmt.Errorf("error setting key: %s", err)
+		}
+	}
+
 	tv := strconv.FormatFloat(c.timeout.Seconds(), 'f', -1, 64)
 	// Set rados_osd_op_timeout and rados_mon_op_timeout to avoid Mon
 	// and PG command hang.
@@ -87,35 +261,72 @@ func (c *RadosConn) establishConn() error {
 		return fmt.Errorf("error setting rados_mon_op_timeout: %s", err)
 	}
 
-	err = conn.SetConfigOption("client_mount_timeout", tv)
+	// client_mount_timeout bounds a single attempt of connecting, which
+	// connectWithTimeout doesn't wait for longer than connectTimeout either.
+	ctv := strconv.FormatFloat(c.connectTimeout.Seconds(), 'f', -1, 64)
+	err = conn.SetConfigOption("client_mount_timeout", ctv)
 	if err != nil {
 		return fmt.Errorf("error setting client_mount_timeout: %s", err)
 	}
 
-	// Ceph may retry the connection up to 10 times internally, which essentially makes client_mount_timeout 10x longer.
-	// Use a goroutine, channel, and a select statement to implement our own timeout wrapper for connections
-	ch := make(chan error, 1)
-	go func(conn *rados.Conn, ch chan error) {
-		defer close(ch)
-		ch <- conn.Connect()
-	}(conn, ch)
-
-	select {
-	case err = <-ch:
-	case <-time.After(c.timeout):
-		return fmt.Errorf("error connecting to rados: timeout")
+	err = connectWithTimeout(conn.Connect, conn.Shutdown, c.connectTimeout)
+	if err == errConnectTimeout {
+		ConnectTimeouts.WithLabelValues(c.cluster).Inc()
 	}
-
 	if err != nil {
 		return fmt.Errorf("error connecting to rados: %s", err)
 	}
 
+	if c.conn != nil {
+		c.conn.Shutdown()
+	}
 	c.conn = conn
 	return nil
 }
 
+// reconnectOnKeyChange re-establishes the connection to the cluster if the
+// key file has been rotated since the current connection was made. Only the
+// modification time of the file is checked on every command.
+func (c *RadosConn) reconnectOnKeyChange() {
+	if c.keyFile == nil {
+		return
+	}
+
+	ll := c.logger.WithField("file", c.keyFile.path)
+
+	modified, err := c.keyFile.modified()
+	if err != nil {
+		ll.WithError(err).Error("error checking key file")
+		return
+	}
+	if !modified {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another command may have reconnected while we were waiting for the lock.
+	if modified, err = c.keyFile.modified(); err != nil || !modified {
+		return
+	}
+
+	err = c.keyFile.reload(func(key string) error {
+		ll.Info("key file changed, reconnecting to rados")
+		return c.establishConn(key)
+	})
+	if err != nil {
+		ll.WithError(err).WithField("retry_in", keyFileRetryInterval).Error("error reconnecting to rados with the new key, keeping the current connection")
+	}
+}
+
 // MonCommand executes a monitor command to rados.
 func (c *RadosConn) MonCommand(args []byte) (buffer []byte, info string, err error) {
+	c.reconnectOnKeyChange()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	ll := c.logger.WithField("args", string(args)).WithField("conn", c.conn.GetInstanceID())
 	ll.Trace("start executing mon command")
 
@@ -131,6 +342,11 @@ func (c *RadosConn) MonCommand(args []byte) (buffer []byte, info string, err err
 
 // MgrCommand executes a manager command to rados.
 func (c *RadosConn) MgrCommand(args [][]byte) (buffer []byte, info string, err error) {
+	c.reconnectOnKeyChange()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	ll := c.logger.WithField("args", string(bytes.Join(args, []byte(",")))).WithField("conn", c.conn.GetInstanceID())
 	ll.Trace("start executing mgr command")
 
@@ -144,8 +360,33 @@ func (c *RadosConn) MgrCommand(args [][]byte) (buffer []byte, info string, err e
 	return
 }
 
+// OsdCommand executes a command against a single OSD daemon.
+func (c *RadosConn) OsdCommand(osd int, args [][]byte) (buffer []byte, info string, err error) {
+	c.reconnectOnKeyChange()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ll := c.logger.WithField("osd", osd).WithField("args", string(bytes.Join(args, []byte(",")))).WithField("conn", c.conn.GetInstanceID())
+	ll.Trace("start executing osd command")
+
+	buffer, info, err = c.conn.OsdCommand(osd, args)
+	if err == nil {
+		buffer = handleCephInf(buffer)
+	}
+
+	ll.WithError(err).Trace("complete executing osd command")
+
+	return
+}
+
 // GetPoolStats returns the count of unfound objects for the given rados pool.
 func (c *RadosConn) GetPoolStats(pool string) (*ceph.PoolStat, error) {
+	c.reconnectOnKeyChange()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	ll := c.logger.WithField("pool", pool).WithField("conn", c.conn.GetInstanceID())
 	ll.Trace("opening IOContext for pool")
 
@@ -0,0 +1,154 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rados
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.key")
+	writeKey := func(key string, modTime time.Time) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(key+"\n"), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	modTime := time.Unix(1700000000, 0)
+	writeKey("AQBfirstkey==", modTime)
+
+	// connect fails as long as fail is set and records the keys it was
+	// called with.
+	var (
+		fail bool
+		keys []string
+	)
+	connect := func(key string) error {
+		keys = append(keys, key)
+		if fail {
+			return errors.New("permission denied")
+		}
+		return nil
+	}
+
+	now := time.Unix(1700000000, 0)
+	kf := newKeyFile(path)
+	kf.now = func() time.Time { return now }
+	require.NoError(t, kf.reload(connect))
+	require.Equal(t, []string{"AQBfirstkey=="}, keys)
+
+	modified, err := kf.modified()
+	require.NoError(t, err)
+	require.False(t, modified)
+
+	// Rotate the secret on disk; the next reconnect must pick it up.
+	modTime = modTime.Add(time.Minute)
+	writeKey("AQBsecondkey==", modTime)
+
+	modified, err = kf.modified()
+	require.NoError(t, err)
+	require.True(t, modified)
+
+	// A failed reconnect leaves the old key in use, the reconnect is only
+	// tried again once keyFileRetryInterval has passed rather than by
+	// every command.
+	fail = true
+	require.EqualError(t, kf.reload(connect), "permission denied")
+
+	modified, err = kf.modified()
+	require.NoError(t, err)
+	require.False(t, modified)
+
+	now = now.Add(keyFileRetryInterval)
+	modified, err = kf.modified()
+	require.NoError(t, err)
+	require.True(t, modified)
+
+	fail = false
+	require.NoError(t, kf.reload(connect))
+	require.Equal(t, []string{"AQBfirstkey==", "AQBsecondkey==", "AQBsecondkey=="}, keys)
+
+	modified, err = kf.modified()
+	require.NoError(t, err)
+	require.False(t, modified)
+
+	// Touching the file without changing the key doesn't reconnect.
+	modTime = modTime.Add(time.Minute)
+	writeKey("AQBsecondkey==", modTime)
+
+	modified, err = kf.modified()
+	require.NoError(t, err)
+	require.True(t, modified)
+
+	require.NoError(t, kf.reload(connect))
+	require.Len(t, keys, 3)
+
+	modified, err = kf.modified()
+	require.NoError(t, err)
+	require.False(t, modified)
+
+	// A missing file is reported rather than treated as a rotation.
+	kf = newKeyFile(filepath.Join(t.TempDir(), "missing"))
+	_, err = kf.modified()
+	require.Error(t, err)
+	require.Error(t, kf.reload(connect))
+}
+
+func TestConnectWithTimeout(t *testing.T) {
+	// A connect that never returns, as when no mon can be reached.
+	block := make(chan struct{})
+	defer close(block)
+
+	err := connectWithTimeout(func() error {
+		<-block
+		return nil
+	}, func() {}, 10*time.Millisecond)
+	require.Equal(t, errConnectTimeout, err)
+
+	// A connect that was given up on is abandoned once it returns.
+	unblock := make(chan struct{})
+	abandoned := make(chan struct{})
+	err = connectWithTimeout(func() error {
+		<-unblock
+		return nil
+	}, func() { close(abandoned) }, 10*time.Millisecond)
+	require.Equal(t, errConnectTimeout, err)
+	close(unblock)
+	select {
+	case <-abandoned:
+	case <-time.After(time.Second):
+		t.Fatal("timed out connect wasn't abandoned")
+	}
+
+	notAbandoned := func() { t.Error("connect returning in time was abandoned") }
+
+	// Errors of a connect returning in time are passed on.
+	err = connectWithTimeout(func() error {
+		return errors.New("permission denied")
+	}, notAbandoned, time.Minute)
+	require.EqualError(t, err, "permission denied")
+
+	// No limit, the connect isn't raced against a timer.
+	err = connectWithTimeout(func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, notAbandoned, 0)
+	require.NoError(t, err)
+}